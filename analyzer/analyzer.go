@@ -0,0 +1,437 @@
+// Package analyzer reports circular and unregistered dependencies between the factories
+// registered in service registries generated by go-service-locator.
+package analyzer
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `report circular and unregistered dependencies between service factories
+
+The analyzer looks for Register<X> (and Register<X>Context) calls on generated service registries
+and collects the Get<Y> calls made on the ServiceLocator parameter of the function literals passed to them.
+Services registered with Register<X>Instance have no dependencies.
+Register<X>Pattern and Register<X>Default make every name of a named service registered.
+
+Only factories passed as function literals are checked.
+Named services are checked when their name is a constant (GetAll<Y> calls are not checked).
+
+Registrations in the same package are checked together for every registry of the same type
+(eg. when factories are registered by multiple helper functions).
+Services resolved by constructors (see the //servicelocator:constructor directive) are registered by every registry.
+Dependencies are expected to be registered in the same package as the services depending on them:
+use -unregistered=false to disable reporting unregistered dependencies otherwise.`
+
+// Analyzer reports circular and unregistered dependencies between service factories.
+var Analyzer = &analysis.Analyzer{
+	Name:      "servicelocator",
+	Doc:       doc,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(constructorFact)},
+}
+
+var reportUnregistered bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&reportUnregistered, "unregistered", true, "report dependencies without a registered factory")
+}
+
+// service identifies a service in a registry.
+type service struct {
+	typ  string
+	name string
+}
+
+func (s service) String() string {
+	if s.name == "" {
+		return s.typ
+	}
+
+	return s.typ + ":" + s.name
+}
+
+// dependency records that the factory of a service resolves another service.
+type dependency struct {
+	from service
+	to   service
+	pos  token.Pos
+
+	// locator is the type of the ServiceLocator parameter of the factory
+	locator types.Type
+}
+
+// constructorFact marks the methods of service locator interfaces
+// resolving services constructed by a constructor function (registered by default in generated registries).
+type constructorFact struct{}
+
+func (*constructorFact) AFact() {}
+
+func (*constructorFact) String() string { return "constructor" }
+
+// registryGraph collects the factories registered in a registry.
+type registryGraph struct {
+	registered   map[service]bool
+	dynamicNames map[string]bool // service types registered with non-constant names
+	dependencies []dependency
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	exportConstructorFacts(pass, inspect)
+
+	graphs := make(map[any]*registryGraph)
+	var registries []any
+
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+
+		registry, svc, dynamic, ok := registration(pass.TypesInfo, call)
+		if !ok {
+			return
+		}
+
+		graph, ok := graphs[registry]
+		if !ok {
+			graph = &registryGraph{
+				registered:   make(map[service]bool),
+				dynamicNames: make(map[string]bool),
+			}
+			graphs[registry] = graph
+			registries = append(registries, registry)
+		}
+
+		if dynamic {
+			graph.dynamicNames[svc.typ] = true
+
+			return
+		}
+
+		graph.registered[svc] = true
+
+		factory, ok := astutil.Unparen(call.Args[len(call.Args)-1]).(*ast.FuncLit)
+		if !ok {
+			return
+		}
+
+		graph.dependencies = append(graph.dependencies, factoryDependencies(pass.TypesInfo, svc, factory)...)
+	})
+
+	for _, registry := range registries {
+		graph := graphs[registry]
+
+		if reportUnregistered {
+			for _, dep := range graph.dependencies {
+				if graph.registered[dep.to] || (dep.to.name != "" && graph.dynamicNames[dep.to.typ]) || hasConstructor(pass, dep) {
+					continue
+				}
+
+				pass.Reportf(dep.pos, "%s depends on %s, which is not registered", dep.from, dep.to)
+			}
+		}
+
+		for _, cycle := range findCycles(graph.dependencies) {
+			path := make([]string, 0, len(cycle)+1)
+			for _, dep := range cycle {
+				path = append(path, dep.from.String())
+			}
+			path = append(path, cycle[0].from.String())
+
+			pass.Reportf(cycle[len(cycle)-1].pos, "circular dependency: %s", strings.Join(path, " -> "))
+		}
+	}
+
+	return nil, nil
+}
+
+// exportConstructorFacts marks the interface methods declaring a constructor directive:
+//
+//	type ServiceLocator interface {
+//		//servicelocator:constructor NewMailer
+//		GetMailer() (*Mailer, error)
+//	}
+func exportConstructorFacts(pass *analysis.Pass, inspect *inspector.Inspector) {
+	inspect.Preorder([]ast.Node{(*ast.InterfaceType)(nil)}, func(n ast.Node) {
+		for _, method := range n.(*ast.InterfaceType).Methods.List {
+			if len(method.Names) == 0 || !hasConstructorDirective(method.Doc) {
+				continue
+			}
+
+			if obj := pass.TypesInfo.Defs[method.Names[0]]; obj != nil {
+				pass.ExportObjectFact(obj, new(constructorFact))
+			}
+		}
+	})
+}
+
+func hasConstructorDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+
+	for _, c := range doc.List {
+		directive, ok := strings.CutPrefix(c.Text, "//servicelocator:")
+		if fields := strings.Fields(directive); ok && len(fields) > 0 && fields[0] == "constructor" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasConstructor checks whether the service dep resolves is constructed by a constructor function
+// (declared by the interface of the ServiceLocator parameter).
+func hasConstructor(pass *analysis.Pass, dep dependency) bool {
+	obj, _, _ := types.LookupFieldOrMethod(dep.locator, true, nil, "Get"+dep.to.typ)
+
+	method, ok := obj.(*types.Func)
+
+	return ok && pass.ImportObjectFact(method, new(constructorFact))
+}
+
+// registration checks if call registers a factory in a service registry.
+//
+// A service registry is recognized by having both Register<X> and Get<X> methods.
+// Registries are identified by their type (see registryKey).
+// Registrations using a non-constant service name are reported as dynamic.
+func registration(info *types.Info, call *ast.CallExpr) (registry any, svc service, dynamic bool, ok bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !strings.HasPrefix(sel.Sel.Name, "Register") {
+		return nil, service{}, false, false
+	}
+
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.MethodVal {
+		return nil, service{}, false, false
+	}
+
+	recv := selection.Recv()
+
+	name := strings.TrimPrefix(sel.Sel.Name, "Register")
+
+	// Register<X>Context accepts context-aware factories, Register<X>Instance accepts instances.
+	// Suffixes are checked first: registries also have Get<X>Context methods.
+	var typ string
+	for _, suffix := range []string{"Context", "Instance", ""} {
+		if strings.HasSuffix(name, suffix) && hasMethod(recv, "Get"+strings.TrimSuffix(name, suffix)) {
+			typ = strings.TrimSuffix(name, suffix)
+
+			break
+		}
+	}
+
+	// Pattern and default factories of named services serve names that are not known statically
+	if typ == "" {
+		for _, suffix := range []string{"PatternContext", "Pattern", "DefaultContext", "Default"} {
+			if strings.HasSuffix(name, suffix) && hasMethod(recv, "Get"+strings.TrimSuffix(name, suffix)) {
+				return registryKey(recv), service{typ: strings.TrimSuffix(name, suffix)}, true, true
+			}
+		}
+
+		return nil, service{}, false, false
+	}
+
+	registry = registryKey(recv)
+
+	svc = service{typ: typ}
+
+	switch len(call.Args) {
+	case 1:
+	case 2:
+		name, ok := constantString(info, call.Args[0])
+		if !ok {
+			return registry, svc, true, true
+		}
+
+		svc.name = name
+
+	default:
+		return nil, service{}, false, false
+	}
+
+	return registry, svc, false, true
+}
+
+// registryKey identifies a registry by its type.
+//
+// Applications usually register factories in a single registry, often spread across helper functions
+// (eg. registerDatabase(r *ServiceRegistry) and registerHTTP(r *ServiceRegistry)):
+// registrations of every registry of the same type in a package are checked together.
+func registryKey(recv types.Type) any {
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+
+	return types.TypeString(recv, nil)
+}
+
+// factoryDependencies collects the services resolved by factory through its ServiceLocator parameter.
+func factoryDependencies(info *types.Info, svc service, factory *ast.FuncLit) []dependency {
+	params := factory.Type.Params.List
+	if len(params) == 0 {
+		return nil
+	}
+
+	names := params[len(params)-1].Names
+	if len(names) == 0 {
+		return nil
+	}
+
+	locator := info.Defs[names[len(names)-1]]
+	if locator == nil {
+		return nil
+	}
+
+	var dependencies []dependency
+
+	ast.Inspect(factory.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !strings.HasPrefix(sel.Sel.Name, "Get") {
+			return true
+		}
+
+		ident, ok := astutil.Unparen(sel.X).(*ast.Ident)
+		if !ok || info.Uses[ident] != locator {
+			return true
+		}
+
+		// GetAll<X> resolves every registered name of a named service
+		if all := strings.TrimPrefix(sel.Sel.Name, "GetAll"); all != sel.Sel.Name && hasMethod(locator.Type(), "Get"+all) {
+			return true
+		}
+
+		dep := service{typ: strings.TrimPrefix(sel.Sel.Name, "Get")}
+
+		if len(call.Args) == 1 {
+			name, ok := constantString(info, call.Args[0])
+			if !ok {
+				return true
+			}
+
+			dep.name = name
+		}
+
+		dependencies = append(dependencies, dependency{
+			from:    svc,
+			to:      dep,
+			pos:     call.Pos(),
+			locator: locator.Type(),
+		})
+
+		return true
+	})
+
+	return dependencies
+}
+
+// findCycles returns the cycles of the dependency graph (each of them once),
+// as the list of dependencies forming the cycle.
+func findCycles(dependencies []dependency) [][]dependency {
+	edges := make(map[service][]dependency)
+	var services []service
+
+	for _, dep := range dependencies {
+		if _, ok := edges[dep.from]; !ok {
+			services = append(services, dep.from)
+		}
+
+		edges[dep.from] = append(edges[dep.from], dep)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].String() < services[j].String()
+	})
+
+	var cycles [][]dependency
+	seen := make(map[string]bool)
+
+	var path []dependency
+	onPath := make(map[service]int)
+	done := make(map[service]bool)
+
+	var visit func(svc service)
+	visit = func(svc service) {
+		onPath[svc] = len(path)
+
+		for _, dep := range edges[svc] {
+			if i, ok := onPath[dep.to]; ok {
+				cycle := append(append([]dependency(nil), path[i:]...), dep)
+
+				if key := cycleKey(cycle); !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+
+				continue
+			}
+
+			if done[dep.to] {
+				continue
+			}
+
+			path = append(path, dep)
+			visit(dep.to)
+			path = path[:len(path)-1]
+		}
+
+		delete(onPath, svc)
+		done[svc] = true
+	}
+
+	for _, svc := range services {
+		if !done[svc] {
+			visit(svc)
+		}
+	}
+
+	return cycles
+}
+
+// cycleKey identifies a cycle regardless of the service it starts with.
+func cycleKey(cycle []dependency) string {
+	start := 0
+	for i, dep := range cycle {
+		if dep.from.String() < cycle[start].from.String() {
+			start = i
+		}
+	}
+
+	var key []string
+	for i := range cycle {
+		key = append(key, cycle[(start+i)%len(cycle)].from.String())
+	}
+
+	return strings.Join(key, " -> ")
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+
+	return ok
+}
+
+func constantString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
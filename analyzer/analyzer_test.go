@@ -0,0 +1,76 @@
+package analyzer_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/sagikazarmark/go-service-locator/analyzer"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), analyzer.Analyzer, "a", "b")
+}
+
+func TestDependencies(t *testing.T) {
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, filepath.Join(analysistest.TestData(), "src", "a"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []*ast.File
+	for _, file := range pkgs["a"].Files {
+		files = append(files, file)
+	}
+
+	// Keep the order of files stable
+	sort.Slice(files, func(i, j int) bool {
+		return fset.File(files[i].Pos()).Name() < fset.File(files[j].Pos()).Name()
+	})
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+
+	if _, err := conf.Check("a", fset, files, info); err != nil {
+		t.Fatal(err)
+	}
+
+	var edges []string
+	for _, dep := range analyzer.Dependencies(info, files) {
+		if dep.Registry.String() != "*a.ServiceRegistry" {
+			t.Errorf("unexpected registry type %s", dep.Registry)
+		}
+
+		edges = append(edges, dep.From.String()+" -> "+dep.To.String())
+	}
+
+	expected := []string{
+		"ServiceA -> ServiceB:b",
+		"ServiceA -> ServiceB:unknown",
+		"ServiceB:b -> ServiceC",
+		"ServiceB:b -> ServiceE",
+		"ServiceC -> ServiceA",
+		"ServiceD -> ServiceD",
+		"ServiceD -> ServiceF",
+	}
+
+	if strings.Join(edges, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected dependencies:\n%s\n\nexpected:\n%s", strings.Join(edges, "\n"), strings.Join(expected, "\n"))
+	}
+}
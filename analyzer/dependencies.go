@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Service identifies a service in a registry.
+type Service struct {
+	// Type is the name of the service (eg. Database for GetDatabase).
+	Type string
+
+	// Name is the name of a named service (empty for unnamed services).
+	Name string
+}
+
+func (s Service) String() string {
+	return service{typ: s.Type, name: s.Name}.String()
+}
+
+// Dependency records that the factory registered for a service resolves another service.
+type Dependency struct {
+	// Registry is the type of the registry the factory is registered in.
+	Registry types.Type
+
+	From Service
+	To   Service
+}
+
+// Dependencies collects the dependencies between the factories registered in files
+// the same way the analyzer does (in the order they appear).
+//
+// Factories registered with non-constant names (and dependencies on them) are skipped.
+func Dependencies(info *types.Info, files []*ast.File) []Dependency {
+	var dependencies []Dependency
+
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			_, svc, dynamic, ok := registration(info, call)
+			if !ok || dynamic {
+				return true
+			}
+
+			factory, ok := astutil.Unparen(call.Args[len(call.Args)-1]).(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+
+			registry := info.Selections[call.Fun.(*ast.SelectorExpr)].Recv()
+
+			for _, dep := range factoryDependencies(info, svc, factory) {
+				dependencies = append(dependencies, Dependency{
+					Registry: registry,
+					From:     Service{Type: dep.from.typ, Name: dep.from.name},
+					To:       Service{Type: dep.to.typ, Name: dep.to.name},
+				})
+			}
+
+			return true
+		})
+	}
+
+	return dependencies
+}
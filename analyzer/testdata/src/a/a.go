@@ -0,0 +1,40 @@
+package a
+
+import "context"
+
+const serviceName = "b"
+
+func register(registry *ServiceRegistry, name string) {
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceB(serviceName)
+		_, _ = serviceLocator.GetServiceB("unknown") // want `ServiceA depends on ServiceB:unknown, which is not registered`
+
+		return "", nil
+	})
+
+	registry.RegisterServiceEInstance("e")
+
+	registry.RegisterServiceB(serviceName, func(_ string, serviceLocator ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceC()
+		_, _ = serviceLocator.GetServiceE()
+
+		return "", nil
+	})
+
+	registry.RegisterServiceCContext(func(_ context.Context, serviceLocator ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceA() // want `circular dependency: ServiceA -> ServiceB:b -> ServiceC -> ServiceA`
+		_, _ = serviceLocator.GetServiceB(name)
+
+		return "", nil
+	})
+
+	registry.RegisterServiceD(func(serviceLocator ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceD() // want `circular dependency: ServiceD -> ServiceD`
+		_, _ = serviceLocator.GetAllServiceB()
+
+		// Services with a constructor are registered by default
+		_, _ = serviceLocator.GetServiceF()
+
+		return "", nil
+	})
+}
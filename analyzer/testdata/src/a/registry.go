@@ -0,0 +1,48 @@
+package a
+
+import "context"
+
+// The registry mimics the generated code.
+
+type ServiceLocator interface {
+	GetServiceA() (string, error)
+	GetServiceB(name string) (string, error)
+	GetAllServiceB() (map[string]string, error)
+	GetServiceC() (string, error)
+	GetServiceD() (string, error)
+	GetServiceE() (string, error)
+
+	//servicelocator:constructor NewServiceF
+	GetServiceF() (string, error) // want GetServiceF:"constructor"
+}
+
+type ServiceFactory[T any] func(ServiceLocator) (T, error)
+
+type NamedServiceFactory[T any] func(string, ServiceLocator) (T, error)
+
+type ContextServiceFactory[T any] func(context.Context, ServiceLocator) (T, error)
+
+type ServiceRegistry struct{}
+
+func (r *ServiceRegistry) RegisterServiceA(factory ServiceFactory[string])                   {}
+func (r *ServiceRegistry) RegisterServiceB(name string, factory NamedServiceFactory[string]) {}
+func (r *ServiceRegistry) RegisterServiceBDefault(factory NamedServiceFactory[string])       {}
+func (r *ServiceRegistry) RegisterServiceC(factory ServiceFactory[string])                   {}
+func (r *ServiceRegistry) RegisterServiceCContext(factory ContextServiceFactory[string])     {}
+func (r *ServiceRegistry) RegisterServiceD(factory ServiceFactory[string])                   {}
+func (r *ServiceRegistry) RegisterServiceEInstance(instance string)                          {}
+
+func (r *ServiceRegistry) GetServiceA() (string, error)            { return "", nil }
+func (r *ServiceRegistry) GetServiceB(name string) (string, error) { return "", nil }
+func (r *ServiceRegistry) GetServiceC() (string, error)            { return "", nil }
+func (r *ServiceRegistry) GetServiceD() (string, error)            { return "", nil }
+func (r *ServiceRegistry) GetServiceE() (string, error)            { return "", nil }
+func (r *ServiceRegistry) GetServiceF() (string, error)            { return "", nil }
+
+func (r *ServiceRegistry) GetServiceAContext(ctx context.Context) (string, error) { return "", nil }
+func (r *ServiceRegistry) GetServiceBContext(ctx context.Context, name string) (string, error) {
+	return "", nil
+}
+func (r *ServiceRegistry) GetServiceCContext(ctx context.Context) (string, error) { return "", nil }
+func (r *ServiceRegistry) GetServiceDContext(ctx context.Context) (string, error) { return "", nil }
+func (r *ServiceRegistry) GetServiceEContext(ctx context.Context) (string, error) { return "", nil }
@@ -0,0 +1,38 @@
+package b
+
+import "a"
+
+// Factories registered by separate helper functions share the registry
+
+func registerDatabase(registry *a.ServiceRegistry) {
+	registry.RegisterServiceC(func(serviceLocator a.ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceA() // want `circular dependency: ServiceA -> ServiceC -> ServiceA`
+
+		return "", nil
+	})
+}
+
+func registerHTTP(registry *a.ServiceRegistry) {
+	registry.RegisterServiceA(func(serviceLocator a.ServiceLocator) (string, error) {
+		_, _ = serviceLocator.GetServiceC()
+
+		// Every name of ServiceB is served by the default factory
+		_, _ = serviceLocator.GetServiceB("any")
+
+		// The constructor directive is declared in another package
+		_, _ = serviceLocator.GetServiceF()
+
+		return "", nil
+	})
+
+	registry.RegisterServiceBDefault(func(_ string, _ a.ServiceLocator) (string, error) {
+		return "", nil
+	})
+}
+
+func register() {
+	registry := &a.ServiceRegistry{}
+
+	registerDatabase(registry)
+	registerHTTP(registry)
+}
@@ -0,0 +1,17 @@
+// Command servicelocator-vet reports circular and unregistered dependencies between service factories.
+//
+// Run it through go vet:
+//
+//	go install github.com/sagikazarmark/go-service-locator/cmd/servicelocator-vet@latest
+//	go vet -vettool=$(which servicelocator-vet) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/sagikazarmark/go-service-locator/analyzer"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
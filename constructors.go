@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"golang.org/x/tools/go/packages"
+)
+
+// constructor describes a function constructing a service from other services:
+//
+//	type ServiceLocator interface {
+//		//servicelocator:constructor NewMailer
+//		GetMailer() (*Mailer, error)
+//	}
+//
+//	func NewMailer(ctx context.Context, config *Config) (*Mailer, error)
+type constructor struct {
+	fn     *types.Func
+	params []constructorParam
+
+	// ref references the constructor in doc comments (qualified by its package name if declared in another package)
+	ref string
+
+	// returnsError reports whether the constructor returns an error along with the service
+	returnsError bool
+
+	// identical reports whether the constructor returns the service type (rather than a type assignable to it)
+	identical bool
+}
+
+// constructorParamKind determines how a constructor parameter is resolved.
+type constructorParamKind int
+
+const (
+	// paramService parameters are resolved from the service locator.
+	paramService constructorParamKind = iota
+
+	// paramContext parameters receive the context of the resolution.
+	paramContext
+
+	// paramLocator parameters receive the service locator.
+	paramLocator
+
+	// paramName parameters receive the name of a named service.
+	paramName
+)
+
+type constructorParam struct {
+	kind constructorParamKind
+
+	// name is the name of the variable holding the parameter in the generated code (for service parameters)
+	name string
+
+	// service is the name of the service the parameter is resolved from (for service parameters)
+	service string
+}
+
+// resolveConstructors type checks the constructors selected by directives against the services declared by the interface.
+//
+// Constructors that cannot be called with the declared services are reported as errors (combined with errors.Join).
+func resolveConstructors(pkg *packages.Package, interfaceName string, services []serviceDefinition) error {
+	var errs []error
+
+	locator := pkg.Types.Scope().Lookup(interfaceName).Type()
+
+	for i, svc := range services {
+		if svc.constructorName == "" {
+			continue
+		}
+
+		c, err := resolveConstructor(pkg, locator, svc, services)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		services[i].constructor = c
+	}
+
+	return errors.Join(errs...)
+}
+
+func resolveConstructor(pkg *packages.Package, locator types.Type, svc serviceDefinition, services []serviceDefinition) (*constructor, error) {
+	fail := func(pos token.Pos, reason string) error {
+		return fmt.Errorf("%s: constructor %s of %s: %s", pkg.Fset.Position(pos), svc.constructorName, svc.name, reason)
+	}
+
+	fn, err := lookupConstructor(pkg, svc.constructorName)
+	if err != nil {
+		return nil, fail(svc.pos, err.Error())
+	}
+
+	sig := fn.Type().(*types.Signature)
+
+	if sig.TypeParams().Len() > 0 {
+		return nil, fail(fn.Pos(), "must not be generic")
+	}
+
+	if sig.Variadic() {
+		return nil, fail(fn.Pos(), "must not be variadic")
+	}
+
+	results := sig.Results()
+
+	if results.Len() == 0 || results.Len() > 2 || (results.Len() == 2 && results.At(1).Type().String() != "error") {
+		return nil, fail(fn.Pos(), "must return the service (and optionally an error)")
+	}
+
+	if !types.AssignableTo(results.At(0).Type(), svc.typ) {
+		return nil, fail(fn.Pos(), fmt.Sprintf("returns %s, which cannot be used as %s", typeString(results.At(0).Type()), typeString(svc.typ)))
+	}
+
+	c := &constructor{
+		fn:           fn,
+		ref:          fn.Name(),
+		returnsError: results.Len() == 2,
+		identical:    types.Identical(results.At(0).Type(), svc.typ),
+	}
+
+	if fn.Pkg() != pkg.Types {
+		c.ref = fn.Pkg().Name() + "." + fn.Name()
+	}
+
+	params := sig.Params()
+
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+
+		p, err := resolveConstructorParam(param, locator, svc, services)
+		if err != nil {
+			return nil, fail(param.Pos(), fmt.Sprintf("parameter %s: %s", paramString(param), err))
+		}
+
+		if p.kind == paramService {
+			p.name = paramVariable(param, i, fn.Pkg().Name())
+		}
+
+		c.params = append(c.params, p)
+	}
+
+	return c, nil
+}
+
+// lookupConstructor finds a function declared in pkg (eg. NewMailer)
+// or in a package imported by pkg (eg. github.com/acme/mail.NewMailer).
+func lookupConstructor(pkg *packages.Package, name string) (*types.Func, error) {
+	scope := pkg.Types.Scope()
+	funcName := name
+
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		importPath := name[:i]
+		funcName = name[i+1:]
+
+		imported := findImport(pkg, importPath)
+		if imported == nil {
+			return nil, fmt.Errorf("package %s is not imported by %s", importPath, pkg.PkgPath)
+		}
+
+		scope = imported.Types.Scope()
+	}
+
+	fn, ok := scope.Lookup(funcName).(*types.Func)
+	if !ok {
+		return nil, errors.New("function not found")
+	}
+
+	if fn.Pkg() != pkg.Types && !fn.Exported() {
+		return nil, errors.New("function is not exported")
+	}
+
+	return fn, nil
+}
+
+// findImport returns the package imported (directly or indirectly) by pkg with the given import path.
+func findImport(pkg *packages.Package, importPath string) *packages.Package {
+	seen := make(map[*packages.Package]bool)
+	queue := []*packages.Package{pkg}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if imported, ok := p.Imports[importPath]; ok {
+			return imported
+		}
+
+		for _, imported := range p.Imports {
+			if !seen[imported] {
+				seen[imported] = true
+				queue = append(queue, imported)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveConstructorParam determines how a parameter of the constructor of svc is resolved:
+//
+//   - context.Context parameters receive the context of the resolution
+//   - service locator interface parameters receive the service locator
+//   - a (name string) parameter receives the name of a named service
+//   - other parameters are resolved from the (unnamed) service of the same type
+func resolveConstructorParam(param *types.Var, locator types.Type, svc serviceDefinition, services []serviceDefinition) (constructorParam, error) {
+	typ := param.Type()
+
+	if named, ok := unalias(typ).(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context" {
+		return constructorParam{kind: paramContext}, nil
+	}
+
+	if types.Identical(typ, locator) {
+		return constructorParam{kind: paramLocator}, nil
+	}
+
+	if svc.named && param.Name() == "name" && types.Identical(typ, types.Typ[types.String]) {
+		return constructorParam{kind: paramName}, nil
+	}
+
+	var candidates []serviceDefinition
+	for _, candidate := range services {
+		if types.Identical(typ, candidate.typ) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return constructorParam{}, errors.New("no service of this type is declared")
+
+	case 1:
+	default:
+		names := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			names = append(names, candidate.name)
+		}
+
+		return constructorParam{}, fmt.Errorf("provided by multiple services: %s", strings.Join(names, ", "))
+	}
+
+	dependency := candidates[0]
+
+	if dependency.name == svc.name {
+		return constructorParam{}, errors.New("the service cannot depend on itself")
+	}
+
+	if dependency.named {
+		return constructorParam{}, fmt.Errorf("%s is a named service: it cannot be resolved without a name", dependency.name)
+	}
+
+	return constructorParam{kind: paramService, service: dependency.name}, nil
+}
+
+// reservedParamVariables are used by the generated constructor factories.
+var reservedParamVariables = map[string]bool{
+	"ctx":            true,
+	"name":           true,
+	"serviceLocator": true,
+	"err":            true,
+	"zero":           true,
+	"instance":       true,
+}
+
+// paramVariable names the variable holding the i-th parameter of a constructor declared in the package pkgName.
+// Parameter names are used unless they would clash with other identifiers of the generated code.
+func paramVariable(param *types.Var, i int, pkgName string) string {
+	name := param.Name()
+
+	if !token.IsIdentifier(name) || name == "_" || reservedParamVariables[name] || name == pkgName {
+		return fmt.Sprintf("arg%d", i)
+	}
+
+	return name
+}
+
+func paramString(param *types.Var) string {
+	if param.Name() == "" {
+		return typeString(param.Type())
+	}
+
+	return param.Name() + " " + typeString(param.Type())
+}
+
+// generateConstructors generates the factories calling the constructors of services.
+func generateConstructors(f *jen.File, cfg config, services []serviceDefinition) {
+	for _, service := range services {
+		c := service.constructor
+		if c == nil {
+			continue
+		}
+
+		f.Line()
+
+		f.Commentf("construct%s constructs {%s} with {%s}, resolving its parameters from the {%s}.", service.name, service.name, c.ref, cfg.interfaceName)
+		f.Func().Id("construct"+service.name).
+			ParamsFunc(func(g *jen.Group) {
+				g.Id("ctx").Qual("context", "Context")
+				ifNamed(service.named, g, jen.Id("name").String())
+				g.Id("serviceLocator").Id(cfg.interfaceName)
+			}).
+			Params(service.typeCode(), jen.Error()).
+			BlockFunc(func(g *jen.Group) {
+				needsZero := c.returnsError && !c.identical
+				for _, p := range c.params {
+					needsZero = needsZero || p.kind == paramService
+				}
+
+				if needsZero {
+					g.Var().Id("zero").Add(service.typeCode())
+					g.Line()
+				}
+
+				for _, p := range c.params {
+					if p.kind != paramService {
+						continue
+					}
+
+					g.List(jen.Id(p.name), jen.Err()).Op(":=").Id("serviceLocator").Dot("Get" + p.service).Call()
+					g.If(jen.Err().Op("!=").Nil()).Block(
+						jen.Return(jen.Id("zero"), jen.Err()),
+					)
+					g.Line()
+				}
+
+				call := jen.Qual(c.fn.Pkg().Path(), c.fn.Name()).CallFunc(func(g *jen.Group) {
+					for _, p := range c.params {
+						switch p.kind {
+						case paramContext:
+							g.Id("ctx")
+						case paramLocator:
+							g.Id("serviceLocator")
+						case paramName:
+							g.Id("name")
+						default:
+							g.Id(p.name)
+						}
+					}
+				})
+
+				switch {
+				case !c.returnsError:
+					g.Return(call, jen.Nil())
+				case c.identical:
+					g.Return(call)
+				default:
+					g.List(jen.Id("instance"), jen.Err()).Op(":=").Add(call)
+					g.If(jen.Err().Op("!=").Nil()).Block(
+						jen.Return(jen.Id("zero"), jen.Err()),
+					)
+					g.Line()
+					g.Return(jen.Id("instance"), jen.Nil())
+				}
+			})
+	}
+}
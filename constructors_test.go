@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveConstructor(t *testing.T) {
+	pkg := loadTestPackage(t, "constructors")
+
+	services, diagnostics, err := parseServiceDefinitions(pkg, "ServiceLocator")
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	locator := pkg.Types.Scope().Lookup("ServiceLocator").Type()
+
+	tests := []struct {
+		service string
+		err     string
+	}{
+		{service: "Mailer"},
+		{service: "ReadCloser"},
+		{service: "Worker"},
+		{service: "Missing", err: "constructor NewMissing of Missing: function not found"},
+		{service: "Request", err: "constructor net/http.NewRequest of Request: package net/http is not imported by github.com/sagikazarmark/go-service-locator/testdata/constructors"},
+		{service: "Generic", err: "constructor NewGeneric of Generic: must not be generic"},
+		{service: "Variadic", err: "constructor NewVariadic of Variadic: must not be variadic"},
+		{service: "Nothing", err: "constructor NewNothing of Nothing: must return the service (and optionally an error)"},
+		{service: "WrongType", err: "constructor NewConfig of WrongType: returns *constructors.Config, which cannot be used as *constructors.WrongType"},
+		{service: "Timeout", err: "constructor NewTimeout of Timeout: parameter timeout time.Duration: no service of this type is declared"},
+		{service: "Router", err: "constructor NewRouter of Router: parameter handlers []constructors.Handler: provided by multiple services: Handlers, Middlewares"},
+		{service: "Self", err: "constructor NewSelf of Self: parameter self *constructors.Self: the service cannot depend on itself"},
+		{service: "Scheduler", err: "constructor NewScheduler of Scheduler: parameter job *constructors.Job: Job is a named service: it cannot be resolved without a name"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.service, func(t *testing.T) {
+			var svc serviceDefinition
+			for _, s := range services {
+				if s.name == test.service {
+					svc = s
+				}
+			}
+			require.NotEmpty(t, svc.name)
+
+			c, err := resolveConstructor(pkg, locator, svc, services)
+
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, svc.constructorName, c.ref)
+		})
+	}
+}
+
+func TestResolveConstructorParams(t *testing.T) {
+	pkg := loadTestPackage(t, "constructors")
+
+	services, _, err := parseServiceDefinitions(pkg, "ServiceLocator")
+	require.NoError(t, err)
+
+	locator := pkg.Types.Scope().Lookup("ServiceLocator").Type()
+
+	for _, svc := range services {
+		switch svc.name {
+		case "Mailer":
+			c, err := resolveConstructor(pkg, locator, svc, services)
+			require.NoError(t, err)
+
+			assert.Equal(t, []constructorParam{
+				{kind: paramContext},
+				{kind: paramService, name: "config", service: "Config"},
+				{kind: paramLocator},
+			}, c.params)
+			assert.True(t, c.returnsError)
+			assert.True(t, c.identical)
+
+		case "Worker":
+			c, err := resolveConstructor(pkg, locator, svc, services)
+			require.NoError(t, err)
+
+			assert.Equal(t, []constructorParam{
+				{kind: paramName},
+				{kind: paramService, name: "mailer", service: "Mailer"},
+			}, c.params)
+			assert.False(t, c.returnsError)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// directivePrefix marks generator directives in the doc comment of service locator interface methods:
+//
+//	type ServiceLocator interface {
+//		//servicelocator:transient
+//		GetBuffer() (*bytes.Buffer, error)
+//
+//		//servicelocator:required primary replica
+//		GetDatabase(name string) (*sql.DB, error)
+//
+//		//servicelocator:constructor NewMailer
+//		GetMailer() (*Mailer, error)
+//	}
+const directivePrefix = "//servicelocator:"
+
+type directive struct {
+	name string
+	args []string
+}
+
+func (d directive) String() string {
+	return strings.TrimSpace(directivePrefix + d.name + " " + strings.Join(d.args, " "))
+}
+
+// parseDirectives returns the generator directives found in doc.
+func parseDirectives(doc *ast.CommentGroup) []directive {
+	if doc == nil {
+		return nil
+	}
+
+	var directives []directive
+
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(c.Text, directivePrefix))
+		if len(fields) == 0 {
+			continue
+		}
+
+		directives = append(directives, directive{
+			name: fields[0],
+			args: fields[1:],
+		})
+	}
+
+	return directives
+}
+
+// methodDocs returns the doc comments of the methods declared in iface indexed by method name.
+func methodDocs(iface *ast.InterfaceType) map[string]*ast.CommentGroup {
+	docs := make(map[string]*ast.CommentGroup)
+
+	for _, field := range iface.Methods.List {
+		for _, name := range field.Names {
+			docs[name.Name] = field.Doc
+		}
+	}
+
+	return docs
+}
+
+// scopeDirectives maps directives to the service scope they select.
+var scopeDirectives = map[string]serviceScope{
+	"transient": scopeTransient,
+	"scoped":    scopeScoped,
+}
+
+// applyDirectives configures svc according to the directives of its interface method.
+func applyDirectives(svc *serviceDefinition, directives []directive) error {
+	scoped := false
+
+	for _, d := range directives {
+		if scope, ok := scopeDirectives[d.name]; ok {
+			if len(d.args) > 0 {
+				return fmt.Errorf("directive %s does not accept arguments", d)
+			}
+
+			if scoped {
+				return fmt.Errorf("directive %s conflicts with a previous scope directive", d)
+			}
+
+			svc.scope = scope
+			scoped = true
+
+			continue
+		}
+
+		if d.name == "required" {
+			if !svc.named {
+				return fmt.Errorf("directive %s only applies to named services", d)
+			}
+
+			if len(d.args) == 0 {
+				return fmt.Errorf("directive %s requires service names", d)
+			}
+
+			svc.required = append(svc.required, d.args...)
+
+			continue
+		}
+
+		if d.name == "constructor" {
+			if len(d.args) != 1 {
+				return fmt.Errorf("directive %s requires exactly one constructor function", d)
+			}
+
+			if svc.constructorName != "" {
+				return fmt.Errorf("directive %s conflicts with a previous constructor directive", d)
+			}
+
+			svc.constructorName = d.args[0]
+
+			continue
+		}
+
+		return fmt.Errorf("unknown directive %s", d)
+	}
+
+	return nil
+}
@@ -17,7 +17,7 @@
           default = pkgs.mkShell {
             buildInputs = with pkgs; [
               go-task
-              go_1_20
+              go_1_22
             ];
           };
 
@@ -1,18 +1,17 @@
 module github.com/sagikazarmark/go-service-locator
 
-go 1.20
+go 1.22.0
 
 require (
 	github.com/dave/jennifer v1.6.0
 	github.com/stretchr/testify v1.8.2
-	golang.org/x/exp v0.0.0-20230811145659-89c5cff77bcb
+	golang.org/x/tools v0.30.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/mod v0.12.0 // indirect
-	golang.org/x/sys v0.11.0 // indirect
-	golang.org/x/tools v0.12.0 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/sagikazarmark/go-service-locator/analyzer"
+)
+
+// graphFormats lists the formats the graph subcommand can render.
+var graphFormats = []string{"dot", "mermaid"}
+
+// graphConfig holds the settings of the graph subcommand parsed from the command line.
+type graphConfig struct {
+	interfaceName string
+	registryName  string
+	generated     string
+	tags          string
+	format        string
+	patterns      []string
+}
+
+// graphMain runs the graph subcommand with the given arguments (without the subcommand name).
+func graphMain(args []string) {
+	cfg, err := parseGraphConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	err = runGraph(context.Background(), cfg, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error rendering the graph:", err)
+		os.Exit(1)
+	}
+}
+
+// parseGraphConfig parses the command line arguments of the graph subcommand.
+// Errors are reported to stderr along with the usage message.
+func parseGraphConfig(args []string) (graphConfig, error) {
+	cfg := graphConfig{}
+
+	flags := flag.NewFlagSet("go-service-locator graph", flag.ContinueOnError)
+	flags.StringVar(&cfg.interfaceName, "interface", defaultInterfaceName, "name of the service locator interface declaring the services")
+	flags.StringVar(&cfg.registryName, "registry-name", defaultRegistryName, "name of the generated registry type (factories registered in it are analyzed for dependencies)")
+	flags.StringVar(&cfg.generated, "generated", defaultOutput, "name of the generated file (type errors in it are ignored)")
+	flags.StringVar(&cfg.tags, "tags", "", "comma-separated list of build tags to apply when loading packages")
+	flags.StringVar(&cfg.format, "format", "dot", "output format ("+strings.Join(graphFormats, " or ")+")")
+
+	flags.Usage = func() {
+		w := flags.Output()
+
+		fmt.Fprintf(w, "Usage: %s [flags] [packages]\n\n", flags.Name())
+		fmt.Fprintln(w, "Renders the services declared by the service locator interface found in the packages (default: the current directory).")
+		fmt.Fprintln(w, "Dependencies between factories registered (as function literals) in the packages are rendered as edges.")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.patterns = flags.Args()
+	if len(cfg.patterns) == 0 {
+		cfg.patterns = []string{"."}
+	}
+
+	err = validateGraphConfig(cfg)
+	if err != nil {
+		fmt.Fprintln(flags.Output(), err)
+		flags.Usage()
+
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func validateGraphConfig(cfg graphConfig) error {
+	if !token.IsIdentifier(cfg.interfaceName) {
+		return fmt.Errorf("invalid interface name: %q", cfg.interfaceName)
+	}
+
+	if !token.IsIdentifier(cfg.registryName) {
+		return fmt.Errorf("invalid registry name: %q", cfg.registryName)
+	}
+
+	if cfg.generated == "" {
+		return errors.New("generated file name must not be empty")
+	}
+
+	for _, format := range graphFormats {
+		if cfg.format == format {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid format: %q", cfg.format)
+}
+
+func runGraph(ctx context.Context, cfg graphConfig, w io.Writer) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+
+	pkgs, errs := load(ctx, wd, env, cfg.tags, cfg.patterns, cfg.generated)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Other packages are only analyzed for factory registrations
+	var pkg *packages.Package
+	for _, p := range pkgs {
+		if _, ok := p.Types.Scope().Lookup(cfg.interfaceName).(*types.TypeName); !ok {
+			continue
+		}
+
+		if pkg != nil {
+			return fmt.Errorf("interface %s declared by multiple packages: %s, %s", cfg.interfaceName, pkg.PkgPath, p.PkgPath)
+		}
+
+		pkg = p
+	}
+
+	if pkg == nil {
+		return fmt.Errorf("interface %s not found", cfg.interfaceName)
+	}
+
+	services, diagnostics, err := parseServiceDefinitions(pkg, cfg.interfaceName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pkg.PkgPath, err)
+	}
+
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+
+	err = resolveConstructors(pkg, cfg.interfaceName, services)
+	if err != nil {
+		return err
+	}
+
+	graph := serviceGraph{
+		services:     services,
+		dependencies: staticDependencies(pkgs, pkg.PkgPath, cfg.registryName, services),
+	}
+
+	var out string
+	switch cfg.format {
+	case "mermaid":
+		out = graph.mermaid()
+	default:
+		out = graph.dot()
+	}
+
+	_, err = io.WriteString(w, out)
+
+	return err
+}
+
+// staticDependencies collects the dependencies of service constructors
+// and the dependencies between the factories registered in the registry generated in the package pkgPath
+// (see [analyzer.Dependencies]).
+//
+// Named services are identified by their type only: the graph describes services, not instances.
+func staticDependencies(pkgs []*packages.Package, pkgPath string, registryName string, services []serviceDefinition) [][2]string {
+	declared := make(map[string]bool, len(services))
+	for _, svc := range services {
+		declared[svc.name] = true
+	}
+
+	seen := make(map[[2]string]bool)
+	var dependencies [][2]string
+
+	add := func(edge [2]string) {
+		if !declared[edge[0]] || !declared[edge[1]] || seen[edge] {
+			return
+		}
+
+		seen[edge] = true
+		dependencies = append(dependencies, edge)
+	}
+
+	for _, svc := range services {
+		if svc.constructor == nil {
+			continue
+		}
+
+		for _, param := range svc.constructor.params {
+			if param.kind == paramService {
+				add([2]string{svc.name, param.service})
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		for _, dep := range analyzer.Dependencies(pkg.TypesInfo, pkg.Syntax) {
+			if isRegistryType(dep.Registry, pkgPath, registryName) {
+				add([2]string{dep.From.Type, dep.To.Type})
+			}
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i][0] != dependencies[j][0] {
+			return dependencies[i][0] < dependencies[j][0]
+		}
+
+		return dependencies[i][1] < dependencies[j][1]
+	})
+
+	return dependencies
+}
+
+// isRegistryType checks whether t is (a pointer to) the registry type called name declared in the package pkgPath.
+func isRegistryType(t types.Type, pkgPath string, name string) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	return named.Obj().Pkg().Path() == pkgPath && named.Obj().Name() == name
+}
+
+// serviceGraph describes the services declared by a service locator interface
+// and the dependencies between them (as pairs of service names).
+type serviceGraph struct {
+	services     []serviceDefinition
+	dependencies [][2]string
+}
+
+// label describes svc: its name, type, import path (if any) and scope.
+func (serviceGraph) label(svc serviceDefinition) []string {
+	lines := []string{svc.name, svc.typeString()}
+
+	if svc.importPath != "" {
+		lines = append(lines, svc.importPath)
+	}
+
+	attributes := svc.scope.String()
+	if svc.named {
+		attributes += ", named"
+	}
+
+	return append(lines, attributes)
+}
+
+// dot renders the graph in the Graphviz DOT language.
+func (g serviceGraph) dot() string {
+	var b strings.Builder
+
+	b.WriteString("digraph services {\n")
+
+	for _, svc := range g.services {
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", svc.name, strings.Join(g.label(svc), "\n"))
+	}
+
+	for _, dep := range g.dependencies {
+		fmt.Fprintf(&b, "\t%q -> %q;\n", dep[0], dep[1])
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// mermaid renders the graph as a Mermaid flowchart.
+func (g serviceGraph) mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("flowchart TD\n")
+
+	// Node IDs follow the runtime graph (see the generated DependencyGraph.Mermaid)
+	ids := make(map[string]string, len(g.services))
+
+	for i, svc := range g.services {
+		ids[svc.name] = fmt.Sprintf("s%d", i)
+
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", ids[svc.name], strings.ReplaceAll(strings.Join(g.label(svc), "<br/>"), "\"", "#quot;"))
+	}
+
+	for _, dep := range g.dependencies {
+		fmt.Fprintf(&b, "\t%s --> %s\n", ids[dep[0]], ids[dep[1]])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGraph(t *testing.T) {
+	cfg, err := parseGraphConfig([]string{"-format", "mermaid", "./testdata/graph"})
+	require.NoError(t, err)
+
+	var out strings.Builder
+
+	err = runGraph(context.Background(), cfg, &out)
+	require.NoError(t, err)
+
+	const expected = `flowchart TD
+	s0["Config<br/>*graph.Config<br/>github.com/sagikazarmark/go-service-locator/testdata/graph<br/>singleton"]
+	s1["DB<br/>*graph.DB<br/>github.com/sagikazarmark/go-service-locator/testdata/graph<br/>singleton"]
+	s2["Mailer<br/>*graph.Mailer<br/>github.com/sagikazarmark/go-service-locator/testdata/graph<br/>singleton"]
+	s3["Server<br/>*graph.Server<br/>github.com/sagikazarmark/go-service-locator/testdata/graph<br/>singleton"]
+	s1 --> s0
+	s2 --> s0
+	s3 --> s1
+	s3 --> s2
+`
+
+	assert.Equal(t, expected, out.String())
+}
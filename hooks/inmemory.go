@@ -0,0 +1,115 @@
+package hooks
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryTracer records spans in memory (eg. for testing).
+//
+// InMemoryTracer serves the same purpose as the in-memory exporter of the OpenTelemetry SDK.
+type InMemoryTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// inMemorySpanKey is the context key of the span started by an [InMemoryTracer].
+type inMemorySpanKey struct{}
+
+// NewInMemoryTracer returns a new [InMemoryTracer].
+func NewInMemoryTracer() *InMemoryTracer {
+	return &InMemoryTracer{}
+}
+
+// RecordedSpan is a span recorded by an [InMemoryTracer].
+//
+// Spans are identified by their (1-based) index in the order they were started.
+// ParentID identifies the span in the context passed to Start (0 for root spans).
+type RecordedSpan struct {
+	ID                int
+	ParentID          int
+	Name              string
+	StartTime         time.Time
+	EndTime           time.Time
+	Attributes        []Attribute
+	Errors            []error
+	StatusCode        StatusCode
+	StatusDescription string
+
+	ended bool
+}
+
+// Start implements Tracer.
+func (t *InMemoryTracer) Start(ctx context.Context, spanName string, startTime time.Time, attributes ...Attribute) (context.Context, Span) {
+	span := &RecordedSpan{
+		Name:       spanName,
+		StartTime:  startTime,
+		Attributes: append([]Attribute(nil), attributes...),
+	}
+
+	if parent, ok := ctx.Value(inMemorySpanKey{}).(inMemorySpan); ok && parent.tracer == t {
+		span.ParentID = parent.span.ID
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	span.ID = len(t.spans)
+	t.mu.Unlock()
+
+	s := inMemorySpan{tracer: t, span: span}
+
+	return context.WithValue(ctx, inMemorySpanKey{}, s), s
+}
+
+// Spans returns the ended spans in the order they were started.
+func (t *InMemoryTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var spans []RecordedSpan
+
+	for _, span := range t.spans {
+		if span.ended {
+			spans = append(spans, *span)
+		}
+	}
+
+	return spans
+}
+
+// Reset discards the recorded spans.
+func (t *InMemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+type inMemorySpan struct {
+	tracer *InMemoryTracer
+	span   *RecordedSpan
+}
+
+func (s inMemorySpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+func (s inMemorySpan) SetStatus(code StatusCode, description string) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.span.StatusCode = code
+	s.span.StatusDescription = description
+}
+
+func (s inMemorySpan) End(endTime time.Time) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.span.EndTime = endTime
+	s.span.ended = true
+}
@@ -0,0 +1,74 @@
+package hooks
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Logger logs how services are resolved.
+//
+// Resolutions are logged at debug level, failed constructions and circular dependencies are logged at error level.
+type Logger struct {
+	logger *slog.Logger
+}
+
+// NewLogger returns hooks logging with logger (or [slog.Default] if logger is nil).
+func NewLogger(logger *slog.Logger) *Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Logger{
+		logger: logger,
+	}
+}
+
+// ResolveStart implements ResolutionHooks.
+func (l *Logger) ResolveStart(ctx context.Context, serviceType, serviceName string, path []string) {
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "resolving service", logAttrs(serviceType, serviceName, path)...)
+}
+
+// CacheHit implements ResolutionHooks.
+func (l *Logger) CacheHit(ctx context.Context, serviceType, serviceName string, path []string) {
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "service resolved from cache", logAttrs(serviceType, serviceName, path)...)
+}
+
+// FactoryStart implements ResolutionHooks.
+func (l *Logger) FactoryStart(ctx context.Context, serviceType, serviceName string, path []string) context.Context {
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "constructing service", logAttrs(serviceType, serviceName, path)...)
+
+	return ctx
+}
+
+// FactoryEnd implements ResolutionHooks.
+func (l *Logger) FactoryEnd(ctx context.Context, serviceType, serviceName string, path []string, duration time.Duration, err error) {
+	attrs := append(logAttrs(serviceType, serviceName, path), slog.Duration("duration", duration))
+
+	if err != nil {
+		l.logger.LogAttrs(ctx, slog.LevelError, "constructing service failed", append(attrs, slog.Any("error", err))...)
+
+		return
+	}
+
+	l.logger.LogAttrs(ctx, slog.LevelDebug, "service constructed", attrs...)
+}
+
+// CircularDependency implements ResolutionHooks.
+func (l *Logger) CircularDependency(ctx context.Context, serviceType, serviceName string, path []string) {
+	l.logger.LogAttrs(ctx, slog.LevelError, "circular dependency detected", logAttrs(serviceType, serviceName, path)...)
+}
+
+func logAttrs(serviceType, serviceName string, path []string) []slog.Attr {
+	attrs := []slog.Attr{slog.String("service_type", serviceType)}
+
+	if serviceName != "" {
+		attrs = append(attrs, slog.String("service_name", serviceName))
+	}
+
+	if len(path) > 0 {
+		attrs = append(attrs, slog.Any("path", path))
+	}
+
+	return attrs
+}
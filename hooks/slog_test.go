@@ -0,0 +1,53 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := NewLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	ctx := context.Background()
+
+	logger.ResolveStart(ctx, "ServiceA", "", nil)
+	logger.FactoryEnd(ctx, "ServiceB", "service", []string{"ServiceA", "ServiceB:service"}, time.Second, errors.New("no service"))
+
+	decoder := json.NewDecoder(&buf)
+
+	var record map[string]any
+
+	require.NoError(t, decoder.Decode(&record))
+	delete(record, "time")
+
+	assert.Equal(t, map[string]any{
+		"level":        "DEBUG",
+		"msg":          "resolving service",
+		"service_type": "ServiceA",
+	}, record)
+
+	record = nil
+
+	require.NoError(t, decoder.Decode(&record))
+	delete(record, "time")
+
+	assert.Equal(t, map[string]any{
+		"level":        "ERROR",
+		"msg":          "constructing service failed",
+		"service_type": "ServiceB",
+		"service_name": "service",
+		"path":         []any{"ServiceA", "ServiceB:service"},
+		"duration":     float64(time.Second),
+		"error":        "no service",
+	}, record)
+}
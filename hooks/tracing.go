@@ -0,0 +1,138 @@
+// Package hooks provides ResolutionHooks for service registries generated by go-service-locator.
+//
+// Generated ResolutionHooks interfaces only refer to standard library types,
+// so the hooks in this package can be used with any generated registry.
+package hooks
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer starts spans.
+//
+// Tracer mirrors the tracer of the OpenTelemetry API (without depending on it):
+// an OpenTelemetry tracer can be adapted with a few lines of code.
+type Tracer interface {
+	// Start starts a span at startTime (as a child of the span in ctx, if any)
+	// and returns a context carrying the new span.
+	Start(ctx context.Context, spanName string, startTime time.Time, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span mirrors the span of the OpenTelemetry API.
+type Span interface {
+	// RecordError records an error as an event of the span.
+	RecordError(err error)
+
+	// SetStatus sets the status of the span.
+	SetStatus(code StatusCode, description string)
+
+	// End ends the span at endTime.
+	End(endTime time.Time)
+}
+
+// Attribute is a key-value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// StatusCode is the status of a span.
+//
+// The values match the status codes of OpenTelemetry.
+type StatusCode int
+
+const (
+	// StatusUnset is the default status.
+	StatusUnset StatusCode = iota
+
+	// StatusError marks a failed operation.
+	StatusError
+
+	// StatusOK marks an operation explicitly validated as successful.
+	StatusOK
+)
+
+// Attribute keys set on spans.
+const (
+	ServiceTypeKey = "servicelocator.service.type"
+	ServiceNameKey = "servicelocator.service.name"
+	PathKey        = "servicelocator.path"
+)
+
+// Tracing records spans for service constructions and circular dependencies.
+//
+// The span of a construction is passed to the factory (through the context returned by FactoryStart),
+// so spans of services are nested into the spans of the services depending on them.
+type Tracing struct {
+	tracer Tracer
+}
+
+// NewTracing returns hooks recording spans with tracer.
+func NewTracing(tracer Tracer) *Tracing {
+	return &Tracing{
+		tracer: tracer,
+	}
+}
+
+// ResolveStart implements ResolutionHooks.
+func (t *Tracing) ResolveStart(_ context.Context, _, _ string, _ []string) {}
+
+// CacheHit implements ResolutionHooks.
+func (t *Tracing) CacheHit(_ context.Context, _, _ string, _ []string) {}
+
+// FactoryStart implements ResolutionHooks.
+func (t *Tracing) FactoryStart(ctx context.Context, serviceType, serviceName string, path []string) context.Context {
+	ctx, span := t.tracer.Start(ctx, "construct "+serviceID(serviceType, serviceName), time.Now(), attributes(serviceType, serviceName, path)...)
+
+	return context.WithValue(ctx, constructionSpanKey{}, span)
+}
+
+// FactoryEnd implements ResolutionHooks.
+func (t *Tracing) FactoryEnd(ctx context.Context, _, _ string, _ []string, _ time.Duration, err error) {
+	span, ok := ctx.Value(constructionSpanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(StatusError, err.Error())
+	}
+
+	span.End(time.Now())
+}
+
+// CircularDependency implements ResolutionHooks.
+func (t *Tracing) CircularDependency(ctx context.Context, serviceType, serviceName string, path []string) {
+	now := time.Now()
+
+	_, span := t.tracer.Start(ctx, "circular dependency "+serviceID(serviceType, serviceName), now, attributes(serviceType, serviceName, path)...)
+	span.SetStatus(StatusError, "circular dependency")
+	span.End(now)
+}
+
+// constructionSpanKey is the context key of the span started by FactoryStart.
+type constructionSpanKey struct{}
+
+func attributes(serviceType, serviceName string, path []string) []Attribute {
+	attributes := []Attribute{{Key: ServiceTypeKey, Value: serviceType}}
+
+	if serviceName != "" {
+		attributes = append(attributes, Attribute{Key: ServiceNameKey, Value: serviceName})
+	}
+
+	if len(path) > 0 {
+		attributes = append(attributes, Attribute{Key: PathKey, Value: path})
+	}
+
+	return attributes
+}
+
+func serviceID(serviceType, serviceName string) string {
+	if serviceName == "" {
+		return serviceType
+	}
+
+	return serviceType + ":" + serviceName
+}
@@ -3,48 +3,244 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
 
 	"github.com/dave/jennifer/jen"
 	"golang.org/x/tools/go/packages"
 )
 
+const (
+	defaultInterfaceName = "ServiceLocator"
+	defaultRegistryName  = "ServiceRegistry"
+	defaultOutput        = "service_locator_gen.go"
+)
+
+// config holds the generator settings parsed from the command line.
+type config struct {
+	interfaceName string
+	registryName  string
+	output        string
+	tags          string
+	strict        bool
+	patterns      []string
+}
+
 func main() {
-	pkg := "."
+	if len(os.Args) > 1 && os.Args[1] == "graph" {
+		graphMain(os.Args[2:])
+
+		return
+	}
+
+	cfg, err := parseConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	err = run(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error generating the code:", err)
+		os.Exit(1)
+	}
+}
+
+// parseConfig parses the command line arguments (without the program name).
+// Errors are reported to stderr along with the usage message.
+func parseConfig(args []string) (config, error) {
+	cfg := config{}
+
+	flags := flag.NewFlagSet("go-service-locator", flag.ContinueOnError)
+	flags.StringVar(&cfg.interfaceName, "interface", defaultInterfaceName, "name of the service locator interface to generate a registry for")
+	flags.StringVar(&cfg.output, "output", defaultOutput, "output file name (relative paths are resolved against the package directory)")
+	flags.StringVar(&cfg.registryName, "registry-name", defaultRegistryName, "name of the generated registry type")
+	flags.StringVar(&cfg.tags, "tags", "", "comma-separated list of build tags to apply when loading packages")
+	flags.BoolVar(&cfg.strict, "strict", false, "fail instead of skipping interface methods that do not describe a service")
+
+	flags.Usage = func() {
+		w := flags.Output()
+
+		fmt.Fprintf(w, "Usage: %s [flags] [packages]\n", flags.Name())
+		fmt.Fprintf(w, "       %s graph [flags] [packages]\n\n", flags.Name())
+		fmt.Fprintln(w, "Generates a service registry for the service locator interface found in each package (default: the current directory).")
+		fmt.Fprintln(w, "The graph subcommand renders the declared services instead (see graph -help).")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.patterns = flags.Args()
+	if len(cfg.patterns) == 0 {
+		cfg.patterns = []string{"."}
+	}
+
+	err = validateConfig(cfg)
+	if err != nil {
+		fmt.Fprintln(flags.Output(), err)
+		flags.Usage()
+
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// scopeName returns the name of the generated scope type.
+func (c config) scopeName() string {
+	return strings.TrimSuffix(c.registryName, "Registry") + "Scope"
+}
+
+func validateConfig(cfg config) error {
+	if !token.IsIdentifier(cfg.interfaceName) {
+		return fmt.Errorf("invalid interface name: %q", cfg.interfaceName)
+	}
+
+	if !token.IsIdentifier(cfg.registryName) {
+		return fmt.Errorf("invalid registry name: %q", cfg.registryName)
+	}
 
-	if len(os.Args) > 0 {
-		pkg = os.Args[1]
+	if cfg.interfaceName == cfg.registryName {
+		return fmt.Errorf("registry name must differ from the interface name: %q", cfg.registryName)
 	}
 
+	if cfg.interfaceName == cfg.scopeName() {
+		return fmt.Errorf("scope name (derived from the registry name) must differ from the interface name: %q", cfg.scopeName())
+	}
+
+	if cfg.output == "" {
+		return errors.New("output file name must not be empty")
+	}
+
+	if filepath.IsAbs(cfg.output) && len(cfg.patterns) > 1 {
+		return errors.New("absolute output path cannot be used with multiple package patterns")
+	}
+
+	return nil
+}
+
+func run(ctx context.Context, cfg config) error {
 	wd, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	env := os.Environ()
 
-	pkgs, errs := load(context.Background(), wd, env, []string{pkg})
+	pkgs, errs := load(ctx, wd, env, cfg.tags, cfg.patterns, cfg.output)
 	if len(errs) > 0 {
-		panic(errors.Join(errs...))
+		return errors.Join(errs...)
 	}
 
 	if len(pkgs) == 0 {
-		fmt.Fprintln(os.Stderr, "no packages found")
+		return errors.New("no packages found")
+	}
+
+	for _, pkg := range pkgs {
+		err := generate(cfg, pkg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.PkgPath, err)
+		}
+	}
+
+	return nil
+}
+
+func generate(cfg config, pkg *packages.Package) error {
+	outDir, err := detectOutputDir(pkg.GoFiles)
+	if err != nil {
+		return err
+	}
+
+	serviceDefinitions, diagnostics, err := parseServiceDefinitions(pkg, cfg.interfaceName)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diagnostics {
+		fmt.Fprintln(os.Stderr, d)
+	}
+
+	if cfg.strict && len(diagnostics) > 0 {
+		return fmt.Errorf("%d method(s) of %s rejected in strict mode", len(diagnostics), cfg.interfaceName)
+	}
+
+	err = resolveConstructors(pkg, cfg.interfaceName, serviceDefinitions)
+	if err != nil {
+		return err
+	}
+
+	f := jen.NewFilePath(pkg.PkgPath)
+	f.ImportName("sync", "sync")
+	f.ImportName("fmt", "fmt")
+	f.ImportName("strings", "strings")
+
+	// generateServiceLocator(f, serviceDefinitions)
+	generateGenericServiceFactory(f, cfg)
+	generateGenericNamedServiceFactory(f, cfg)
+	generateGenericContextServiceFactory(f, cfg)
+	generateGenericNamedContextServiceFactory(f, cfg)
+	generateGenericServiceDecorator(f, cfg)
+	generateNamedServiceFactoryPattern(f)
+	generateServiceRegistry(f, cfg, serviceDefinitions)
+	generateConstructors(f, cfg, serviceDefinitions)
+	generateServiceScope(f, cfg, serviceDefinitions)
+	generateServiceCall(f)
+	generateCloseInstances(f)
+	generateServiceLocationContext(f, cfg, serviceDefinitions)
+
+	err = generateSkippedMethods(f, cfg, serviceDefinitions, diagnostics)
+	if err != nil {
+		return err
+	}
+
+	generateResolutionHooks(f, cfg)
+	generateDependencyGraph(f)
+	generateDebugHandler(f, cfg)
+	generateCircularDependencyError(f)
+	generateMaxDepthExceededError(f)
+	generateServiceNotRegisteredError(f)
+	generateServiceAlreadyRegisteredError(f)
+	generateServiceConstructionError(f)
+	generateServiceLocatorError(f)
+
+	output := cfg.output
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(outDir, output)
 	}
 
-	outDir, err := detectOutputDir(pkgs[0].GoFiles)
+	file, err := os.Create(output)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer file.Close()
+
+	return f.Render(file)
+}
 
+// parseServiceDefinitions collects the services declared by the interface called interfaceName in pkg.
+// Methods that do not describe a service are reported as diagnostics.
+func parseServiceDefinitions(pkg *packages.Package, interfaceName string) ([]serviceDefinition, []diagnostic, error) {
 	var serviceDefinitions []serviceDefinition
+	var diagnostics []diagnostic
 
-	for _, f := range pkgs[0].Syntax {
+	found := false
+
+	for _, f := range pkg.Syntax {
 		for _, decl := range f.Decls {
 			gen, ok := decl.(*ast.GenDecl)
 			if !ok || gen.Tok != token.TYPE {
@@ -53,106 +249,179 @@ func main() {
 
 			for _, specs := range gen.Specs {
 				ts, ok := specs.(*ast.TypeSpec)
-				if !ok || ts.Name.String() != "ServiceLocator" {
+				if !ok || ts.Name.String() != interfaceName {
 					continue
 				}
 
 				iface, ok := ts.Type.(*ast.InterfaceType)
 				if !ok {
-					continue
+					return nil, nil, fmt.Errorf("%s is not an interface", interfaceName)
 				}
 
-				typ := pkgs[0].TypesInfo.TypeOf(iface).(*types.Interface)
+				found = true
+
+				typ := pkg.TypesInfo.TypeOf(iface).(*types.Interface)
+				qf := types.RelativeTo(pkg.Types)
+				docs := methodDocs(iface)
+
+				svcs := make([]serviceDefinition, typ.NumMethods())
+				errs := make([]error, typ.NumMethods())
+				named := make(map[string]serviceDefinition)
 
 				for i := 0; i < typ.NumMethods(); i++ {
 					method := typ.Method(i)
 
-					sig := method.Type().(*types.Signature)
-
-					params := sig.Params()
-					results := sig.Results()
+					svcs[i], errs[i] = parseServiceDefinition(method, qf)
+					if errs[i] == nil {
+						errs[i] = applyDirectives(&svcs[i], parseDirectives(docs[method.Name()]))
+					}
 
-					if results.Len() != 2 {
-						continue
+					if errs[i] == nil && svcs[i].named {
+						named[svcs[i].name] = svcs[i]
 					}
+				}
 
-					serviceName := results.At(0).Type().(*types.Named).Obj().Name()
+				for i := 0; i < typ.NumMethods(); i++ {
+					method := typ.Method(i)
 
-					if method.Name() != fmt.Sprintf("Get%s", serviceName) {
+					if isEnumerationMethod(method, named) {
 						continue
 					}
 
-					if results.At(1).Type().String() != "error" {
+					svc, err := svcs[i], errs[i]
+					if err != nil {
+						diagnostics = append(diagnostics, diagnostic{
+							pos:    pkg.Fset.Position(method.Pos()),
+							method: method.Name(),
+							reason: err.Error(),
+							sig:    method.Type().(*types.Signature),
+						})
+
 						continue
 					}
 
-					svc := serviceDefinition{
-						name:       serviceName,
-						importPath: results.At(0).Type().(*types.Named).Obj().Pkg().Path(),
-					}
+					serviceDefinitions = append(serviceDefinitions, svc)
+				}
+			}
+		}
+	}
 
-					if params.Len() > 1 {
-						continue
-					}
+	if !found {
+		return nil, nil, fmt.Errorf("interface %s not found", interfaceName)
+	}
 
-					if params.Len() == 1 {
-						param := params.At(0)
+	sort.Slice(diagnostics, func(i, j int) bool {
+		return diagnostics[i].pos.Offset < diagnostics[j].pos.Offset
+	})
 
-						if param.Name() != "name" || param.Type().String() != "string" {
-							continue
-						}
+	return serviceDefinitions, diagnostics, nil
+}
 
-						svc.named = true
-					}
+// isEnumerationMethod checks if method enumerates a named service declared by the interface:
+//
+//	ServiceNames() []string
+//	GetAllService() (map[string]Service, error)
+//
+// The generated registry implements these methods, so declaring them in the interface exposes them to factories.
+func isEnumerationMethod(method *types.Func, named map[string]serviceDefinition) bool {
+	sig := method.Type().(*types.Signature)
 
-					serviceDefinitions = append(serviceDefinitions, svc)
-				}
-			}
+	if sig.Params().Len() != 0 {
+		return false
+	}
+
+	results := sig.Results()
+
+	if svc, ok := named[strings.TrimSuffix(method.Name(), "Names")]; ok && svc.name+"Names" == method.Name() {
+		return results.Len() == 1 && types.Identical(results.At(0).Type(), types.NewSlice(types.Typ[types.String]))
+	}
+
+	if svc, ok := named[strings.TrimPrefix(method.Name(), "GetAll")]; ok && "GetAll"+svc.name == method.Name() {
+		if results.Len() != 2 || results.At(1).Type().String() != "error" {
+			return false
 		}
+
+		m, ok := unalias(results.At(0).Type()).(*types.Map)
+
+		return ok && types.Identical(m.Key(), types.Typ[types.String]) && types.Identical(m.Elem(), svc.typ)
 	}
 
-	//
-	// for _, service := range services {
-	// 	serviceSegments := strings.SplitN(service, ":", 2)
-	//
-	// 	def := serviceDefinition{
-	// 		name: serviceSegments[0],
-	// 	}
-	//
-	// 	if len(serviceSegments) > 1 {
-	// 		serviceParams := strings.Split(serviceSegments[1], ",")
-	//
-	// 		if slices.Contains(serviceParams, "named") {
-	// 			def.named = true
-	// 		}
-	// 	}
-	//
-	// 	serviceDefinitions = append(serviceDefinitions, def)
-	// }
-
-	f := jen.NewFilePath(pkgs[0].PkgPath)
-	f.ImportName("sync", "sync")
-	f.ImportName("fmt", "fmt")
-	f.ImportName("strings", "strings")
+	return false
+}
 
-	// generateServiceLocator(f, serviceDefinitions)
-	generateGenericServiceFactory(f)
-	generateGenericNamedServiceFactory(f)
-	generateServiceRegistry(f, serviceDefinitions)
-	generateServiceLocationContext(f, serviceDefinitions)
-	generateCircularDependencyError(f)
+// parseServiceDefinition checks that method has one of the following forms and returns the service it describes:
+//
+//	GetService() (Service, error)
+//	GetService(name string) (Service, error)
+//
+// The service type can be any type that can be referenced from the generated code.
+// If a name can be derived from the service type (see serviceTypeName), the method name must match it.
+func parseServiceDefinition(method *types.Func, qf types.Qualifier) (serviceDefinition, error) {
+	sig := method.Type().(*types.Signature)
 
-	file, err := os.Create(filepath.Join(outDir, "service_locator_gen.go"))
-	if err != nil {
-		panic(err)
+	params := sig.Params()
+	results := sig.Results()
+
+	if results.Len() != 2 {
+		return serviceDefinition{}, fmt.Errorf("must return exactly two values (service and error), got %d", results.Len())
 	}
-	defer file.Close()
 
-	err = f.Render(file)
-	if err != nil {
-		fmt.Fprint(os.Stderr, "Error generating the code:", err)
-		os.Exit(1)
+	serviceType := results.At(0).Type()
+
+	if _, err := typeCode(serviceType); err != nil {
+		return serviceDefinition{}, fmt.Errorf("service type %s is not supported", types.TypeString(serviceType, qf))
+	}
+
+	serviceName := strings.TrimPrefix(method.Name(), "Get")
+
+	if serviceName == method.Name() || !token.IsExported(serviceName) {
+		return serviceDefinition{}, errors.New("method name must be Get followed by an exported service name")
+	}
+
+	if typeName, ok := serviceTypeName(serviceType); ok && serviceName != typeName {
+		return serviceDefinition{}, fmt.Errorf("method name must be Get%s for service type %s", typeName, types.TypeString(serviceType, qf))
+	}
+
+	if results.At(1).Type().String() != "error" {
+		return serviceDefinition{}, fmt.Errorf("second result must be error, got %s", types.TypeString(results.At(1).Type(), qf))
+	}
+
+	svc := serviceDefinition{
+		name:       serviceName,
+		typ:        serviceType,
+		importPath: typePackagePath(serviceType),
+		pos:        method.Pos(),
+	}
+
+	if params.Len() > 1 {
+		return serviceDefinition{}, fmt.Errorf("must accept at most one parameter, got %d", params.Len())
+	}
+
+	if params.Len() == 1 {
+		param := params.At(0)
+
+		if param.Name() != "name" || param.Type().String() != "string" {
+			return serviceDefinition{}, fmt.Errorf("parameter must be (name string), got (%s %s)", param.Name(), types.TypeString(param.Type(), qf))
+		}
+
+		svc.named = true
 	}
+
+	return svc, nil
+}
+
+// diagnostic describes an interface method that was skipped by the generator.
+type diagnostic struct {
+	pos    token.Position
+	method string
+	reason string
+
+	// sig is the signature of the method (implemented by stubs, see generateSkippedMethods)
+	sig *types.Signature
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: skipping method %s: %s", d.pos, d.method, d.reason)
 }
 
 // load typechecks the packages that match the given patterns and
@@ -165,7 +434,12 @@ func main() {
 // env is nil or empty, it is interpreted as an empty set of variables.
 // In case of duplicate environment variables, the last one in the list
 // takes precedence.
-func load(ctx context.Context, wd string, env []string, patterns []string) ([]*packages.Package, []error) {
+//
+// tags is a comma-separated list of build tags passed to the build system.
+//
+// Type errors reported in output (the file being generated) are ignored:
+// a previously generated file falls out of sync whenever the service locator interface changes.
+func load(ctx context.Context, wd string, env []string, tags string, patterns []string, output string) ([]*packages.Package, []error) {
 	cfg := &packages.Config{
 		Context: ctx,
 		Mode:    packages.LoadAllSyntax,
@@ -173,6 +447,10 @@ func load(ctx context.Context, wd string, env []string, patterns []string) ([]*p
 		Env:     env,
 	}
 
+	if tags != "" {
+		cfg.BuildFlags = []string{"-tags=" + tags}
+	}
+
 	escaped := make([]string, len(patterns))
 	for i := range patterns {
 		escaped[i] = "pattern=" + patterns[i]
@@ -186,6 +464,10 @@ func load(ctx context.Context, wd string, env []string, patterns []string) ([]*p
 	var errs []error
 	for _, p := range pkgs {
 		for _, e := range p.Errors {
+			if e.Kind == packages.TypeError && isOutputFile(errorFile(e), output) {
+				continue
+			}
+
 			errs = append(errs, e)
 		}
 	}
@@ -196,6 +478,25 @@ func load(ctx context.Context, wd string, env []string, patterns []string) ([]*p
 	return pkgs, nil
 }
 
+// errorFile returns the file name from the position of e.
+func errorFile(e packages.Error) string {
+	i := strings.LastIndex(e.Pos, ".go:")
+	if i < 0 {
+		return e.Pos
+	}
+
+	return e.Pos[:i+len(".go")]
+}
+
+// isOutputFile checks whether file is the output file (absolute or relative to the package directory).
+func isOutputFile(file string, output string) bool {
+	if filepath.IsAbs(output) {
+		return filepath.Clean(file) == filepath.Clean(output)
+	}
+
+	return strings.HasSuffix(filepath.Clean(file), string(filepath.Separator)+filepath.Clean(output))
+}
+
 func detectOutputDir(paths []string) (string, error) {
 	if len(paths) == 0 {
 		return "", errors.New("no files to derive output directory from")
@@ -211,9 +512,81 @@ func detectOutputDir(paths []string) (string, error) {
 
 type serviceDefinition struct {
 	name       string
+	typ        types.Type
 	importPath string
 
 	named bool
+	scope serviceScope
+
+	// required lists the names of a named service that must be registered
+	required []string
+
+	// constructorName selects the function constructing the service (resolved into constructor)
+	constructorName string
+	constructor     *constructor
+
+	// pos is the position of the interface method declaring the service
+	pos token.Pos
+}
+
+// serviceScope determines the lifetime of service instances.
+type serviceScope int
+
+const (
+	// scopeSingleton services are constructed once and cached by the registry.
+	scopeSingleton serviceScope = iota
+
+	// scopeTransient services are constructed every time they are requested.
+	scopeTransient
+
+	// scopeScoped services are constructed once per scope and cached by the scope.
+	scopeScoped
+)
+
+func (s serviceScope) String() string {
+	switch s {
+	case scopeTransient:
+		return "transient"
+	case scopeScoped:
+		return "scoped"
+	default:
+		return "singleton"
+	}
+}
+
+// typeString renders the service type qualified with package names (eg. *bytes.Buffer).
+//
+// Aliases are resolved, the same way they are in the generated code.
+func (s serviceDefinition) typeString() string {
+	return typeString(resolveAliases(s.typ))
+}
+
+// cached checks whether instances of the service are cached.
+func (s serviceDefinition) cached() bool {
+	return s.scope != scopeTransient
+}
+
+// typeCode renders the service type.
+func (s serviceDefinition) typeCode() *jen.Statement {
+	// The type is validated when parsing the service definition.
+	code, _ := typeCode(s.typ)
+
+	return code
+}
+
+// splitCamelCase inserts spaces between the words of a CamelCase identifier.
+func splitCamelCase(s string) string {
+	var b strings.Builder
+
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
 }
 
 // helper functions
@@ -243,212 +616,1984 @@ func generateServiceLocator(f *jen.File, services []serviceDefinition) {
 	})
 }
 
-func generateGenericServiceFactory(f *jen.File) {
+func generateGenericServiceFactory(f *jen.File, cfg config) {
 	f.Comment("ServiceFactory creates a new instance of T.")
-	f.Type().Id("ServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.Id("ServiceLocator")).Params(jen.Id("T"), jen.Error())
+	f.Type().Id("ServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.Id(cfg.interfaceName)).Params(jen.Id("T"), jen.Error())
 }
 
-func generateGenericNamedServiceFactory(f *jen.File) {
+func generateGenericNamedServiceFactory(f *jen.File, cfg config) {
 	f.Comment("NamedServiceFactory creates a new named instance of T.")
-	f.Type().Id("NamedServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.String(), jen.Id("ServiceLocator")).Params(jen.Id("T"), jen.Error())
+	f.Type().Id("NamedServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.String(), jen.Id(cfg.interfaceName)).Params(jen.Id("T"), jen.Error())
+}
+
+func generateGenericServiceDecorator(f *jen.File, cfg config) {
+	f.Comment("ServiceDecorator wraps an instance of T (eg. with logging or metrics).")
+	f.Type().Id("ServiceDecorator").Types(jen.Id("T").Any()).Func().Params(jen.Id("T"), jen.Id(cfg.interfaceName)).Params(jen.Id("T"), jen.Error())
+
+	f.Comment("decorate applies decorators to an instance returned by a factory.")
+	f.Func().Id("decorate").Types(jen.Id("T").Any()).Params(
+		jen.Id("c").Op("*").Id("serviceLocationContext"),
+		jen.Id("instance").Id("T"),
+		jen.Id("decorators").Index().Id("ServiceDecorator").Types(jen.Id("T")),
+	).Params(jen.Id("T"), jen.Error()).Block(
+		jen.For(jen.Id("_, decorator").Op(":=").Range().Id("decorators")).Block(
+			jen.Var().Id("err").Error(),
+			jen.Line(),
+			jen.Id("instance, err").Op("=").Id("decorator").Call(jen.Id("instance"), jen.Id("c")),
+			jen.If(jen.Id("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Id("instance"), jen.Id("err")),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("instance"), jen.Nil()),
+	)
+}
+
+func generateNamedServiceFactoryPattern(f *jen.File) {
+	f.Comment("namedServiceFactoryPattern is a factory of named services registered for names matching a pattern.")
+	f.Type().Id("namedServiceFactoryPattern").Types(jen.Id("T").Any()).Struct(
+		jen.Id("pattern").String(),
+		jen.Id("factory").Id("NamedContextServiceFactory").Types(jen.Id("T")),
+	)
+}
+
+func generateGenericContextServiceFactory(f *jen.File, cfg config) {
+	f.Comment("ContextServiceFactory creates a new instance of T.")
+	f.Comment("")
+	f.Comment("The context is the one passed to the Get*Context method the resolution started with.")
+	f.Type().Id("ContextServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.Qual("context", "Context"), jen.Id(cfg.interfaceName)).Params(jen.Id("T"), jen.Error())
+}
+
+func generateGenericNamedContextServiceFactory(f *jen.File, cfg config) {
+	f.Comment("NamedContextServiceFactory creates a new named instance of T.")
+	f.Comment("")
+	f.Comment("The context is the one passed to the Get*Context method the resolution started with.")
+	f.Type().Id("NamedContextServiceFactory").Types(jen.Id("T").Any()).Func().Params(jen.Qual("context", "Context"), jen.String(), jen.Id(cfg.interfaceName)).Params(jen.Id("T"), jen.Error())
 }
 
-func generateServiceRegistry(f *jen.File, services []serviceDefinition) {
-	f.Comment("ServiceRegistry allows registering service factories to construct new instances of a service.")
-	f.Comment("ServiceRegistry is also the primary {ServiceLocator} entrypoint.")
-	f.Type().Id("ServiceRegistry").StructFunc(func(g *jen.Group) {
+func generateServiceRegistry(f *jen.File, cfg config, services []serviceDefinition) {
+	f.Commentf("%s allows registering service factories to construct new instances of a service.", cfg.registryName)
+	f.Commentf("%s is also the primary {%s} entrypoint.", cfg.registryName, cfg.interfaceName)
+	f.Type().Id(cfg.registryName).StructFunc(func(g *jen.Group) {
 		g.Id("mu").Qual("sync", "Mutex")
 		g.Line()
 
 		for _, service := range services {
+			singleton := service.scope == scopeSingleton
+
+			switch {
+			case service.named && singleton:
+				g.Id("instances" + service.name).Map(jen.String()).Add(service.typeCode())
+				g.Id("factories" + service.name).Map(jen.String()).Id("NamedContextServiceFactory").Types(service.typeCode())
+				g.Id("calls" + service.name).Map(jen.String()).Op("*").Id("serviceCall").Types(service.typeCode())
+				g.Id("generations" + service.name).Map(jen.String()).Uint64()
+				g.Id("fallbackGeneration" + service.name).Uint64()
+			case service.named:
+				g.Id("factories" + service.name).Map(jen.String()).Id("NamedContextServiceFactory").Types(service.typeCode())
+			case singleton:
+				g.Id("instance" + service.name).Add(service.typeCode())
+				g.Id("constructed" + service.name).Bool()
+				g.Id("factory" + service.name).Id("ContextServiceFactory").Types(service.typeCode())
+				g.Id("call" + service.name).Op("*").Id("serviceCall").Types(service.typeCode())
+				g.Id("generation" + service.name).Uint64()
+			default:
+				g.Id("factory" + service.name).Id("ContextServiceFactory").Types(service.typeCode())
+			}
+
 			if service.named {
-				g.Id("instances"+service.name).Map(jen.String()).Qual(service.importPath, service.name)
-				g.Id("factories" + service.name).Map(jen.String()).Id("NamedServiceFactory").Types(jen.Qual(service.importPath, service.name))
+				g.Id("patternFactories" + service.name).Index().Id("namedServiceFactoryPattern").Types(service.typeCode())
+				g.Id("defaultFactory" + service.name).Id("NamedContextServiceFactory").Types(service.typeCode())
+				g.Id("decorators" + service.name).Map(jen.String()).Index().Id("ServiceDecorator").Types(service.typeCode())
 			} else {
-				g.Id("instance"+service.name).Qual(service.importPath, service.name)
-				g.Id("factory" + service.name).Id("ServiceFactory").Types(jen.Qual(service.importPath, service.name))
+				g.Id("decorators" + service.name).Index().Id("ServiceDecorator").Types(service.typeCode())
+			}
+
+			if service.constructor != nil {
+				g.Id("constructorFactory" + service.name).Bool()
 			}
 		}
+
+		g.Line()
+
+		g.Comment("waiting records constructions waiting for instances constructed by other ones")
+		g.Id("waiting").Map(jen.Op("*").Id("serviceWait")).Struct()
+
+		g.Line()
+
+		g.Comment("instanceOrder records instances in construction order, so they can be closed in reverse order")
+		g.Id("instanceOrder").Index().Any()
+		g.Id("closed").Bool()
+
+		g.Line()
+
+		g.Id("maxDepth").Int()
+		g.Id("initWorkers").Int()
+		g.Id("registrationPolicy").Id("RegistrationPolicy")
+		g.Id("hooks").Id("ResolutionHooks")
+
+		g.Line()
+
+		g.Comment("services and dependencies record the dependency graph of resolved services")
+		g.Id("services").Map(jen.String()).Struct()
+		g.Id("instanceInfo").Map(jen.String()).Id("ServiceInstanceInfo")
+		g.Id("dependencies").Map(jen.Id("ServiceDependency")).Struct()
 	})
 
-	f.Comment("NewServiceRegistry instantiates a new {ServiceRegistry}.")
-	f.Func().Id("NewServiceRegistry").Params().Op("*").Id("ServiceRegistry").Block(
-		jen.Return(jen.Op("&").Id("ServiceRegistry").ValuesFunc(func(g *jen.Group) {
+	f.Commentf("%sOption configures a {%s}.", cfg.registryName, cfg.registryName)
+	f.Type().Id(cfg.registryName + "Option").Func().Params(jen.Op("*").Id(cfg.registryName))
+
+	f.Comment("WithMaxDepth limits how deep services can depend on other services.")
+	f.Comment("Resolving a service beyond that depth returns a {MaxDepthExceededError}.")
+	f.Comment("")
+	f.Comment("The depth is not limited by default.")
+	f.Func().Id("WithMaxDepth").Params(jen.Id("maxDepth").Int()).Id(cfg.registryName + "Option").Block(
+		jen.Return(jen.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Block(
+			jen.Id("r").Dot("maxDepth").Op("=").Id("maxDepth"),
+		)),
+	)
+
+	f.Commentf("WithInitWorkers limits how many services {%s.InitAll} constructs concurrently.", cfg.registryName)
+	f.Comment("")
+	f.Comment("The default is {runtime.GOMAXPROCS}.")
+	f.Func().Id("WithInitWorkers").Params(jen.Id("workers").Int()).Id(cfg.registryName + "Option").Block(
+		jen.Return(jen.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Block(
+			jen.Id("r").Dot("initWorkers").Op("=").Id("workers"),
+		)),
+	)
+
+	f.Comment("RegistrationPolicy determines what happens when registering a factory for a service that already has one.")
+	f.Type().Id("RegistrationPolicy").Int()
+
+	f.Const().Defs(
+		jen.Comment("AllowOverride replaces the previous factory (default)."),
+		jen.Id("AllowOverride").Id("RegistrationPolicy").Op("=").Iota(),
+		jen.Line(),
+		jen.Comment("ErrorOnDuplicate keeps the previous factory and returns a {ServiceAlreadyRegisteredError}."),
+		jen.Id("ErrorOnDuplicate"),
+		jen.Line(),
+		jen.Comment("PanicOnDuplicate panics with a {ServiceAlreadyRegisteredError}."),
+		jen.Id("PanicOnDuplicate"),
+	)
+
+	f.Comment("WithRegistrationPolicy sets what happens when registering a factory for a service that already has one.")
+	f.Func().Id("WithRegistrationPolicy").Params(jen.Id("policy").Id("RegistrationPolicy")).Id(cfg.registryName + "Option").Block(
+		jen.Return(jen.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Block(
+			jen.Id("r").Dot("registrationPolicy").Op("=").Id("policy"),
+		)),
+	)
+
+	f.Comment("WithResolutionHooks sets the hooks observing how services are resolved.")
+	f.Func().Id("WithResolutionHooks").Params(jen.Id("hooks").Id("ResolutionHooks")).Id(cfg.registryName + "Option").Block(
+		jen.Return(jen.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Block(
+			jen.Id("r").Dot("hooks").Op("=").Id("hooks"),
+		)),
+	)
+
+	f.Commentf("Err%sClosed is returned when retrieving a service from a closed {%s}.", cfg.registryName, cfg.registryName)
+	f.Var().Id("Err"+cfg.registryName+"Closed").Op("=").Qual("errors", "New").Call(jen.Lit(strings.ToLower(splitCamelCase(cfg.registryName)) + " is closed"))
+
+	f.Commentf("New%s instantiates a new {%s}.", cfg.registryName, cfg.registryName)
+	f.Func().Id("New"+cfg.registryName).Params(jen.Id("opts").Op("...").Id(cfg.registryName+"Option")).Op("*").Id(cfg.registryName).Block(
+		jen.Id("r").Op(":=").Op("&").Id(cfg.registryName).ValuesFunc(func(g *jen.Group) {
+			for _, service := range services {
+				if !service.named {
+					continue
+				}
+
+				singleton := service.scope == scopeSingleton
+
+				if singleton {
+					g.Id("instances" + service.name).Op(":").Make(jen.Map(jen.String()).Add(service.typeCode()))
+				}
+
+				g.Id("factories" + service.name).Op(":").Make(jen.Map(jen.String()).Id("NamedContextServiceFactory").Types(service.typeCode()))
+				g.Id("decorators" + service.name).Op(":").Make(jen.Map(jen.String()).Index().Id("ServiceDecorator").Types(service.typeCode()))
+
+				if singleton {
+					g.Id("calls" + service.name).Op(":").Make(jen.Map(jen.String()).Op("*").Id("serviceCall").Types(service.typeCode()))
+					g.Id("generations" + service.name).Op(":").Make(jen.Map(jen.String()).Uint64())
+				}
+			}
+
 			for _, service := range services {
+				if service.constructor == nil {
+					continue
+				}
+
 				if service.named {
-					g.Id("instances" + service.name).Op(":").Make(jen.Map(jen.String()).Qual(service.importPath, service.name))
-					g.Id("factories" + service.name).Op(":").Make(jen.Map(jen.String()).Id("NamedServiceFactory").Types(jen.Qual(service.importPath, service.name)))
+					g.Id("defaultFactory" + service.name).Op(":").Id("construct" + service.name)
+				} else {
+					g.Id("factory" + service.name).Op(":").Id("construct" + service.name)
 				}
+				g.Id("constructorFactory" + service.name).Op(":").True()
 			}
-		})),
+
+			g.Id("waiting").Op(":").Make(jen.Map(jen.Op("*").Id("serviceWait")).Struct())
+			g.Id("services").Op(":").Make(jen.Map(jen.String()).Struct())
+			g.Id("instanceInfo").Op(":").Make(jen.Map(jen.String()).Id("ServiceInstanceInfo"))
+			g.Id("dependencies").Op(":").Make(jen.Map(jen.Id("ServiceDependency")).Struct())
+			g.Id("initWorkers").Op(":").Qual("runtime", "GOMAXPROCS").Call(jen.Lit(0))
+		}),
+		jen.Line(),
+		jen.For(jen.Id("_, opt").Op(":=").Range().Id("opts")).Block(
+			jen.Id("opt").Call(jen.Id("r")),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("r")),
 	)
 
-	generateServiceRegistryMethods(f, services)
+	generateServiceRegistryMethods(f, cfg, services)
 }
 
-func generateServiceRegistryMethods(f *jen.File, services []serviceDefinition) {
+func generateServiceRegistryMethods(f *jen.File, cfg config, services []serviceDefinition) {
 	for _, service := range services {
 		f.Line()
 
 		// Register method
 		f.Commentf("Register%s registers a factory for {%s}.", service.name, service.name)
+		f.Comment("")
+		f.Comment("Registering a factory again is subject to the {RegistrationPolicy} of the registry.")
+		if c := service.constructor; c != nil && !service.named {
+			f.Commentf("A factory calling {%s} is registered by default: replacing it is not subject to the policy.", c.ref)
+		}
 		f.Func().
-			Params(jen.Id("r").Op("*").Id("ServiceRegistry")).Id("Register" + service.name).
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register" + service.name).
 			ParamsFunc(func(g *jen.Group) {
 				if service.named {
 					g.Id("serviceName").String()
-					g.Id("factory").Id("NamedServiceFactory").Types(jen.Qual(service.importPath, service.name))
+					g.Id("factory").Id("NamedServiceFactory").Types(service.typeCode())
 				} else {
-					g.Id("factory").Id("ServiceFactory").Types(jen.Qual(service.importPath, service.name))
+					g.Id("factory").Id("ServiceFactory").Types(service.typeCode())
 				}
 			}).
-			BlockFunc(func(g *jen.Group) {
-				g.Id("r").Dot("mu").Dot("Lock").Call()
-				g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
-				g.Line()
+			Error().
+			Block(
+				jen.Return().Id("r").Dot("Register" + service.name + "Context").CallFunc(func(g *jen.Group) {
+					ifNamed(service.named, g, jen.Id("serviceName"))
+					g.Func().
+						ParamsFunc(func(g *jen.Group) {
+							g.Id("_").Qual("context", "Context")
+							ifNamed(service.named, g, jen.Id("name").String())
+							g.Id("serviceLocator").Id(cfg.interfaceName)
+						}).
+						Params(service.typeCode(), jen.Error()).
+						Block(
+							jen.Return(jen.Id("factory").CallFunc(func(g *jen.Group) {
+								ifNamed(service.named, g, jen.Id("name"))
+								g.Id("serviceLocator")
+							})),
+						)
+				}),
+			)
 
-				if service.named {
-					g.Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName")).Op("=").Id("factory")
-				} else {
-					g.Id("r").Dot("factory" + service.name).Op("=").Id("factory")
+		f.Line()
+
+		f.Commentf("Register%sContext registers a factory for {%s} that accepts the context of the resolution.", service.name, service.name)
+		f.Comment("")
+		f.Comment("Registering a factory again is subject to the {RegistrationPolicy} of the registry.")
+		if service.scope == scopeSingleton {
+			f.Comment("Overriding a factory discards the instance constructed by the previous one.")
+		}
+		f.Func().
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register" + service.name + "Context").
+			ParamsFunc(func(g *jen.Group) {
+				if service.named {
+					g.Id("serviceName").String()
+					g.Id("factory").Id("NamedContextServiceFactory").Types(service.typeCode())
+				} else {
+					g.Id("factory").Id("ContextServiceFactory").Types(service.typeCode())
+				}
+			}).
+			Error().
+			BlockFunc(func(g *jen.Group) {
+				g.Id("r").Dot("mu").Dot("Lock").Call()
+				g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+				g.Line()
+
+				var registered jen.Code
+				if service.named {
+					registered = jen.Id("_, ok").Op(":=").Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName")).Op(";").Id("ok")
+				} else {
+					registered = factoryRegistered(service)
+				}
+
+				g.If(registered).Block(
+					jen.If(jen.Id("err").Op(":=").Id("r").Dot("duplicateRegistration").CallFunc(func(g *jen.Group) {
+						g.Lit(service.name)
+						if service.named {
+							g.Id("serviceName")
+						} else {
+							g.Lit("")
+						}
+					}), jen.Id("err").Op("!=").Nil()).Block(
+						jen.Return(jen.Id("err")),
+					),
+				)
+
+				g.Line()
+
+				if service.named {
+					g.Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName")).Op("=").Id("factory")
+				} else {
+					g.Id("r").Dot("factory" + service.name).Op("=").Id("factory")
+					replaceConstructorFactory(g, service)
+				}
+
+				invalidateInstance(g, service)
+
+				g.Line()
+
+				g.Return(jen.Nil())
+			})
+
+		if service.scope == scopeSingleton {
+			f.Line()
+
+			generateRegisterInstance(f, cfg, service)
+		}
+
+		f.Line()
+
+		f.Commentf("Decorate%s registers a decorator wrapping instances of {%s}.", service.name, service.name)
+		f.Comment("")
+		f.Comment("Decorators are applied in registration order to instances returned by the factory.")
+		if service.scope != scopeTransient {
+			f.Comment("Instances constructed before registering a decorator (or registered as instances) are not decorated.")
+		}
+		f.Func().
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Decorate" + service.name).
+			ParamsFunc(func(g *jen.Group) {
+				ifNamed(service.named, g, jen.Id("serviceName").String())
+				g.Id("decorator").Id("ServiceDecorator").Types(service.typeCode())
+			}).
+			BlockFunc(func(g *jen.Group) {
+				g.Id("r").Dot("mu").Dot("Lock").Call()
+				g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+				g.Line()
+
+				decorators := jen.Id("r").Dot("decorators" + service.name)
+				if service.named {
+					decorators = decorators.Index(jen.Id("serviceName"))
+				}
+
+				g.Add(decorators).Op("=").Append(decorators.Clone(), jen.Id("decorator"))
+			})
+
+		f.Line()
+
+		f.Commentf("Unregister%s removes the factory of {%s}.", service.name, service.name)
+		if service.scope == scopeSingleton {
+			f.Comment("")
+			f.Comment("The instance constructed by the factory is discarded.")
+		}
+		f.Func().
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Unregister" + service.name).
+			ParamsFunc(ifNamedFunc(service.named, jen.Id("serviceName").String())).
+			BlockFunc(func(g *jen.Group) {
+				g.Id("r").Dot("mu").Dot("Lock").Call()
+				g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+				g.Line()
+
+				if service.named {
+					g.Delete(jen.Id("r").Dot("factories"+service.name), jen.Id("serviceName"))
+				} else {
+					g.Id("r").Dot("factory" + service.name).Op("=").Nil()
+					replaceConstructorFactory(g, service)
 				}
+
+				invalidateInstance(g, service)
 			})
 
+		if service.named {
+			f.Line()
+
+			generateFallbackFactories(f, cfg, service)
+		}
+
 		f.Line()
 
 		// Get method
-		f.Commentf("Get%s retrieves an instance of {%s}.", service.name, service.name)
+		switch service.scope {
+		case scopeTransient:
+			f.Commentf("Get%s creates a new instance of {%s}.", service.name, service.name)
+		case scopeScoped:
+			f.Commentf("Get%s retrieves an instance of {%s}.", service.name, service.name)
+			f.Commentf("%s is a scoped service: it can only be retrieved from a {%s}.", service.name, cfg.scopeName())
+		default:
+			f.Commentf("Get%s retrieves an instance of {%s}.", service.name, service.name)
+		}
 		f.Func().
-			Params(jen.Id("r").Op("*").Id("ServiceRegistry")).Id("Get"+service.name).
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Get"+service.name).
 			ParamsFunc(ifNamedFunc(service.named, jen.Id("serviceName").String())).
-			Params(jen.Qual(service.importPath, service.name), jen.Error()).
+			Params(service.typeCode(), jen.Error()).
+			Block(
+				jen.Return(jen.Id("r").Dot("Get" + service.name + "Context").CallFunc(func(g *jen.Group) {
+					g.Qual("context", "Background").Call()
+					ifNamed(service.named, g, jen.Id("serviceName"))
+				})),
+			)
+
+		f.Line()
+
+		f.Commentf("Get%sContext is like {%s.Get%s}, but passes ctx to the factories called during the resolution.", service.name, cfg.registryName, service.name)
+		f.Func().
+			Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Get"+service.name+"Context").
+			ParamsFunc(func(g *jen.Group) {
+				g.Id("ctx").Qual("context", "Context")
+				ifNamed(service.named, g, jen.Id("serviceName").String())
+			}).
+			Params(service.typeCode(), jen.Error()).
 			BlockFunc(func(g *jen.Group) {
+				newContext := jen.Id("newServiceLocationContext").Call(jen.Id("ctx"), jen.Id("r"), jen.Nil(), jen.Id("r").Dot("maxDepth"))
+
+				if service.scope == scopeScoped {
+					g.Return().Add(newContext).Dot("Get" + service.name).CallFunc(ifNamedFunc(service.named, jen.Id("serviceName")))
+
+					return
+				}
+
 				g.Return().Id("r").Dot("get" + service.name).CallFunc(func(g *jen.Group) {
 					ifNamed(service.named, g, jen.Id("serviceName"))
-					g.Id("newServiceLocationContext").Call(jen.Id("r"), jen.Lit(0))
+					g.Add(newContext)
 				})
 			})
 
-		f.Line()
+		if service.named {
+			f.Line()
+
+			f.Commentf("%sNames returns the names factories of {%s} are registered with (sorted).", service.name, service.name)
+			f.Func().
+				Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id(service.name+"Names").
+				Params().
+				Index().String().
+				Block(
+					jen.Id("r").Dot("mu").Dot("Lock").Call(),
+					jen.Defer().Id("r").Dot("mu").Dot("Unlock").Call(),
+					jen.Line(),
+					jen.Return(jen.Id("sortedNames").Call(jen.Id("r").Dot("factories"+service.name))),
+				)
+
+			f.Line()
+
+			generateGetAll(f, service, jen.Id("r").Op("*").Id(cfg.registryName), "r", cfg.registryName,
+				jen.Id("newServiceLocationContext").Call(jen.Id("ctx"), jen.Id("r"), jen.Nil(), jen.Id("r").Dot("maxDepth")),
+			)
+		}
+
+		// Private get method
+		switch service.scope {
+		case scopeSingleton:
+			f.Line()
+			generateCachedServiceGetter(f, cfg, service)
+		case scopeTransient:
+			f.Line()
+			generateTransientServiceGetter(f, cfg, service)
+		}
+	}
+
+	f.Line()
+
+	f.Comment("duplicateRegistration applies the registration policy to registering a service again.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("duplicateRegistration").
+		Params(jen.Id("serviceType"), jen.Id("serviceName").String()).
+		Error().
+		Block(
+			jen.Id("err").Op(":=").Id("ServiceAlreadyRegisteredError").Values(jen.Dict{
+				jen.Id("ServiceType"): jen.Id("serviceType"),
+				jen.Id("ServiceName"): jen.Id("serviceName"),
+			}),
+			jen.Line(),
+			jen.Switch(jen.Id("r").Dot("registrationPolicy")).Block(
+				jen.Case(jen.Id("ErrorOnDuplicate")).Block(
+					jen.Return(jen.Id("err")),
+				),
+				jen.Case(jen.Id("PanicOnDuplicate")).Block(
+					jen.Panic(jen.Id("err")),
+				),
+			),
+			jen.Line(),
+			jen.Return(jen.Nil()),
+		)
+
+	f.Line()
+
+	f.Comment("wouldDeadlock checks if waiter waiting for an instance constructed by owner would never return,")
+	f.Comment("because owner is (directly or indirectly) waiting for an instance constructed by waiter (or by a service depending on it).")
+	f.Comment("")
+	f.Comment("It must be called while holding the registry lock.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("wouldDeadlock").
+		Params(jen.Id("waiter"), jen.Id("owner").Op("*").Id("serviceConstruction")).
+		Bool().
+		Block(
+			jen.Comment("Nothing waits for the start of a resolution"),
+			jen.If(jen.Id("waiter").Op("==").Nil()).Block(
+				jen.Return(jen.False()),
+			),
+			jen.Line(),
+			jen.Comment("blocked lists constructions that cannot finish before owner finishes"),
+			jen.Id("blocked").Op(":=").Index().Op("*").Id("serviceConstruction").Values(jen.Id("owner")),
+			jen.Id("seen").Op(":=").Map(jen.Op("*").Id("serviceConstruction")).Bool().Values(jen.Dict{jen.Id("owner"): jen.True()}),
+			jen.Line(),
+			jen.For(jen.Id("i").Op(":=").Lit(0), jen.Id("i").Op("<").Len(jen.Id("blocked")), jen.Id("i").Op("++")).Block(
+				jen.If(jen.Id("blocked").Index(jen.Id("i")).Dot("contains").Call(jen.Id("waiter"))).Block(
+					jen.Return(jen.True()),
+				),
+				jen.Line(),
+				jen.For(jen.Id("wait").Op(":=").Range().Id("r").Dot("waiting")).Block(
+					jen.If(jen.Op("!").Id("seen").Index(jen.Id("wait").Dot("owner")).Op("&&").Id("blocked").Index(jen.Id("i")).Dot("contains").Call(jen.Id("wait").Dot("waiter"))).Block(
+						jen.Id("seen").Index(jen.Id("wait").Dot("owner")).Op("=").True(),
+						jen.Id("blocked").Op("=").Append(jen.Id("blocked"), jen.Id("wait").Dot("owner")),
+					),
+				),
+			),
+			jen.Line(),
+			jen.Return(jen.False()),
+		)
+
+	f.Line()
+
+	f.Comment("recordDependency records that a service is resolved (by the service currently being constructed, if any).")
+	f.Comment("")
+	f.Comment("It must be called while holding the registry lock.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("recordDependency").
+		Params(jen.Id("c").Op("*").Id("serviceLocationContext"), jen.Id("serviceType"), jen.Id("serviceName").String()).
+		Block(
+			jen.Id("service").Op(":=").Id("r").Dot("graphNode").Call(jen.Id("serviceType"), jen.Id("serviceName")),
+			jen.Line(),
+			jen.Id("r").Dot("services").Index(jen.Id("service")).Op("=").Struct().Values(),
+			jen.Line(),
+			jen.If(jen.Id("dependent").Op(":=").Id("c").Dot("construction"), jen.Id("dependent").Op("!=").Nil()).Block(
+				jen.Id("r").Dot("dependencies").Index(jen.Id("ServiceDependency").Values(jen.Dict{
+					jen.Id("Service"):    jen.Id("r").Dot("graphNode").Call(jen.Id("dependent").Dot("serviceType"), jen.Id("dependent").Dot("serviceName")),
+					jen.Id("Dependency"): jen.Id("service"),
+				})).Op("=").Struct().Values(),
+			),
+		)
+
+	f.Line()
+
+	f.Comment("graphNode identifies a service in the dependency graph.")
+	f.Comment("")
+	f.Comment("Names without a factory of their own (resolved by pattern or default factories) are recorded as \"*\",")
+	f.Comment("so that resolving arbitrary names does not grow the graph.")
+	f.Comment("")
+	f.Comment("It must be called while holding the registry lock.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("graphNode").
+		Params(jen.Id("serviceType"), jen.Id("serviceName").String()).
+		String().
+		BlockFunc(func(g *jen.Group) {
+			var cases []jen.Code
+			for _, service := range services {
+				if !service.named {
+					continue
+				}
+
+				cases = append(cases, jen.Case(jen.Lit(service.name)).Block(
+					jen.If(jen.Id("_, ok").Op(":=").Id("r").Dot("factories"+service.name).Index(jen.Id("serviceName")), jen.Op("!").Id("ok")).Block(
+						jen.Return(jen.Id("serviceID").Call(jen.Id("serviceType"), jen.Lit("*"))),
+					),
+				))
+			}
+
+			if len(cases) > 0 {
+				g.Switch(jen.Id("serviceType")).Block(cases...)
+				g.Line()
+			}
+
+			g.Return(jen.Id("serviceID").Call(jen.Id("serviceType"), jen.Id("serviceName")))
+		})
+
+	f.Line()
+
+	f.Comment("DependencyGraph returns the services resolved so far and their dependencies on each other.")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("DependencyGraph").Params().Id("DependencyGraph").Block(
+		jen.Id("r").Dot("mu").Dot("Lock").Call(),
+		jen.Defer().Id("r").Dot("mu").Dot("Unlock").Call(),
+		jen.Line(),
+		jen.Var().Id("graph").Id("DependencyGraph"),
+		jen.Line(),
+		jen.For(jen.Id("service").Op(":=").Range().Id("r").Dot("services")).Block(
+			jen.Id("graph").Dot("Services").Op("=").Append(jen.Id("graph").Dot("Services"), jen.Id("service")),
+		),
+		jen.Line(),
+		jen.For(jen.Id("dependency").Op(":=").Range().Id("r").Dot("dependencies")).Block(
+			jen.Id("graph").Dot("Dependencies").Op("=").Append(jen.Id("graph").Dot("Dependencies"), jen.Id("dependency")),
+		),
+		jen.Line(),
+		jen.Qual("sort", "Strings").Call(jen.Id("graph").Dot("Services")),
+		jen.Qual("sort", "Slice").Call(jen.Id("graph").Dot("Dependencies"), jen.Func().Params(jen.Id("i"), jen.Id("j").Int()).Bool().Block(
+			jen.Id("a, b").Op(":=").Id("graph").Dot("Dependencies").Index(jen.Id("i")).Op(",").Id("graph").Dot("Dependencies").Index(jen.Id("j")),
+			jen.Line(),
+			jen.If(jen.Id("a").Dot("Service").Op("!=").Id("b").Dot("Service")).Block(
+				jen.Return(jen.Id("a").Dot("Service").Op("<").Id("b").Dot("Service")),
+			),
+			jen.Line(),
+			jen.Return(jen.Id("a").Dot("Dependency").Op("<").Id("b").Dot("Dependency")),
+		)),
+		jen.Line(),
+		jen.Return(jen.Id("graph")),
+	)
+
+	f.Line()
+
+	f.Comment("Validate checks that a factory is registered for every service")
+	f.Comment("(and every required name of named services).")
+	f.Comment("")
+	f.Comment("Missing factories are reported as {ServiceNotRegisteredError} combined with {errors.Join}.")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Validate").Params().Error().BlockFunc(func(g *jen.Group) {
+		g.Id("r").Dot("mu").Dot("Lock").Call()
+		g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+		g.Line()
+
+		g.Var().Id("errs").Index().Error()
+
+		for _, service := range services {
+			g.Line()
+
+			if !service.named {
+				g.If(jen.Id("r").Dot("factory" + service.name).Op("==").Nil()).Block(
+					jen.Id("errs").Op("=").Append(jen.Id("errs"), jen.Id("ServiceNotRegisteredError").Values(jen.Dict{
+						jen.Id("ServiceType"): jen.Lit(service.name),
+					})),
+				)
+
+				continue
+			}
+
+			if len(service.required) == 0 {
+				continue
+			}
+
+			names := make([]jen.Code, 0, len(service.required))
+			for _, name := range service.required {
+				names = append(names, jen.Lit(name))
+			}
+
+			g.For(jen.Id("_, serviceName").Op(":=").Range().Index().String().Values(names...)).Block(
+				jen.If(jen.Id("_, ok").Op(":=").Id("r").Dot("lookup"+service.name).Call(jen.Id("serviceName")), jen.Op("!").Id("ok")).Block(
+					jen.Id("errs").Op("=").Append(jen.Id("errs"), jen.Id("ServiceNotRegisteredError").Values(jen.Dict{
+						jen.Id("ServiceType"): jen.Lit(service.name),
+						jen.Id("ServiceName"): jen.Id("serviceName"),
+					})),
+				),
+			)
+		}
+
+		g.Line()
+
+		g.Return(jen.Qual("errors", "Join").Call(jen.Id("errs").Op("...")))
+	})
+
+	f.Line()
+
+	generateServiceInfo(f, cfg, services)
+
+	f.Line()
+
+	generateInitAll(f, cfg, services)
+
+	f.Line()
+
+	f.Comment("Close closes service instances in reverse construction order.")
+	f.Commentf("Retrieving services from a closed registry returns {Err%sClosed}.", cfg.registryName)
+	f.Comment("")
+	f.Comment("Instances implementing either {io.Closer} or Close(context.Context) error are closed.")
+	f.Comment("Instances of scoped services are closed by their scope.")
+	f.Comment("Instances still under construction when calling Close are closed when their construction completes")
+	f.Commentf("(and the pending resolutions return {Err%sClosed}).", cfg.registryName)
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Close").Params(jen.Id("ctx").Qual("context", "Context")).Error().Block(
+		jen.Id("r").Dot("mu").Dot("Lock").Call(),
+		jen.Id("r").Dot("closed").Op("=").True(),
+		jen.Id("instances").Op(":=").Id("r").Dot("instanceOrder"),
+		jen.Id("r").Dot("instanceOrder").Op("=").Nil(),
+		jen.Id("r").Dot("mu").Dot("Unlock").Call(),
+		jen.Line(),
+		jen.Return(jen.Id("closeInstances").Call(jen.Id("ctx"), jen.Id("instances"))),
+	)
+}
+
+// generateRegisterInstance generates a method registering an already constructed instance of a singleton service.
+func generateRegisterInstance(f *jen.File, cfg config, service serviceDefinition) {
+	f.Commentf("Register%sInstance registers an instance of {%s}.", service.name, service.name)
+	f.Comment("")
+	f.Comment("Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).")
+	f.Comment("The instance is closed when closing the registry.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register" + service.name + "Instance").
+		ParamsFunc(func(g *jen.Group) {
+			ifNamed(service.named, g, jen.Id("serviceName").String())
+			g.Id("instance").Add(service.typeCode())
+		}).
+		Error().
+		BlockFunc(func(g *jen.Group) {
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+			g.Line()
+
+			var registered jen.Code
+			if service.named {
+				registered = jen.Id("_, ok").Op(":=").Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName")).Op(";").Id("ok")
+			} else {
+				registered = factoryRegistered(service)
+			}
+
+			g.If(registered).Block(
+				jen.If(jen.Id("err").Op(":=").Id("r").Dot("duplicateRegistration").CallFunc(func(g *jen.Group) {
+					g.Lit(service.name)
+					if service.named {
+						g.Id("serviceName")
+					} else {
+						g.Lit("")
+					}
+				}), jen.Id("err").Op("!=").Nil()).Block(
+					jen.Return(jen.Id("err")),
+				),
+			)
+
+			g.Line()
+
+			factory := jen.Func().
+				ParamsFunc(func(g *jen.Group) {
+					g.Qual("context", "Context")
+					ifNamed(service.named, g, jen.String())
+					g.Id(cfg.interfaceName)
+				}).
+				Params(service.typeCode(), jen.Error()).
+				Block(
+					jen.Return(jen.Id("instance"), jen.Nil()),
+				)
+
+			if service.named {
+				g.Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName")).Op("=").Add(factory)
+				g.Id("r").Dot("instances" + service.name).Index(jen.Id("serviceName")).Op("=").Id("instance")
+			} else {
+				g.Id("r").Dot("factory" + service.name).Op("=").Add(factory)
+				replaceConstructorFactory(g, service)
+				g.Id("r").Dot("instance" + service.name).Op("=").Id("instance")
+				g.Id("r").Dot("constructed" + service.name).Op("=").True()
+			}
+			incrementGeneration(g, service)
+
+			g.Line()
+
+			g.Id("service").Op(":=").Id("serviceID").CallFunc(func(g *jen.Group) {
+				g.Lit(service.name)
+				if service.named {
+					g.Id("serviceName")
+				} else {
+					g.Lit("")
+				}
+			})
+
+			g.Line()
+
+			g.Id("r").Dot("instanceOrder").Op("=").Append(jen.Id("r").Dot("instanceOrder"), jen.Id("instance"))
+			g.Id("r").Dot("services").Index(jen.Id("service")).Op("=").Struct().Values()
+			g.Id("r").Dot("instanceInfo").Index(jen.Id("service")).Op("=").Id("ServiceInstanceInfo").ValuesFunc(func(g *jen.Group) {
+				if service.named {
+					g.Id("Name").Op(":").Id("serviceName")
+				}
+				g.Id("ConstructedAt").Op(":").Qual("time", "Now").Call()
+			})
+
+			g.Line()
+
+			g.Return(jen.Nil())
+		})
+}
+
+// generateFallbackFactories generates methods registering factories of a named service
+// used for names without a factory of their own: pattern factories and a default factory.
+func generateFallbackFactories(f *jen.File, cfg config, service serviceDefinition) {
+	wrapFactory := func() *jen.Statement {
+		return jen.Func().
+			Params(jen.Id("_").Qual("context", "Context"), jen.Id("name").String(), jen.Id("serviceLocator").Id(cfg.interfaceName)).
+			Params(service.typeCode(), jen.Error()).
+			Block(
+				jen.Return(jen.Id("factory").Call(jen.Id("name"), jen.Id("serviceLocator"))),
+			)
+	}
+
+	duplicateRegistration := func(serviceName jen.Code) *jen.Statement {
+		return jen.If(jen.Id("err").Op(":=").Id("r").Dot("duplicateRegistration").Call(jen.Lit(service.name), serviceName), jen.Id("err").Op("!=").Nil()).Block(
+			jen.Return(jen.Id("err")),
+		)
+	}
+
+	f.Commentf("Register%sPattern registers a factory for {%s} used for names without a factory of their own matching pattern.", service.name, service.name)
+	f.Comment("")
+	f.Comment("Patterns use the syntax of {path.Match} (eg. \"cache.*\").")
+	f.Comment("If multiple patterns match a name, the pattern registered first is used.")
+	f.Comment("Registering a factory for the same pattern again is subject to the {RegistrationPolicy} of the registry.")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register"+service.name+"Pattern").
+		Params(jen.Id("pattern").String(), jen.Id("factory").Id("NamedServiceFactory").Types(service.typeCode())).
+		Error().
+		Block(
+			jen.Return(jen.Id("r").Dot("Register"+service.name+"PatternContext").Call(jen.Id("pattern"), wrapFactory())),
+		)
+
+	f.Line()
+
+	f.Commentf("Register%sPatternContext is like {%s.Register%sPattern}, but registers a factory that accepts the context of the resolution.", service.name, cfg.registryName, service.name)
+	if service.scope == scopeSingleton {
+		f.Comment("")
+		f.Comment("Instances constructed by pattern and default factories are discarded.")
+	}
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register"+service.name+"PatternContext").
+		Params(jen.Id("pattern").String(), jen.Id("factory").Id("NamedContextServiceFactory").Types(service.typeCode())).
+		Error().
+		BlockFunc(func(g *jen.Group) {
+			g.If(jen.Id("_, err").Op(":=").Qual("path", "Match").Call(jen.Id("pattern"), jen.Lit("")), jen.Id("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Qual("fmt", "Errorf").Call(jen.Lit("invalid pattern %q for "+service.name+": %w"), jen.Id("pattern"), jen.Id("err"))),
+			)
+
+			g.Line()
+
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.Id("registered").Op(":=").False()
+
+			g.Line()
+
+			g.For(jen.Id("i").Op(":=").Range().Id("r").Dot("patternFactories"+service.name)).Block(
+				jen.If(jen.Id("r").Dot("patternFactories"+service.name).Index(jen.Id("i")).Dot("pattern").Op("!=").Id("pattern")).Block(
+					jen.Continue(),
+				),
+				jen.Line(),
+				duplicateRegistration(jen.Id("pattern")),
+				jen.Line(),
+				jen.Id("r").Dot("patternFactories"+service.name).Index(jen.Id("i")).Dot("factory").Op("=").Id("factory"),
+				jen.Id("registered").Op("=").True(),
+			)
+
+			g.Line()
+
+			g.If(jen.Op("!").Id("registered")).Block(
+				jen.Id("r").Dot("patternFactories"+service.name).Op("=").Append(
+					jen.Id("r").Dot("patternFactories"+service.name),
+					jen.Id("namedServiceFactoryPattern").Types(service.typeCode()).Values(jen.Dict{
+						jen.Id("pattern"): jen.Id("pattern"),
+						jen.Id("factory"): jen.Id("factory"),
+					}),
+				),
+			)
+
+			invalidateFallbackInstances(g, service)
+
+			g.Line()
+
+			g.Return(jen.Nil())
+		})
+
+	f.Line()
+
+	f.Commentf("Unregister%sPattern removes the factory of {%s} registered for pattern.", service.name, service.name)
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Unregister" + service.name + "Pattern").
+		Params(jen.Id("pattern").String()).
+		BlockFunc(func(g *jen.Group) {
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.For(jen.Id("i, p").Op(":=").Range().Id("r").Dot("patternFactories" + service.name)).Block(
+				jen.If(jen.Id("p").Dot("pattern").Op("==").Id("pattern")).Block(
+					jen.Id("r").Dot("patternFactories"+service.name).Op("=").Append(
+						jen.Id("r").Dot("patternFactories"+service.name).Index(jen.Empty(), jen.Id("i"), jen.Id("i")),
+						jen.Id("r").Dot("patternFactories"+service.name).Index(jen.Id("i").Op("+").Lit(1), jen.Empty()).Op("..."),
+					),
+					jen.Line(),
+					jen.Break(),
+				),
+			)
+
+			invalidateFallbackInstances(g, service)
+		})
+
+	f.Line()
+
+	f.Commentf("Register%sDefault registers a factory for {%s} used for names without a factory of their own (or a matching pattern).", service.name, service.name)
+	f.Comment("")
+	f.Comment("Registering a default factory again is subject to the {RegistrationPolicy} of the registry.")
+	if c := service.constructor; c != nil {
+		f.Commentf("A default factory calling {%s} is registered by default: replacing it is not subject to the policy.", c.ref)
+	}
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register" + service.name + "Default").
+		Params(jen.Id("factory").Id("NamedServiceFactory").Types(service.typeCode())).
+		Error().
+		Block(
+			jen.Return(jen.Id("r").Dot("Register" + service.name + "DefaultContext").Call(wrapFactory())),
+		)
+
+	f.Line()
+
+	f.Commentf("Register%sDefaultContext is like {%s.Register%sDefault}, but registers a factory that accepts the context of the resolution.", service.name, cfg.registryName, service.name)
+	if service.scope == scopeSingleton {
+		f.Comment("")
+		f.Comment("Instances constructed by pattern and default factories are discarded.")
+	}
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Register" + service.name + "DefaultContext").
+		Params(jen.Id("factory").Id("NamedContextServiceFactory").Types(service.typeCode())).
+		Error().
+		BlockFunc(func(g *jen.Group) {
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.If(factoryRegistered(service)).Block(
+				duplicateRegistration(jen.Lit("*")),
+			)
+
+			g.Line()
+
+			g.Id("r").Dot("defaultFactory" + service.name).Op("=").Id("factory")
+			replaceConstructorFactory(g, service)
+
+			invalidateFallbackInstances(g, service)
+
+			g.Line()
+
+			g.Return(jen.Nil())
+		})
+
+	f.Line()
+
+	f.Commentf("Unregister%sDefault removes the default factory of {%s}.", service.name, service.name)
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Unregister" + service.name + "Default").
+		Params().
+		BlockFunc(func(g *jen.Group) {
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.Id("r").Dot("defaultFactory" + service.name).Op("=").Nil()
+			replaceConstructorFactory(g, service)
+
+			invalidateFallbackInstances(g, service)
+		})
+
+	f.Line()
+
+	f.Commentf("lookup%s returns the factory of {%s} for serviceName", service.name, service.name)
+	f.Comment("(falling back to pattern factories and the default factory).")
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("lookup"+service.name).
+		Params(jen.Id("serviceName").String()).
+		Params(jen.Id("NamedContextServiceFactory").Types(service.typeCode()), jen.Bool()).
+		Block(
+			jen.If(jen.Id("factory, ok").Op(":=").Id("r").Dot("factories"+service.name).Index(jen.Id("serviceName")), jen.Id("ok")).Block(
+				jen.Return(jen.Id("factory"), jen.True()),
+			),
+			jen.Line(),
+			jen.For(jen.Id("_, p").Op(":=").Range().Id("r").Dot("patternFactories"+service.name)).Block(
+				jen.If(jen.Id("ok, _").Op(":=").Qual("path", "Match").Call(jen.Id("p").Dot("pattern"), jen.Id("serviceName")), jen.Id("ok")).Block(
+					jen.Return(jen.Id("p").Dot("factory"), jen.True()),
+				),
+			),
+			jen.Line(),
+			jen.Return(jen.Id("r").Dot("defaultFactory"+service.name), jen.Id("r").Dot("defaultFactory"+service.name).Op("!=").Nil()),
+		)
+}
+
+// factoryRegistered checks whether a factory (or a default factory for named services) is registered for service.
+//
+// The factory calling the constructor of a service is registered by default:
+// replacing it is not subject to the registration policy.
+func factoryRegistered(service serviceDefinition) *jen.Statement {
+	field := "factory" + service.name
+	if service.named {
+		field = "defaultFactory" + service.name
+	}
+
+	registered := jen.Id("r").Dot(field).Op("!=").Nil()
+
+	if service.constructor != nil {
+		registered.Op("&&").Op("!").Id("r").Dot("constructorFactory" + service.name)
+	}
+
+	return registered
+}
+
+// replaceConstructorFactory records that the factory calling the constructor of service was replaced (or removed).
+func replaceConstructorFactory(g *jen.Group, service serviceDefinition) {
+	if service.constructor != nil {
+		g.Id("r").Dot("constructorFactory" + service.name).Op("=").False()
+	}
+}
+
+// invalidateFallbackInstances discards the instances of a named singleton service
+// constructed by pattern and default factories.
+func invalidateFallbackInstances(g *jen.Group, service serviceDefinition) {
+	if service.scope != scopeSingleton {
+		return
+	}
+
+	g.Line()
+
+	g.Id("r").Dot("fallbackGeneration" + service.name).Op("++")
+	g.For(jen.Id("serviceName").Op(":=").Range().Id("r").Dot("instances" + service.name)).Block(
+		jen.If(jen.Id("_, ok").Op(":=").Id("r").Dot("factories"+service.name).Index(jen.Id("serviceName")), jen.Op("!").Id("ok")).Block(
+			jen.Delete(jen.Id("r").Dot("instances"+service.name), jen.Id("serviceName")),
+			jen.Delete(jen.Id("r").Dot("instanceInfo"), jen.Id("serviceID").Call(jen.Lit(service.name), jen.Id("serviceName"))),
+		),
+	)
+}
+
+// invalidateInstance discards the instance of a singleton service cached by the registry.
+//
+// Instances of scoped services are cached by scopes: they are left intact.
+func invalidateInstance(g *jen.Group, service serviceDefinition) {
+	if service.scope != scopeSingleton {
+		return
+	}
+
+	// Instances constructed by the previous registration are not cached (see generateCachedServiceGetter)
+	incrementGeneration(g, service)
+
+	if service.named {
+		g.Delete(jen.Id("r").Dot("instances"+service.name), jen.Id("serviceName"))
+		g.Delete(jen.Id("r").Dot("instanceInfo"), jen.Id("serviceID").Call(jen.Lit(service.name), jen.Id("serviceName")))
+
+		return
+	}
+
+	g.Var().Id("zero").Add(service.typeCode())
+	g.Id("r").Dot("instance" + service.name).Op("=").Id("zero")
+	g.Id("r").Dot("constructed" + service.name).Op("=").False()
+	g.Delete(jen.Id("r").Dot("instanceInfo"), jen.Lit(service.name))
+}
+
+// incrementGeneration records that the registration of a singleton service changed
+// (for the name in the serviceName variable, in case of named services).
+func incrementGeneration(g *jen.Group, service serviceDefinition) {
+	if service.named {
+		g.Id("r").Dot("generations" + service.name).Index(jen.Id("serviceName")).Op("++")
+
+		return
+	}
+
+	g.Id("r").Dot("generation" + service.name).Op("++")
+}
+
+// generateServiceInfo generates a method describing the services of the registry.
+//
+// Static information comes from the service definitions, the rest is recorded by the registry.
+func generateServiceInfo(f *jen.File, cfg config, services []serviceDefinition) {
+	f.Commentf("ServiceInfo describes a service of a {%s}.", cfg.registryName)
+	f.Type().Id("ServiceInfo").Struct(
+		jen.Comment("Name is the name of the service (eg. the name of the type)."),
+		jen.Id("Name").String().Tag(map[string]string{"json": "name"}),
+		jen.Line(),
+		jen.Comment("Type is the type of the service qualified by its package name."),
+		jen.Id("Type").String().Tag(map[string]string{"json": "type"}),
+		jen.Line(),
+		jen.Comment("ImportPath is the import path of the package declaring the type of the service (if any)."),
+		jen.Id("ImportPath").String().Tag(map[string]string{"json": "importPath,omitempty"}),
+		jen.Line(),
+		jen.Comment("Scope is either singleton, transient or scoped."),
+		jen.Id("Scope").String().Tag(map[string]string{"json": "scope"}),
+		jen.Line(),
+		jen.Id("Named").Bool().Tag(map[string]string{"json": "named"}),
+		jen.Line(),
+		jen.Comment("Names lists the names factories of a named service are registered with (sorted)."),
+		jen.Id("Names").Index().String().Tag(map[string]string{"json": "names,omitempty"}),
+		jen.Line(),
+		jen.Comment("Registered reports whether a factory is registered for the service (or any name of a named service)."),
+		jen.Id("Registered").Bool().Tag(map[string]string{"json": "registered"}),
+		jen.Line(),
+		jen.Comment("Constructed reports whether an instance of the service is cached by the registry."),
+		jen.Id("Constructed").Bool().Tag(map[string]string{"json": "constructed"}),
+		jen.Line(),
+		jen.Comment("Instances lists the instances cached by the registry (sorted by name)."),
+		jen.Id("Instances").Index().Id("ServiceInstanceInfo").Tag(map[string]string{"json": "instances,omitempty"}),
+		jen.Line(),
+		jen.Comment("Dependencies lists the services the service depends on (recorded when resolving services)."),
+		jen.Id("Dependencies").Index().String().Tag(map[string]string{"json": "dependencies,omitempty"}),
+	)
+
+	f.Comment("ServiceInstanceInfo describes an instance cached by the registry.")
+	f.Type().Id("ServiceInstanceInfo").Struct(
+		jen.Comment("Name is the name of the instance (for named services)."),
+		jen.Id("Name").String().Tag(map[string]string{"json": "name,omitempty"}),
+		jen.Line(),
+		jen.Comment("ConstructedAt is the time the construction of the instance started."),
+		jen.Id("ConstructedAt").Qual("time", "Time").Tag(map[string]string{"json": "constructedAt"}),
+		jen.Line(),
+		jen.Comment("Duration is the time it took to construct the instance (zero for registered instances)."),
+		jen.Id("Duration").Qual("time", "Duration").Tag(map[string]string{"json": "duration"}),
+	)
+
+	f.Line()
+
+	f.Comment("Services describes the services of the registry (in the order they are declared).")
+	f.Comment("")
+	f.Comment("Instances of scoped services are cached by scopes: they are not listed.")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("Services").Params().Index().Id("ServiceInfo").BlockFunc(func(g *jen.Group) {
+		g.Id("r").Dot("mu").Dot("Lock").Call()
+		g.Defer().Id("r").Dot("mu").Dot("Unlock").Call()
+
+		g.Line()
+
+		g.Return(jen.Index().Id("ServiceInfo").CustomFunc(jen.Options{Open: "{", Close: "}", Separator: ",", Multi: true}, func(g *jen.Group) {
+			for _, service := range services {
+				g.Id("r").Dot("serviceInfo").Call(jen.Id("ServiceInfo").CustomFunc(jen.Options{Open: "{", Close: "}", Separator: ",", Multi: true}, func(g *jen.Group) {
+					g.Id("Name").Op(":").Lit(service.name)
+					g.Id("Type").Op(":").Lit(service.typeString())
+					if service.importPath != "" {
+						g.Id("ImportPath").Op(":").Lit(service.importPath)
+					}
+					g.Id("Scope").Op(":").Lit(service.scope.String())
+
+					if !service.named {
+						g.Id("Registered").Op(":").Id("r").Dot("factory" + service.name).Op("!=").Nil()
+
+						return
+					}
+
+					g.Id("Named").Op(":").True()
+					g.Id("Names").Op(":").Id("sortedNames").Call(jen.Id("r").Dot("factories" + service.name))
+					g.Id("Registered").Op(":").Len(jen.Id("r").Dot("factories" + service.name)).Op(">").Lit(0).Op("||").
+						Len(jen.Id("r").Dot("patternFactories" + service.name)).Op(">").Lit(0).Op("||").
+						Id("r").Dot("defaultFactory" + service.name).Op("!=").Nil()
+				}))
+			}
+		}))
+	})
+
+	f.Line()
+
+	f.Comment("serviceInfo completes info with the instances and the dependencies recorded by the registry.")
+	f.Comment("")
+	f.Comment("It must be called while holding the registry lock.")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("serviceInfo").Params(jen.Id("info").Id("ServiceInfo")).Id("ServiceInfo").Block(
+		jen.Id("matches").Op(":=").Func().Params(jen.Id("service").String()).Bool().Block(
+			jen.If(jen.Id("info").Dot("Named")).Block(
+				jen.Return(jen.Qual("strings", "HasPrefix").Call(jen.Id("service"), jen.Id("info").Dot("Name").Op("+").Lit(":"))),
+			),
+			jen.Line(),
+			jen.Return(jen.Id("service").Op("==").Id("info").Dot("Name")),
+		),
+		jen.Line(),
+		jen.For(jen.Id("service, instance").Op(":=").Range().Id("r").Dot("instanceInfo")).Block(
+			jen.If(jen.Id("matches").Call(jen.Id("service"))).Block(
+				jen.Id("info").Dot("Instances").Op("=").Append(jen.Id("info").Dot("Instances"), jen.Id("instance")),
+			),
+		),
+		jen.Line(),
+		jen.Qual("sort", "Slice").Call(jen.Id("info").Dot("Instances"), jen.Func().Params(jen.Id("i"), jen.Id("j").Int()).Bool().Block(
+			jen.Return(jen.Id("info").Dot("Instances").Index(jen.Id("i")).Dot("Name").Op("<").Id("info").Dot("Instances").Index(jen.Id("j")).Dot("Name")),
+		)),
+		jen.Line(),
+		jen.Id("info").Dot("Constructed").Op("=").Len(jen.Id("info").Dot("Instances")).Op(">").Lit(0),
+		jen.Line(),
+		jen.Id("seen").Op(":=").Make(jen.Map(jen.String()).Bool()),
+		jen.Line(),
+		jen.For(jen.Id("dependency").Op(":=").Range().Id("r").Dot("dependencies")).Block(
+			jen.If(jen.Id("matches").Call(jen.Id("dependency").Dot("Service")).Op("&&").Op("!").Id("seen").Index(jen.Id("dependency").Dot("Dependency"))).Block(
+				jen.Id("seen").Index(jen.Id("dependency").Dot("Dependency")).Op("=").True(),
+				jen.Id("info").Dot("Dependencies").Op("=").Append(jen.Id("info").Dot("Dependencies"), jen.Id("dependency").Dot("Dependency")),
+			),
+		),
+		jen.Line(),
+		jen.Qual("sort", "Strings").Call(jen.Id("info").Dot("Dependencies")),
+		jen.Line(),
+		jen.Return(jen.Id("info")),
+	)
+
+	f.Line()
+
+	f.Comment("sortedNames returns the keys of m (sorted).")
+	f.Func().Id("sortedNames").Types(jen.Id("T").Any()).Params(jen.Id("m").Map(jen.String()).Id("T")).Index().String().Block(
+		jen.Id("names").Op(":=").Make(jen.Index().String(), jen.Lit(0), jen.Len(jen.Id("m"))),
+		jen.For(jen.Id("name").Op(":=").Range().Id("m")).Block(
+			jen.Id("names").Op("=").Append(jen.Id("names"), jen.Id("name")),
+		),
+		jen.Qual("sort", "Strings").Call(jen.Id("names")),
+		jen.Line(),
+		jen.Return(jen.Id("names")),
+	)
+}
+
+// generateInitAll generates a method constructing every registered singleton.
+func generateInitAll(f *jen.File, cfg config, services []serviceDefinition) {
+	f.Comment("InitAll constructs every registered singleton (including every registered name of named services).")
+	f.Comment("")
+	f.Comment("Services are constructed concurrently by a limited number of workers (see {WithInitWorkers}),")
+	f.Comment("so services that do not depend on each other are constructed in parallel.")
+	f.Comment("Errors are combined with {errors.Join} (reporting each failing service once).")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("InitAll").Params(jen.Id("ctx").Qual("context", "Context")).Error().BlockFunc(func(g *jen.Group) {
+		g.Var().Id("inits").Index().Func().Params().Error()
+
+		g.Line()
+
+		g.Id("r").Dot("mu").Dot("Lock").Call()
+
+		for _, service := range services {
+			if service.scope != scopeSingleton {
+				continue
+			}
+
+			g.Line()
+
+			if !service.named {
+				g.If(jen.Id("r").Dot("factory" + service.name).Op("!=").Nil()).Block(
+					jen.Id("inits").Op("=").Append(jen.Id("inits"), jen.Func().Params().Error().Block(
+						jen.Id("_, err").Op(":=").Id("r").Dot("Get"+service.name+"Context").Call(jen.Id("ctx")),
+						jen.Line(),
+						jen.Return(jen.Id("err")),
+					)),
+				)
+
+				continue
+			}
+
+			g.Id("names"+service.name).Op(":=").Make(jen.Index().String(), jen.Lit(0), jen.Len(jen.Id("r").Dot("factories"+service.name)))
+			g.For(jen.Id("serviceName").Op(":=").Range().Id("r").Dot("factories" + service.name)).Block(
+				jen.Id("names"+service.name).Op("=").Append(jen.Id("names"+service.name), jen.Id("serviceName")),
+			)
+			g.Qual("sort", "Strings").Call(jen.Id("names" + service.name))
+
+			g.Line()
+
+			g.For(jen.Id("_, serviceName").Op(":=").Range().Id("names"+service.name)).Block(
+				jen.Id("serviceName").Op(":=").Id("serviceName"),
+				jen.Line(),
+				jen.Id("inits").Op("=").Append(jen.Id("inits"), jen.Func().Params().Error().Block(
+					jen.Id("_, err").Op(":=").Id("r").Dot("Get"+service.name+"Context").Call(jen.Id("ctx"), jen.Id("serviceName")),
+					jen.Line(),
+					jen.Return(jen.Id("err")),
+				)),
+			)
+		}
+
+		g.Line()
+
+		g.Id("workers").Op(":=").Id("r").Dot("initWorkers")
+		g.Id("r").Dot("mu").Dot("Unlock").Call()
+
+		g.Line()
+
+		g.If(jen.Id("workers").Op("<").Lit(1)).Block(
+			jen.Id("workers").Op("=").Lit(1),
+		)
+
+		g.Line()
+
+		g.Comment("Services waiting for a dependency wait for the worker constructing it,")
+		g.Comment("so workers never wait for services that are not being constructed.")
+		g.Id("jobs").Op(":=").Make(jen.Chan().Int())
+		g.Id("errs").Op(":=").Make(jen.Index().Error(), jen.Len(jen.Id("inits")))
+
+		g.Line()
+
+		g.Var().Id("wg").Qual("sync", "WaitGroup")
+
+		g.Line()
+
+		g.For(jen.Id("i").Op(":=").Lit(0), jen.Id("i").Op("<").Id("workers").Op("&&").Id("i").Op("<").Len(jen.Id("inits")), jen.Id("i").Op("++")).Block(
+			jen.Id("wg").Dot("Add").Call(jen.Lit(1)),
+			jen.Line(),
+			jen.Go().Func().Params().Block(
+				jen.Defer().Id("wg").Dot("Done").Call(),
+				jen.Line(),
+				jen.For(jen.Id("job").Op(":=").Range().Id("jobs")).Block(
+					jen.Id("errs").Index(jen.Id("job")).Op("=").Id("inits").Index(jen.Id("job")).Call(),
+				),
+			).Call(),
+		)
+
+		g.Line()
+
+		g.For(jen.Id("job").Op(":=").Range().Id("inits")).Block(
+			jen.Id("jobs").Op("<-").Id("job"),
+		)
+		g.Close(jen.Id("jobs"))
+
+		g.Line()
+
+		g.Id("wg").Dot("Wait").Call()
+
+		g.Line()
+
+		g.Comment("Services depending on a failed service report the failure of that service as well")
+		g.Var().Id("combined").Index().Error()
+		g.Id("seen").Op(":=").Make(jen.Map(jen.String()).Bool())
+
+		g.Line()
+
+		g.For(jen.Id("_, err").Op(":=").Range().Id("errs")).Block(
+			jen.If(jen.Id("err").Op("==").Nil()).Block(
+				jen.Continue(),
+			),
+			jen.Line(),
+			jen.Id("key").Op(":=").Id("err").Dot("Error").Call(),
+			jen.Line(),
+			jen.Var().Id("constructionErr").Id("ServiceConstructionError"),
+			jen.If(jen.Qual("errors", "As").Call(jen.Id("err"), jen.Op("&").Id("constructionErr"))).Block(
+				jen.Id("key").Op("=").Id("serviceID").Call(jen.Id("constructionErr").Dot("ServiceType"), jen.Id("constructionErr").Dot("ServiceName")),
+			),
+			jen.Line(),
+			jen.If(jen.Id("seen").Index(jen.Id("key"))).Block(
+				jen.Continue(),
+			),
+			jen.Line(),
+			jen.Id("seen").Index(jen.Id("key")).Op("=").True(),
+			jen.Id("combined").Op("=").Append(jen.Id("combined"), jen.Id("err")),
+		)
+
+		g.Line()
+
+		g.Return(jen.Qual("errors", "Join").Call(jen.Id("combined").Op("...")))
+	})
+}
+
+// generateCachedServiceGetter generates the getter of services whose instances are cached by the registry.
+func generateCachedServiceGetter(f *jen.File, cfg config, service serviceDefinition) {
+	// Instances of scoped services are stored in the scope, everything else is stored in the registry.
+	receiver := jen.Id("r").Op("*").Id(cfg.registryName)
+	self := func() *jen.Statement { return jen.Id("r") }
+	registry := func() *jen.Statement { return jen.Id("r") }
+
+	if service.scope == scopeScoped {
+		receiver = jen.Id("s").Op("*").Id(cfg.scopeName())
+		self = func() *jen.Statement { return jen.Id("s") }
+		registry = func() *jen.Statement { return jen.Id("s").Dot("registry") }
+	}
+
+	f.Func().
+		Params(receiver).Id("get"+service.name).
+		ParamsFunc(func(g *jen.Group) {
+			ifNamed(service.named, g, jen.Id("serviceName").String())
+			g.Id("c").Op("*").Id("serviceLocationContext")
+		}).
+		Params(service.typeCode(), jen.Error()).
+		BlockFunc(func(g *jen.Group) {
+			var callField *jen.Statement
+			if service.named {
+				callField = self().Dot("calls" + service.name).Index(jen.Id("serviceName"))
+			} else {
+				callField = self().Dot("call" + service.name)
+			}
+
+			g.Var().Id("zero").Add(service.typeCode())
+
+			g.Line()
+
+			g.Add(callHook("c", service, "ResolveStart"))
+
+			g.Line()
+
+			g.Add(registry()).Dot("mu").Dot("Lock").Call()
+
+			g.Line()
+
+			g.If(registry().Dot("closed")).Block(
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				jen.Return(jen.Id("zero"), jen.Id("Err"+cfg.registryName+"Closed")),
+			)
+
+			g.Line()
+
+			g.Add(registry()).Dot("recordDependency").CallFunc(func(g *jen.Group) {
+				g.Id("c")
+				g.Lit(service.name)
+				if service.named {
+					g.Id("serviceName")
+				} else {
+					g.Lit("")
+				}
+			})
+
+			if service.scope == scopeScoped {
+				g.Line()
+
+				g.If(jen.Id("s").Dot("closed")).Block(
+					registry().Dot("mu").Dot("Unlock").Call(),
+					jen.Line(),
+					jen.Return(jen.Id("zero"), jen.Id("Err"+cfg.scopeName()+"Closed")),
+				)
+			}
+
+			g.Line()
+
+			if service.named {
+				g.If(
+					jen.Id("instance, ok").Op(":=").Add(self()).Dot("instances"+service.name).Index(jen.Id("serviceName")),
+					jen.Id("ok"),
+				).Block(
+					registry().Dot("mu").Dot("Unlock").Call(),
+					jen.Line(),
+					callHook("c", service, "CacheHit"),
+					jen.Line(),
+					jen.Return(jen.Id("instance"), jen.Nil()),
+				)
+			} else {
+				g.If(self().Dot("constructed"+service.name)).Block(
+					jen.Id("instance").Op(":=").Add(self()).Dot("instance"+service.name),
+					registry().Dot("mu").Dot("Unlock").Call(),
+					jen.Line(),
+					callHook("c", service, "CacheHit"),
+					jen.Line(),
+					jen.Return(jen.Id("instance"), jen.Nil()),
+				)
+			}
+
+			g.Line()
+
+			serviceArgs := func(g *jen.Group) {
+				g.Lit(service.name)
+				if service.named {
+					g.Id("serviceName")
+				} else {
+					g.Lit("")
+				}
+			}
+
+			circularDependencyError := jen.Id("c").Dot("circularDependencyError").CallFunc(serviceArgs)
+
+			g.If(jen.Id("c").Dot("isConstructing").CallFunc(serviceArgs)).Block(
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				callHook("c", service, "CircularDependency"),
+				jen.Line(),
+				jen.Return(jen.Id("zero"), circularDependencyError),
+			)
+
+			g.Line()
+
+			g.Comment("Wait for the instance if it is already being constructed")
+			g.If(jen.Id("call").Op(":=").Add(callField), jen.Id("call").Op("!=").Nil()).Block(
+				jen.If(registry().Dot("wouldDeadlock").Call(jen.Id("c").Dot("construction"), jen.Id("call").Dot("owner"))).Block(
+					registry().Dot("mu").Dot("Unlock").Call(),
+					jen.Line(),
+					callHook("c", service, "CircularDependency"),
+					jen.Line(),
+					jen.Return(jen.Id("zero"), circularDependencyError),
+				),
+				jen.Line(),
+				jen.Id("wait").Op(":=").Op("&").Id("serviceWait").Values(jen.Dict{
+					jen.Id("waiter"): jen.Id("c").Dot("construction"),
+					jen.Id("owner"):  jen.Id("call").Dot("owner"),
+				}),
+				registry().Dot("waiting").Index(jen.Id("wait")).Op("=").Struct().Values(),
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				jen.Select().Block(
+					jen.Case(jen.Op("<-").Id("call").Dot("done")),
+					jen.Case(jen.Op("<-").Id("c").Dot("ctx").Dot("Done").Call()),
+				),
+				jen.Line(),
+				registry().Dot("mu").Dot("Lock").Call(),
+				jen.Delete(registry().Dot("waiting"), jen.Id("wait")),
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				jen.Select().Block(
+					jen.Case(jen.Op("<-").Id("call").Dot("done")),
+					jen.Default().Block(
+						jen.Return(jen.Id("zero"), jen.Id("c").Dot("ctx").Dot("Err").Call()),
+					),
+				),
+				jen.Line(),
+				jen.If(jen.Id("call").Dot("err").Op("!=").Nil()).Block(
+					jen.Return(jen.Id("zero"), jen.Id("call").Dot("err")),
+				),
+				jen.Line(),
+				jen.Return(jen.Id("call").Dot("instance"), jen.Nil()),
+			)
+
+			g.Line()
+
+			if service.named {
+				g.Id("factory, factoryOk").Op(":=").Add(registry()).Dot("lookup" + service.name).Call(jen.Id("serviceName"))
+			} else {
+				g.Id("factory").Op(":=").Add(registry()).Dot("factory" + service.name)
+				g.Id("factoryOk").Op(":=").Id("factory").Op("!=").Nil()
+			}
+			if service.named {
+				g.Id("decorators").Op(":=").Add(registry()).Dot("decorators" + service.name).Index(jen.Id("serviceName"))
+			} else {
+				g.Id("decorators").Op(":=").Add(registry()).Dot("decorators" + service.name)
+			}
+			switch {
+			case service.scope == scopeSingleton && service.named:
+				g.Id("_, exact").Op(":=").Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName"))
+				g.Id("generation").Op(":=").Id("r").Dot("generations" + service.name).Index(jen.Id("serviceName"))
+				g.Id("fallbackGeneration").Op(":=").Id("r").Dot("fallbackGeneration" + service.name)
+			case service.scope == scopeSingleton:
+				g.Id("generation").Op(":=").Id("r").Dot("generation" + service.name)
+			}
+
+			g.Line()
+
+			g.If(jen.Op("!").Id("factoryOk")).BlockFunc(func(g *jen.Group) {
+				g.Add(registry()).Dot("mu").Dot("Unlock").Call()
+
+				g.Line()
+
+				g.Return(jen.Id("zero"), jen.Id("ServiceNotRegisteredError").Values(jen.DictFunc(func(d jen.Dict) {
+					d[jen.Id("ServiceType")] = jen.Lit(service.name)
+					if service.named {
+						d[jen.Id("ServiceName")] = jen.Id("serviceName")
+					}
+					d[jen.Id("Path")] = jen.Id("c").Dot("path").Call()
+				})))
+			})
+
+			g.Line()
+
+			g.If(jen.Id("err").Op(":=").Id("c").Dot("ctx").Dot("Err").Call(), jen.Id("err").Op("!=").Nil()).Block(
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				jen.Return(jen.Id("zero"), jen.Id("err")),
+			)
+
+			g.Line()
+
+			g.Id("child, err").Op(":=").Id("c").Dot("enter").CallFunc(serviceArgs)
+			g.If(jen.Id("err").Op("!=").Nil()).Block(
+				registry().Dot("mu").Dot("Unlock").Call(),
+				jen.Line(),
+				jen.Return(jen.Id("zero"), jen.Id("err")),
+			)
+
+			g.Line()
+
+			g.Id("call").Op(":=").Op("&").Id("serviceCall").Types(service.typeCode()).Values(jen.Dict{
+				jen.Id("done"):  jen.Make(jen.Chan().Struct()),
+				jen.Id("owner"): jen.Id("child").Dot("construction"),
+			})
+			g.Add(callField.Clone()).Op("=").Id("call")
+			g.Add(registry()).Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.Var().Id("start").Qual("time", "Time")
+
+			g.Line()
+
+			g.Comment("Resolutions waiting for the instance must not hang if the factory (or a decorator) panics")
+			g.Defer().Func().Params().BlockFunc(func(g *jen.Group) {
+				g.Id("p").Op(":=").Recover()
+				g.If(jen.Id("p").Op("!=").Nil()).Block(
+					jen.Id("err").Op(":=").Qual("fmt", "Errorf").Call(jen.Lit("panic: %v"), jen.Id("p")),
+					jen.Line(),
+					callHook("child", service, "FactoryEnd", jen.Qual("time", "Since").Call(jen.Id("start")), jen.Id("err")),
+					jen.Line(),
+					jen.Id("call").Dot("err").Op("=").Id("child").Dot("constructionError").CallFunc(func(g *jen.Group) {
+						serviceArgs(g)
+						g.Id("err")
+					}),
+				)
+
+				g.Line()
+
+				g.Add(registry()).Dot("mu").Dot("Lock").Call()
+				if service.named {
+					g.Delete(self().Dot("calls"+service.name), jen.Id("serviceName"))
+				} else {
+					g.Add(self()).Dot("call" + service.name).Op("=").Nil()
+				}
+				g.Add(registry()).Dot("mu").Dot("Unlock").Call()
+
+				g.Line()
+
+				g.Close(jen.Id("call").Dot("done"))
+
+				g.Line()
+
+				g.If(jen.Id("p").Op("!=").Nil()).Block(
+					jen.Panic(jen.Id("p")),
+				)
+			}).Call()
+
+			g.Line()
+
+			g.If(jen.Id("hooks").Op(":=").Id("child").Dot("registry").Dot("hooks"), jen.Id("hooks").Op("!=").Nil()).Block(
+				jen.Id("child").Dot("ctx").Op("=").Add(hookCall("child", service, "FactoryStart")),
+			)
+			g.Id("start").Op("=").Qual("time", "Now").Call()
+
+			g.Line()
+
+			g.Id("call").Dot("instance").Op(",").Id("call").Dot("err").Op("=").Id("factory").CallFunc(func(g *jen.Group) {
+				g.Id("child").Dot("ctx")
+				ifNamed(service.named, g, jen.Id("serviceName"))
+				g.Id("child")
+			})
+			g.If(jen.Id("call").Dot("err").Op("==").Nil()).Block(
+				jen.Id("call").Dot("instance").Op(",").Id("call").Dot("err").Op("=").Id("decorate").Call(jen.Id("child"), jen.Id("call").Dot("instance"), jen.Id("decorators")),
+			)
+
+			g.Line()
+
+			g.Id("duration").Op(":=").Qual("time", "Since").Call(jen.Id("start"))
+
+			g.Line()
+
+			g.Add(callHook("child", service, "FactoryEnd", jen.Id("duration"), jen.Id("call").Dot("err")))
+
+			g.Line()
+
+			g.If(jen.Id("call").Dot("err").Op("!=").Nil()).Block(
+				jen.Id("call").Dot("err").Op("=").Id("child").Dot("constructionError").CallFunc(func(g *jen.Group) {
+					g.Lit(service.name)
+					if service.named {
+						g.Id("serviceName")
+					} else {
+						g.Lit("")
+					}
+					g.Id("call").Dot("err")
+				}),
+			)
+
+			g.Line()
+
+			registryClosed := jen.If(registry().Dot("closed")).Block(
+				jen.Id("closedErr").Op("=").Id("Err" + cfg.registryName + "Closed"),
+			)
+			if service.scope == scopeScoped {
+				registryClosed.Else().If(jen.Id("s").Dot("closed")).Block(
+					jen.Id("closedErr").Op("=").Id("Err" + cfg.scopeName() + "Closed"),
+				)
+			}
+
+			g.Add(registry()).Dot("mu").Dot("Lock").Call()
+			g.Var().Id("closedErr").Error()
+			g.Add(registryClosed)
+			g.If(jen.Id("call").Dot("err").Op("==").Nil().Op("&&").Id("closedErr").Op("==").Nil()).BlockFunc(func(g *jen.Group) {
+				store := func(g *jen.Group) {
+					if service.named {
+						g.Add(self()).Dot("instances" + service.name).Index(jen.Id("serviceName")).Op("=").Id("call").Dot("instance")
+					} else {
+						g.Add(self()).Dot("instance" + service.name).Op("=").Id("call").Dot("instance")
+						g.Add(self()).Dot("constructed" + service.name).Op("=").True()
+					}
+
+					if service.scope == scopeSingleton {
+						g.Id("r").Dot("instanceInfo").Index(jen.Id("serviceID").CallFunc(serviceArgs)).Op("=").Id("ServiceInstanceInfo").ValuesFunc(func(g *jen.Group) {
+							if service.named {
+								g.Id("Name").Op(":").Id("serviceName")
+							}
+							g.Id("ConstructedAt").Op(":").Id("start")
+							g.Id("Duration").Op(":").Id("duration")
+						})
+					}
+				}
+
+				switch {
+				case service.scope == scopeSingleton && service.named:
+					g.Comment("The instance is not cached if the factory was replaced (or removed) during the construction:")
+					g.Comment("pattern and default factories only matter for names without a factory of their own")
+					g.If(
+						jen.Id("r").Dot("generations" + service.name).Index(jen.Id("serviceName")).Op("==").Id("generation").
+							Op("&&").Parens(jen.Id("exact").Op("||").Id("r").Dot("fallbackGeneration" + service.name).Op("==").Id("fallbackGeneration")),
+					).BlockFunc(store)
+				case service.scope == scopeSingleton:
+					g.Comment("The instance is not cached if the factory was replaced (or removed) during the construction")
+					g.If(jen.Id("r").Dot("generation" + service.name).Op("==").Id("generation")).BlockFunc(store)
+				default:
+					store(g)
+				}
+
+				g.Line()
+
+				g.Add(self()).Dot("instanceOrder").Op("=").Append(self().Dot("instanceOrder"), jen.Id("call").Dot("instance"))
+			})
+			g.Add(registry()).Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.Comment("Instances constructed after closing would never be closed otherwise")
+			g.If(jen.Id("call").Dot("err").Op("==").Nil().Op("&&").Id("closedErr").Op("!=").Nil()).Block(
+				jen.Id("call").Dot("err").Op("=").Id("closedErr"),
+				jen.Line(),
+				jen.If(
+					jen.Err().Op(":=").Id("closeInstances").Call(jen.Id("child").Dot("ctx"), jen.Index().Any().Values(jen.Id("call").Dot("instance"))),
+					jen.Err().Op("!=").Nil(),
+				).Block(
+					jen.Id("call").Dot("err").Op("=").Qual("errors", "Join").Call(jen.Id("closedErr"), jen.Err()),
+				),
+			)
+
+			g.Line()
+
+			g.If(jen.Id("call").Dot("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Id("zero"), jen.Id("call").Dot("err")),
+			)
+
+			g.Line()
+
+			g.Return(jen.Id("call").Dot("instance"), jen.Nil())
+		})
+}
+
+// generateGetAll generates methods resolving every registered name of a named service.
+//
+// newContext creates the context of the resolution (from ctx).
+func generateGetAll(f *jen.File, service serviceDefinition, receiver jen.Code, self string, typeName string, newContext *jen.Statement) {
+	f.Commentf("GetAll%s retrieves an instance of {%s} for every registered name.", service.name, service.name)
+	f.Func().
+		Params(receiver).Id("GetAll"+service.name).
+		Params().
+		Params(jen.Map(jen.String()).Add(service.typeCode()), jen.Error()).
+		Block(
+			jen.Return(jen.Id(self).Dot("GetAll" + service.name + "Context").Call(jen.Qual("context", "Background").Call())),
+		)
+
+	f.Line()
+
+	f.Commentf("GetAll%sContext is like {%s.GetAll%s}, but passes ctx to the factories called during the resolution.", service.name, typeName, service.name)
+	f.Func().
+		Params(receiver).Id("GetAll"+service.name+"Context").
+		Params(jen.Id("ctx").Qual("context", "Context")).
+		Params(jen.Map(jen.String()).Add(service.typeCode()), jen.Error()).
+		Block(
+			jen.Return(newContext.Dot("GetAll" + service.name).Call()),
+		)
+}
+
+// callHook generates calling a method of the resolution hooks of the registry (if any).
+//
+// c is the name of the variable holding the context of the resolution.
+func callHook(c string, service serviceDefinition, hook string, args ...jen.Code) *jen.Statement {
+	return jen.If(jen.Id("hooks").Op(":=").Id(c).Dot("registry").Dot("hooks"), jen.Id("hooks").Op("!=").Nil()).Block(
+		hookCall(c, service, hook, args...),
+	)
+}
+
+// hookCall generates calling a hook held by the hooks variable (see callHook).
+func hookCall(c string, service serviceDefinition, hook string, args ...jen.Code) *jen.Statement {
+	return jen.Id("hooks").Dot(hook).CallFunc(func(g *jen.Group) {
+		g.Id(c).Dot("ctx")
+		g.Lit(service.name)
+		if service.named {
+			g.Id("serviceName")
+		} else {
+			g.Lit("")
+		}
+		g.Id(c).Dot("path").Call()
+
+		for _, arg := range args {
+			g.Add(arg)
+		}
+	})
+}
+
+// generateTransientServiceGetter generates the getter of services constructed every time they are requested.
+func generateTransientServiceGetter(f *jen.File, cfg config, service serviceDefinition) {
+	f.Func().
+		Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("get"+service.name).
+		ParamsFunc(func(g *jen.Group) {
+			ifNamed(service.named, g, jen.Id("serviceName").String())
+			g.Id("c").Op("*").Id("serviceLocationContext")
+		}).
+		Params(service.typeCode(), jen.Error()).
+		BlockFunc(func(g *jen.Group) {
+			serviceArgs := func(g *jen.Group) {
+				g.Lit(service.name)
+				if service.named {
+					g.Id("serviceName")
+				} else {
+					g.Lit("")
+				}
+			}
+
+			g.Var().Id("zero").Add(service.typeCode())
+
+			g.Line()
+
+			g.Add(callHook("c", service, "ResolveStart"))
+
+			g.Line()
+
+			g.Comment("Transient services may be requested multiple times during the same resolution,")
+			g.Comment("but not while being constructed.")
+			g.If(jen.Id("c").Dot("isConstructing").CallFunc(serviceArgs)).Block(
+				callHook("c", service, "CircularDependency"),
+				jen.Line(),
+				jen.Return(jen.Id("zero"), jen.Id("c").Dot("circularDependencyError").CallFunc(serviceArgs)),
+			)
+
+			g.Line()
+
+			g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Id("closed").Op(":=").Id("r").Dot("closed")
+			g.Id("r").Dot("recordDependency").CallFunc(func(g *jen.Group) {
+				g.Id("c")
+				g.Lit(service.name)
+				if service.named {
+					g.Id("serviceName")
+				} else {
+					g.Lit("")
+				}
+			})
+			if service.named {
+				g.Id("factory, factoryOk").Op(":=").Id("r").Dot("lookup" + service.name).Call(jen.Id("serviceName"))
+			} else {
+				g.Id("factory").Op(":=").Id("r").Dot("factory" + service.name)
+				g.Id("factoryOk").Op(":=").Id("factory").Op("!=").Nil()
+			}
+			if service.named {
+				g.Id("decorators").Op(":=").Id("r").Dot("decorators" + service.name).Index(jen.Id("serviceName"))
+			} else {
+				g.Id("decorators").Op(":=").Id("r").Dot("decorators" + service.name)
+			}
+			g.Id("r").Dot("mu").Dot("Unlock").Call()
+
+			g.Line()
+
+			g.If(jen.Id("closed")).Block(
+				jen.Return(jen.Id("zero"), jen.Id("Err"+cfg.registryName+"Closed")),
+			)
+
+			g.Line()
+
+			g.If(jen.Op("!").Id("factoryOk")).BlockFunc(func(g *jen.Group) {
+				g.Return(jen.Id("zero"), jen.Id("ServiceNotRegisteredError").Values(jen.DictFunc(func(d jen.Dict) {
+					d[jen.Id("ServiceType")] = jen.Lit(service.name)
+					if service.named {
+						d[jen.Id("ServiceName")] = jen.Id("serviceName")
+					}
+					d[jen.Id("Path")] = jen.Id("c").Dot("path").Call()
+				})))
+			})
+
+			g.Line()
+
+			g.If(jen.Id("err").Op(":=").Id("c").Dot("ctx").Dot("Err").Call(), jen.Id("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Id("zero"), jen.Id("err")),
+			)
+
+			g.Line()
+
+			g.Id("child, err").Op(":=").Id("c").Dot("enter").CallFunc(serviceArgs)
+			g.If(jen.Id("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Id("zero"), jen.Id("err")),
+			)
+
+			g.Line()
 
-		// Private get method
-		f.Func().
-			Params(jen.Id("r").Op("*").Id("ServiceRegistry")).Id("get"+service.name).
-			ParamsFunc(func(g *jen.Group) {
-				ifNamed(service.named, g, jen.Id("serviceName").String())
-				g.Id("ctx").Op("*").Id("serviceLocationContext")
-			}).
-			Params(jen.Qual(service.importPath, service.name), jen.Error()).
-			BlockFunc(func(g *jen.Group) {
-				g.Id("r").Dot("mu").Dot("Lock").Call()
+			g.Var().Id("start").Qual("time", "Time")
 
-				if service.named {
-					g.Id("instance, instanceOk").Op(":=").Id("r").Dot("instances" + service.name).Index(jen.Id("serviceName"))
-					g.Id("factory, factoryOk").Op(":=").Id("r").Dot("factories" + service.name).Index(jen.Id("serviceName"))
-				} else {
-					g.Id("instance").Op(":=").Id("r").Dot("instance" + service.name)
-					g.Id("instanceOk").Op(":=").Id("instance").Op("!=").Nil()
-					g.Id("factory").Op(":=").Id("r").Dot("factory" + service.name)
-					g.Id("factoryOk").Op(":=").Id("factory").Op("!=").Nil()
-				}
+			g.Line()
 
-				g.Id("r").Dot("mu").Dot("Unlock").Call()
+			g.Comment("Spans started by FactoryStart must end if the factory (or a decorator) panics")
+			g.Defer().Func().Params().Block(
+				jen.If(jen.Id("p").Op(":=").Recover(), jen.Id("p").Op("!=").Nil()).Block(
+					callHook("child", service, "FactoryEnd", jen.Qual("time", "Since").Call(jen.Id("start")), jen.Qual("fmt", "Errorf").Call(jen.Lit("panic: %v"), jen.Id("p"))),
+					jen.Line(),
+					jen.Panic(jen.Id("p")),
+				),
+			).Call()
 
-				g.Line()
+			g.Line()
 
-				g.If(jen.Id("instanceOk")).Block(
-					jen.Return(jen.Id("instance"), jen.Nil()),
-				)
+			g.If(jen.Id("hooks").Op(":=").Id("child").Dot("registry").Dot("hooks"), jen.Id("hooks").Op("!=").Nil()).Block(
+				jen.Id("child").Dot("ctx").Op("=").Add(hookCall("child", service, "FactoryStart")),
+			)
+			g.Id("start").Op("=").Qual("time", "Now").Call()
 
-				g.Line()
+			g.Line()
 
-				g.If(jen.Id("ctx").Dot("isVisited" + service.name).CallFunc(ifNamedFunc(service.named, jen.Id("serviceName")))).Block(
-					jen.Return(
-						jen.Nil(),
-						jen.Id("newCircularDependencyError").CallFunc(func(g *jen.Group) {
-							g.Lit(service.name)
-							if service.named {
-								g.Id("serviceName")
-							} else {
-								g.Lit("")
-							}
-							g.Id("ctx").Dot("dependencyGraph")
-						}),
-					),
-				)
+			g.Id("instance, err").Op(":=").Id("factory").CallFunc(func(g *jen.Group) {
+				g.Id("child").Dot("ctx")
+				ifNamed(service.named, g, jen.Id("serviceName"))
+				g.Id("child")
+			})
+			g.If(jen.Id("err").Op("==").Nil()).Block(
+				jen.Id("instance, err").Op("=").Id("decorate").Call(jen.Id("child"), jen.Id("instance"), jen.Id("decorators")),
+			)
 
-				g.Id("ctx").Dot("markVisited" + service.name).CallFunc(ifNamedFunc(service.named, jen.Id("serviceName")))
+			g.Line()
 
-				g.Line()
+			g.Add(callHook("child", service, "FactoryEnd", jen.Qual("time", "Since").Call(jen.Id("start")), jen.Id("err")))
+
+			g.Line()
 
-				g.If(jen.Op("!").Id("factoryOk")).BlockFunc(func(g *jen.Group) {
+			g.If(jen.Id("err").Op("!=").Nil()).Block(
+				jen.Return(jen.Id("zero"), jen.Id("child").Dot("constructionError").CallFunc(func(g *jen.Group) {
+					g.Lit(service.name)
 					if service.named {
-						g.Return(jen.Nil(), jen.Qual("fmt", "Errorf").Call(
-							jen.Lit("no factory registered for "+service.name+" with name '%s'"),
-							jen.Id("serviceName"),
-						))
+						g.Id("serviceName")
 					} else {
-						g.Return(jen.Nil(), jen.Qual("errors", "New").Call(
-							jen.Lit("no factory registered for "+service.name),
-						))
+						g.Lit("")
 					}
-				})
-
-				g.Line()
-
-				g.Id("instance, err").Op(":=").Id("factory").CallFunc(func(g *jen.Group) {
-					ifNamed(service.named, g, jen.Id("serviceName"))
-					g.Id("ctx")
-				})
-				g.If(jen.Id("err").Op("!=").Nil()).Block(
-					jen.Return(jen.Nil(), jen.Id("err")),
-				)
-
-				g.Line()
-
-				g.Id("r").Dot("mu").Dot("Lock").Call()
-				if service.named {
-					g.Id("r").Dot("instances" + service.name).Index(jen.Id("serviceName")).Op("=").Id("instance")
-				} else {
-					g.Id("r").Dot("instance" + service.name).Op("=").Id("instance")
-				}
-				g.Id("r").Dot("mu").Dot("Unlock").Call()
+					g.Id("err")
+				})),
+			)
 
-				g.Line()
+			g.Line()
 
-				g.Return(jen.Id("instance"), jen.Nil())
-			})
-	}
+			g.Return(jen.Id("instance"), jen.Nil())
+		})
 }
 
-func generateServiceLocationContext(f *jen.File, services []serviceDefinition) {
-	f.Type().Id("serviceLocationContext").StructFunc(func(g *jen.Group) {
-		g.Id("registry").Op("*").Id("ServiceRegistry")
-		g.Id("dependencyGraph").Index().String()
+func generateServiceScope(f *jen.File, cfg config, services []serviceDefinition) {
+	f.Commentf("%s caches instances of scoped services (eg. for the duration of a request).", cfg.scopeName())
+	f.Commentf("%s implements {%s}: other services are resolved from the parent {%s}.", cfg.scopeName(), cfg.interfaceName, cfg.registryName)
+	f.Type().Id(cfg.scopeName()).StructFunc(func(g *jen.Group) {
+		g.Id("registry").Op("*").Id(cfg.registryName)
 
 		g.Line()
 
-		g.Id("visitLock").Qual("sync", "Mutex")
-
-		g.Line()
+		g.Comment("The state of the scope is guarded by the registry lock.")
 
 		for _, service := range services {
+			if service.scope != scopeScoped {
+				continue
+			}
+
 			if service.named {
-				g.Id("visited" + service.name).Map(jen.String()).Bool()
+				g.Id("instances" + service.name).Map(jen.String()).Add(service.typeCode())
+				g.Id("calls" + service.name).Map(jen.String()).Op("*").Id("serviceCall").Types(service.typeCode())
 			} else {
-				g.Id("visited" + service.name).Bool()
+				g.Id("instance" + service.name).Add(service.typeCode())
+				g.Id("constructed" + service.name).Bool()
+				g.Id("call" + service.name).Op("*").Id("serviceCall").Types(service.typeCode())
 			}
 		}
+
+		g.Line()
+
+		g.Comment("instanceOrder records instances in construction order, so they can be closed in reverse order")
+		g.Id("instanceOrder").Index().Any()
+		g.Id("closed").Bool()
 	})
 
-	f.Func().Id("newServiceLocationContext").Params(jen.Id("registry").Op("*").Id("ServiceRegistry"), jen.Id("maxDepth").Int()).Op("*").Id("serviceLocationContext").Block(
-		jen.Return(jen.Op("&").Id("serviceLocationContext").ValuesFunc(func(g *jen.Group) {
-			g.Id("registry").Op(":").Id("registry")
+	f.Commentf("Err%sClosed is returned when retrieving a service from a closed {%s}.", cfg.scopeName(), cfg.scopeName())
+	f.Var().Id("Err"+cfg.scopeName()+"Closed").Op("=").Qual("errors", "New").Call(jen.Lit(strings.ToLower(splitCamelCase(cfg.scopeName())) + " is closed"))
+
+	f.Line()
+
+	f.Commentf("NewScope creates a new {%s}.", cfg.scopeName())
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("NewScope").Params().Op("*").Id(cfg.scopeName()).Block(
+		jen.Return(jen.Op("&").Id(cfg.scopeName()).ValuesFunc(func(g *jen.Group) {
+			g.Id("registry").Op(":").Id("r")
 
 			for _, service := range services {
-				if service.named {
-					g.Id("visited" + service.name).Op(":").Make(jen.Map(jen.String()).Bool())
+				if service.scope != scopeScoped || !service.named {
+					continue
 				}
+
+				g.Id("instances" + service.name).Op(":").Make(jen.Map(jen.String()).Add(service.typeCode()))
+				g.Id("calls" + service.name).Op(":").Make(jen.Map(jen.String()).Op("*").Id("serviceCall").Types(service.typeCode()))
 			}
 		})),
 	)
@@ -457,58 +2602,470 @@ func generateServiceLocationContext(f *jen.File, services []serviceDefinition) {
 		f.Line()
 
 		// Get method
+		if service.scope == scopeTransient {
+			f.Commentf("Get%s creates a new instance of {%s}.", service.name, service.name)
+		} else {
+			f.Commentf("Get%s retrieves an instance of {%s}.", service.name, service.name)
+		}
 		f.Func().
-			Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("Get"+service.name).
+			Params(jen.Id("s").Op("*").Id(cfg.scopeName())).Id("Get"+service.name).
 			ParamsFunc(ifNamedFunc(service.named, jen.Id("serviceName").String())).
-			Params(jen.Qual(service.importPath, service.name), jen.Error()).
+			Params(service.typeCode(), jen.Error()).
 			Block(
-				jen.Return(jen.Id("c").Dot("registry").Dot("get" + service.name).CallFunc(func(g *jen.Group) {
+				jen.Return(jen.Id("s").Dot("Get" + service.name + "Context").CallFunc(func(g *jen.Group) {
+					g.Qual("context", "Background").Call()
 					ifNamed(service.named, g, jen.Id("serviceName"))
-					g.Id("c")
 				})),
 			)
 
 		f.Line()
 
-		// isVisited method
+		f.Commentf("Get%sContext is like {%s.Get%s}, but passes ctx to the factories called during the resolution.", service.name, cfg.scopeName(), service.name)
 		f.Func().
-			Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("isVisited" + service.name).
-			ParamsFunc(ifNamedFunc(service.named, jen.Id("serviceName").String())).
-			Bool().
-			BlockFunc(func(g *jen.Group) {
-				g.Id("c").Dot("visitLock").Dot("Lock").Call()
-				g.Defer().Id("c").Dot("visitLock").Dot("Unlock").Call()
+			Params(jen.Id("s").Op("*").Id(cfg.scopeName())).Id("Get"+service.name+"Context").
+			ParamsFunc(func(g *jen.Group) {
+				g.Id("ctx").Qual("context", "Context")
+				ifNamed(service.named, g, jen.Id("serviceName").String())
+			}).
+			Params(service.typeCode(), jen.Error()).
+			Block(
+				jen.Return(jen.Id("newServiceLocationContext").Call(jen.Id("ctx"), jen.Id("s").Dot("registry"), jen.Id("s"), jen.Id("s").Dot("registry").Dot("maxDepth")).Dot("Get" + service.name).CallFunc(ifNamedFunc(service.named, jen.Id("serviceName")))),
+			)
 
-				g.Line()
+		if service.named {
+			f.Line()
 
-				if service.named {
-					g.Return(jen.Id("c").Dot("visited" + service.name).Index(jen.Id("serviceName")))
-				} else {
-					g.Return(jen.Id("c").Dot("visited" + service.name))
-				}
-			})
+			f.Commentf("%sNames returns the names factories of {%s} are registered with (sorted).", service.name, service.name)
+			f.Func().
+				Params(jen.Id("s").Op("*").Id(cfg.scopeName())).Id(service.name + "Names").
+				Params().
+				Index().String().
+				Block(
+					jen.Return(jen.Id("s").Dot("registry").Dot(service.name + "Names").Call()),
+				)
+
+			f.Line()
+
+			generateGetAll(f, service, jen.Id("s").Op("*").Id(cfg.scopeName()), "s", cfg.scopeName(),
+				jen.Id("newServiceLocationContext").Call(jen.Id("ctx"), jen.Id("s").Dot("registry"), jen.Id("s"), jen.Id("s").Dot("registry").Dot("maxDepth")),
+			)
+		}
+
+		// Private get method
+		if service.scope == scopeScoped {
+			f.Line()
+			generateCachedServiceGetter(f, cfg, service)
+		}
+	}
+
+	f.Line()
+
+	f.Comment("Close closes instances of scoped services in reverse construction order.")
+	f.Commentf("Retrieving services from a closed scope returns {Err%sClosed}.", cfg.scopeName())
+	f.Comment("")
+	f.Comment("Instances implementing either {io.Closer} or Close(context.Context) error are closed.")
+	f.Comment("Instances still under construction when calling Close are closed when their construction completes")
+	f.Commentf("(and the pending resolutions return {Err%sClosed}).", cfg.scopeName())
+	f.Func().Params(jen.Id("s").Op("*").Id(cfg.scopeName())).Id("Close").Params(jen.Id("ctx").Qual("context", "Context")).Error().Block(
+		jen.Id("s").Dot("registry").Dot("mu").Dot("Lock").Call(),
+		jen.Id("s").Dot("closed").Op("=").True(),
+		jen.Id("instances").Op(":=").Id("s").Dot("instanceOrder"),
+		jen.Id("s").Dot("instanceOrder").Op("=").Nil(),
+		jen.Id("s").Dot("registry").Dot("mu").Dot("Unlock").Call(),
+		jen.Line(),
+		jen.Return(jen.Id("closeInstances").Call(jen.Id("ctx"), jen.Id("instances"))),
+	)
+}
+
+func generateCloseInstances(f *jen.File) {
+	f.Comment("closeInstances closes instances in reverse order and returns the combined errors.")
+	f.Func().Id("closeInstances").Params(jen.Id("ctx").Qual("context", "Context"), jen.Id("instances").Index().Any()).Error().Block(
+		jen.Var().Id("errs").Index().Error(),
+		jen.Line(),
+		jen.For(jen.Id("i").Op(":=").Len(jen.Id("instances")).Op("-").Lit(1), jen.Id("i").Op(">=").Lit(0), jen.Id("i").Op("--")).Block(
+			jen.Var().Id("err").Error(),
+			jen.Line(),
+			jen.Switch(jen.Id("instance").Op(":=").Id("instances").Index(jen.Id("i")).Assert(jen.Type())).Block(
+				jen.Case(jen.Interface(jen.Id("Close").Params(jen.Qual("context", "Context")).Error())).Block(
+					jen.Id("err").Op("=").Id("instance").Dot("Close").Call(jen.Id("ctx")),
+				),
+				jen.Case(jen.Qual("io", "Closer")).Block(
+					jen.Id("err").Op("=").Id("instance").Dot("Close").Call(),
+				),
+			),
+			jen.Line(),
+			jen.If(jen.Id("err").Op("!=").Nil()).Block(
+				jen.Id("errs").Op("=").Append(jen.Id("errs"), jen.Id("err")),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.Qual("errors", "Join").Call(jen.Id("errs").Op("..."))),
+	)
+}
+
+func generateServiceCall(f *jen.File) {
+	f.Comment("serviceCall tracks the construction of an instance of T,")
+	f.Comment("so that concurrent requests for the same instance wait for a single factory call.")
+	f.Type().Id("serviceCall").Types(jen.Id("T").Any()).Struct(
+		jen.Id("done").Chan().Struct(),
+		jen.Id("owner").Op("*").Id("serviceConstruction"),
+		jen.Line(),
+		jen.Id("instance").Id("T"),
+		jen.Id("err").Error(),
+	)
+
+	f.Comment("serviceWait records that a construction (or the start of a resolution if waiter is nil)")
+	f.Comment("is waiting for an instance constructed by another one.")
+	f.Type().Id("serviceWait").Struct(
+		jen.Id("waiter").Op("*").Id("serviceConstruction"),
+		jen.Id("owner").Op("*").Id("serviceConstruction"),
+	)
+}
+
+func generateServiceLocationContext(f *jen.File, cfg config, services []serviceDefinition) {
+	f.Commentf("serviceLocationContext is the {%s} passed to factories.", cfg.interfaceName)
+	f.Comment("")
+	f.Comment("Services located through it are resolved with the context the resolution started with.")
+	f.Comment("Factories receive a context of their own, so the path of the resolution is tracked")
+	f.Comment("even if factories resolve services concurrently or retry failed resolutions.")
+	f.Type().Id("serviceLocationContext").Struct(
+		jen.Id("ctx").Qual("context", "Context"),
+		jen.Id("registry").Op("*").Id(cfg.registryName),
+		jen.Id("scope").Op("*").Id(cfg.scopeName()),
+		jen.Line(),
+		jen.Comment("construction is the service constructed with this context (nil at the start of a resolution)"),
+		jen.Id("construction").Op("*").Id("serviceConstruction"),
+		jen.Id("maxDepth").Int(),
+	)
+
+	f.Comment("serviceConstruction is a service being constructed.")
+	f.Type().Id("serviceConstruction").Struct(
+		jen.Id("serviceType").String(),
+		jen.Id("serviceName").String(),
+		jen.Id("service").String(),
+		jen.Line(),
+		jen.Comment("parent is the service depending on this one (if any)"),
+		jen.Id("parent").Op("*").Id("serviceConstruction"),
+		jen.Id("depth").Int(),
+	)
 
+	f.Comment("contains checks if other is c or a dependency constructed (directly or indirectly) for c.")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceConstruction")).Id("contains").Params(jen.Id("other").Op("*").Id("serviceConstruction")).Bool().Block(
+		jen.For(jen.Empty(), jen.Id("other").Op("!=").Nil(), jen.Id("other").Op("=").Id("other").Dot("parent")).Block(
+			jen.If(jen.Id("other").Op("==").Id("c")).Block(
+				jen.Return(jen.True()),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.False()),
+	)
+
+	f.Line()
+
+	f.Func().Id("newServiceLocationContext").Params(
+		jen.Id("ctx").Qual("context", "Context"),
+		jen.Id("registry").Op("*").Id(cfg.registryName),
+		jen.Id("scope").Op("*").Id(cfg.scopeName()),
+		jen.Id("maxDepth").Int(),
+	).Op("*").Id("serviceLocationContext").Block(
+		jen.Return(jen.Op("&").Id("serviceLocationContext").Values(jen.Dict{
+			jen.Id("ctx"):      jen.Id("ctx"),
+			jen.Id("registry"): jen.Id("registry"),
+			jen.Id("scope"):    jen.Id("scope"),
+			jen.Id("maxDepth"): jen.Id("maxDepth"),
+		})),
+	)
+
+	f.Line()
+
+	f.Comment("unscoped returns a context for resolving services outside of the current scope.")
+	f.Comment("")
+	f.Comment("Singletons are cached by the registry, so they must not depend on instances of the current scope.")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("unscoped").Params().Op("*").Id("serviceLocationContext").Block(
+		jen.If(jen.Id("c").Dot("scope").Op("==").Nil()).Block(
+			jen.Return(jen.Id("c")),
+		),
+		jen.Line(),
+		jen.Id("unscoped").Op(":=").Op("*").Id("c"),
+		jen.Id("unscoped").Dot("scope").Op("=").Nil(),
+		jen.Line(),
+		jen.Return(jen.Op("&").Id("unscoped")),
+	)
+
+	f.Line()
+
+	f.Comment("enter returns the context for constructing a service,")
+	f.Comment("unless that would exceed the maximum depth of the resolution.")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("enter").Params(jen.Id("serviceType"), jen.Id("serviceName").String()).Params(jen.Op("*").Id("serviceLocationContext"), jen.Error()).Block(
+		jen.Id("depth").Op(":=").Lit(0),
+		jen.If(jen.Id("c").Dot("construction").Op("!=").Nil()).Block(
+			jen.Id("depth").Op("=").Id("c").Dot("construction").Dot("depth"),
+		),
+		jen.Line(),
+		jen.If(jen.Id("c").Dot("maxDepth").Op(">").Lit(0).Op("&&").Id("depth").Op(">=").Id("c").Dot("maxDepth")).Block(
+			jen.Return(jen.Nil(), jen.Id("MaxDepthExceededError").Values(jen.Dict{
+				jen.Id("ServiceType"):     jen.Id("serviceType"),
+				jen.Id("ServiceName"):     jen.Id("serviceName"),
+				jen.Id("MaxDepth"):        jen.Id("c").Dot("maxDepth"),
+				jen.Id("DependencyGraph"): jen.Id("c").Dot("path").Call(),
+			})),
+		),
+		jen.Line(),
+		jen.Id("child").Op(":=").Op("*").Id("c"),
+		jen.Id("child").Dot("construction").Op("=").Op("&").Id("serviceConstruction").Values(jen.Dict{
+			jen.Id("serviceType"): jen.Id("serviceType"),
+			jen.Id("serviceName"): jen.Id("serviceName"),
+			jen.Id("service"):     jen.Id("serviceID").Call(jen.Id("serviceType"), jen.Id("serviceName")),
+			jen.Id("parent"):      jen.Id("c").Dot("construction"),
+			jen.Id("depth"):       jen.Id("depth").Op("+").Lit(1),
+		}),
+		jen.Line(),
+		jen.Return(jen.Op("&").Id("child"), jen.Nil()),
+	)
+
+	f.Line()
+
+	f.Comment("path returns the services being constructed (the last one being the innermost).")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("path").Params().Index().String().Block(
+		jen.Var().Id("path").Index().String(),
+		jen.Line(),
+		jen.For(jen.Id("construction").Op(":=").Id("c").Dot("construction"), jen.Id("construction").Op("!=").Nil(), jen.Id("construction").Op("=").Id("construction").Dot("parent")).Block(
+			jen.Id("path").Op("=").Append(jen.Id("path"), jen.Id("construction").Dot("service")),
+		),
+		jen.Line(),
+		jen.For(jen.Id("i, j").Op(":=").Lit(0).Op(",").Len(jen.Id("path")).Op("-").Lit(1), jen.Id("i").Op("<").Id("j"), jen.Id("i, j").Op("=").Id("i").Op("+").Lit(1).Op(",").Id("j").Op("-").Lit(1)).Block(
+			jen.Id("path").Index(jen.Id("i")).Op(",").Id("path").Index(jen.Id("j")).Op("=").Id("path").Index(jen.Id("j")).Op(",").Id("path").Index(jen.Id("i")),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("path")),
+	)
+
+	f.Line()
+
+	f.Comment("isConstructing checks if a service is being constructed by the current resolution path.")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("isConstructing").Params(jen.Id("serviceType"), jen.Id("serviceName").String()).Bool().Block(
+		jen.Id("service").Op(":=").Id("serviceID").Call(jen.Id("serviceType"), jen.Id("serviceName")),
+		jen.Line(),
+		jen.For(jen.Id("construction").Op(":=").Id("c").Dot("construction"), jen.Id("construction").Op("!=").Nil(), jen.Id("construction").Op("=").Id("construction").Dot("parent")).Block(
+			jen.If(jen.Id("construction").Dot("service").Op("==").Id("service")).Block(
+				jen.Return(jen.True()),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.False()),
+	)
+
+	f.Line()
+
+	f.Comment("circularDependencyError reports that resolving a service would result in a circular dependency.")
+	f.Comment("")
+	f.Comment("If the service is being constructed by the current resolution path, the error only contains the cycle.")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("circularDependencyError").Params(jen.Id("serviceType"), jen.Id("serviceName").String()).Id("CircularDependencyError").Block(
+		jen.Id("service").Op(":=").Id("serviceID").Call(jen.Id("serviceType"), jen.Id("serviceName")),
+		jen.Id("path").Op(":=").Id("c").Dot("path").Call(),
+		jen.Line(),
+		jen.For(jen.Id("i").Op(",").Id("s").Op(":=").Range().Id("path")).Block(
+			jen.If(jen.Id("s").Op("==").Id("service")).Block(
+				jen.Id("path").Op("=").Id("path").Index(jen.Id("i"), jen.Empty()),
+				jen.Line(),
+				jen.Break(),
+			),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("newCircularDependencyError").Call(jen.Id("serviceType"), jen.Id("serviceName"), jen.Append(jen.Id("path"), jen.Id("service")))),
+	)
+
+	f.Line()
+
+	f.Comment("constructionError wraps an error returned by the factory of a service,")
+	f.Comment("unless the error is already reported by the service locator (eg. by a dependency of the service).")
+	f.Func().Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("constructionError").Params(jen.Id("serviceType"), jen.Id("serviceName").String(), jen.Id("err").Error()).Error().Block(
+		jen.Var().Id("serviceLocatorErr").Id("serviceLocatorError"),
+		jen.If(jen.Qual("errors", "As").Call(jen.Id("err"), jen.Op("&").Id("serviceLocatorErr"))).Block(
+			jen.Return(jen.Id("err")),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("ServiceConstructionError").Values(jen.Dict{
+			jen.Id("ServiceType"): jen.Id("serviceType"),
+			jen.Id("ServiceName"): jen.Id("serviceName"),
+			jen.Id("Path"):        jen.Id("c").Dot("path").Call(),
+			jen.Id("Err"):         jen.Id("err"),
+		})),
+	)
+
+	f.Line()
+
+	for _, service := range services {
 		f.Line()
 
-		// markVisited method
+		// Get method
 		f.Func().
-			Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("markVisited" + service.name).
+			Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("Get"+service.name).
 			ParamsFunc(ifNamedFunc(service.named, jen.Id("serviceName").String())).
+			Params(service.typeCode(), jen.Error()).
 			BlockFunc(func(g *jen.Group) {
-				g.Id("c").Dot("visitLock").Dot("Lock").Call()
-				g.Defer().Id("c").Dot("visitLock").Dot("Unlock").Call()
+				switch service.scope {
+				case scopeScoped:
+					g.If(jen.Id("c").Dot("scope").Op("==").Nil()).Block(
+						jen.Var().Id("zero").Add(service.typeCode()),
+						jen.Line(),
+						jen.Return(jen.Id("zero"), jen.Qual("errors", "New").Call(
+							jen.Lit(service.name+" is a scoped service: it can only be retrieved from a "+cfg.scopeName()),
+						)),
+					)
+
+					g.Line()
+
+					g.Return(jen.Id("c").Dot("scope").Dot("get" + service.name).CallFunc(func(g *jen.Group) {
+						ifNamed(service.named, g, jen.Id("serviceName"))
+						g.Id("c")
+					}))
+
+				case scopeTransient:
+					g.Return(jen.Id("c").Dot("registry").Dot("get" + service.name).CallFunc(func(g *jen.Group) {
+						ifNamed(service.named, g, jen.Id("serviceName"))
+						g.Id("c")
+					}))
+
+				default:
+					g.Return(jen.Id("c").Dot("registry").Dot("get" + service.name).CallFunc(func(g *jen.Group) {
+						ifNamed(service.named, g, jen.Id("serviceName"))
+						g.Id("c").Dot("unscoped").Call()
+					}))
+				}
+			})
+
+		if service.named {
+			f.Line()
+
+			f.Func().
+				Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id(service.name + "Names").
+				Params().
+				Index().String().
+				Block(
+					jen.Return(jen.Id("c").Dot("registry").Dot(service.name + "Names").Call()),
+				)
+
+			f.Line()
+
+			f.Func().
+				Params(jen.Id("c").Op("*").Id("serviceLocationContext")).Id("GetAll"+service.name).
+				Params().
+				Params(jen.Map(jen.String()).Add(service.typeCode()), jen.Error()).
+				Block(
+					jen.Id("instances").Op(":=").Make(jen.Map(jen.String()).Add(service.typeCode())),
+					jen.Line(),
+					jen.For(jen.Id("_, serviceName").Op(":=").Range().Id("c").Dot(service.name+"Names").Call()).Block(
+						jen.Id("instance, err").Op(":=").Id("c").Dot("Get"+service.name).Call(jen.Id("serviceName")),
+						jen.If(jen.Id("err").Op("!=").Nil()).Block(
+							jen.Return(jen.Nil(), jen.Id("err")),
+						),
+						jen.Line(),
+						jen.Id("instances").Index(jen.Id("serviceName")).Op("=").Id("instance"),
+					),
+					jen.Line(),
+					jen.Return(jen.Id("instances"), jen.Nil()),
+				)
+		}
+	}
+}
+
+// generatedMethods lists the exported methods of the registry and the scope not derived from the services.
+var generatedMethods = map[string]bool{
+	"Close":           true,
+	"DebugHandler":    true,
+	"DependencyGraph": true,
+	"InitAll":         true,
+	"NewScope":        true,
+	"Services":        true,
+	"Validate":        true,
+}
+
+// generateSkippedMethods generates stubs for the interface methods skipped by the generator,
+// so that the registry, the scope and the service location context still implement the interface.
+//
+// Stubs return an error.
+// Methods that do not return an error or clash with the generated ones cannot be stubbed: they fail the generation.
+func generateSkippedMethods(f *jen.File, cfg config, services []serviceDefinition, diagnostics []diagnostic) error {
+	reserved := make(map[string]bool, len(generatedMethods))
+	for name := range generatedMethods {
+		reserved[name] = true
+	}
+
+	for _, service := range services {
+		for _, name := range []string{"Get" + service.name + "Context", "GetAll" + service.name, "GetAll" + service.name + "Context", service.name + "Names"} {
+			reserved[name] = true
+		}
+	}
+
+	for _, d := range diagnostics {
+		name := d.method
+
+		switch {
+		case !token.IsExported(name):
+			return fmt.Errorf("%s: method %s cannot be implemented by the generated code: method is not exported", d.pos, name)
+
+		case reserved[name] || strings.HasPrefix(name, "Register") || strings.HasPrefix(name, "Unregister") || strings.HasPrefix(name, "Decorate"):
+			return fmt.Errorf("%s: method %s cannot be implemented by the generated code: name is used by the registry", d.pos, name)
+		}
+
+		params, err := tupleCode(d.sig.Params(), d.sig.Variadic())
+		if err != nil {
+			return fmt.Errorf("%s: method %s cannot be implemented by the generated code: %w", d.pos, name, err)
+		}
 
+		results := d.sig.Results()
+
+		if results.Len() == 0 || results.At(results.Len()-1).Type().String() != "error" {
+			return fmt.Errorf("%s: method %s cannot be implemented by the generated code: method does not return an error", d.pos, name)
+		}
+
+		resultCodes, err := tupleCode(results, false)
+		if err != nil {
+			return fmt.Errorf("%s: method %s cannot be implemented by the generated code: %w", d.pos, name, err)
+		}
+
+		message := fmt.Sprintf("%s.%s does not describe a service", cfg.interfaceName, name)
+
+		body := func(g *jen.Group) {
+			for i := 0; i < results.Len()-1; i++ {
+				g.Var().Id(fmt.Sprintf("zero%d", i)).Add(resultCodes[i])
+			}
+
+			if results.Len() > 1 {
 				g.Line()
+			}
 
-				if service.named {
-					g.Id("c").Dot("visited" + service.name).Index(jen.Id("serviceName")).Op("=").Lit(true)
-					g.Id("c").Dot("dependencyGraph").Op("=").Append(jen.Id("c").Dot("dependencyGraph"), jen.Lit(service.name+":").Op("+").Id("serviceName"))
-				} else {
-					g.Id("c").Dot("visited" + service.name).Op("=").Lit(true)
-					g.Id("c").Dot("dependencyGraph").Op("=").Append(jen.Id("c").Dot("dependencyGraph"), jen.Lit(service.name))
+			g.ReturnFunc(func(g *jen.Group) {
+				for i := 0; i < results.Len()-1; i++ {
+					g.Id(fmt.Sprintf("zero%d", i))
 				}
+				g.Qual("errors", "New").Call(jen.Lit(message))
 			})
+		}
+
+		receivers := []jen.Code{
+			jen.Id("r").Op("*").Id(cfg.registryName),
+			jen.Id("s").Op("*").Id(cfg.scopeName()),
+			jen.Id("c").Op("*").Id("serviceLocationContext"),
+		}
+
+		for i, receiver := range receivers {
+			f.Line()
+
+			switch i {
+			case 0:
+				f.Commentf("%s is declared by {%s}, but it does not describe a service (%s).", name, cfg.interfaceName, d.reason)
+				f.Comment("It always returns an error.")
+			case 1:
+				f.Commentf("%s is like {%s.%s}.", name, cfg.registryName, name)
+			}
+
+			f.Func().Params(receiver).Id(name).Params(params...).Params(resultCodes...).BlockFunc(body)
+		}
 	}
+
+	return nil
 }
 
 func generateCircularDependencyError(f *jen.File) {
@@ -533,11 +3090,414 @@ func generateCircularDependencyError(f *jen.File) {
 
 	f.Func().Params(jen.Id("e").Id("CircularDependencyError")).Id("Error").Params().String().Block(
 		jen.Id("dependencyPath").Op(":=").Qual("strings", "Join").Call(jen.Id("e").Dot("DependencyGraph"), jen.Lit(" -> ")),
+		jen.Line(),
+		jen.If(jen.Id("e").Dot("ServiceName").Op("!=").Lit("")).Block(
+			jen.Return(jen.Qual("fmt", "Sprintf").Call(
+				jen.Lit("circular dependency detected for %s '%s': %s"),
+				jen.Id("e").Dot("ServiceType"),
+				jen.Id("e").Dot("ServiceName"),
+				jen.Id("dependencyPath"),
+			)),
+		),
+		jen.Line(),
+		jen.Return(jen.Qual("fmt", "Sprintf").Call(
+			jen.Lit("circular dependency detected for %s: %s"),
+			jen.Id("e").Dot("ServiceType"),
+			jen.Id("dependencyPath"),
+		)),
+	)
+
+	f.Func().Params(jen.Id("CircularDependencyError")).Id("serviceLocatorError").Params().Block()
+}
+
+func generateMaxDepthExceededError(f *jen.File) {
+	f.Comment("MaxDepthExceededError is returned when resolving a service exceeds the maximum depth of dependencies.")
+	f.Type().Id("MaxDepthExceededError").Struct(
+		jen.Id("ServiceType").String(),
+		jen.Id("ServiceName").String(),
+		jen.Id("MaxDepth").Int(),
+		jen.Id("DependencyGraph").Index().String(),
+	)
+
+	f.Func().Params(jen.Id("e").Id("MaxDepthExceededError")).Id("Error").Params().String().Block(
+		jen.Id("dependencyPath").Op(":=").Qual("strings", "Join").Call(jen.Id("e").Dot("DependencyGraph"), jen.Lit(" -> ")),
+		jen.Line(),
+		jen.If(jen.Id("e").Dot("ServiceName").Op("!=").Lit("")).Block(
+			jen.Return(jen.Qual("fmt", "Sprintf").Call(
+				jen.Lit("maximum depth (%d) exceeded for %s '%s': %s"),
+				jen.Id("e").Dot("MaxDepth"),
+				jen.Id("e").Dot("ServiceType"),
+				jen.Id("e").Dot("ServiceName"),
+				jen.Id("dependencyPath"),
+			)),
+		),
+		jen.Line(),
+		jen.Return(jen.Qual("fmt", "Sprintf").Call(
+			jen.Lit("maximum depth (%d) exceeded for %s: %s"),
+			jen.Id("e").Dot("MaxDepth"),
+			jen.Id("e").Dot("ServiceType"),
+			jen.Id("dependencyPath"),
+		)),
+	)
+
+	f.Func().Params(jen.Id("MaxDepthExceededError")).Id("serviceLocatorError").Params().Block()
+}
+
+func generateServiceNotRegisteredError(f *jen.File) {
+	f.Comment("ServiceNotRegisteredError is returned when there is no factory registered for a service.")
+	f.Comment("")
+	f.Comment("Path lists the services being constructed when the service was requested (empty if it was requested directly).")
+	f.Type().Id("ServiceNotRegisteredError").Struct(
+		jen.Id("ServiceType").String(),
+		jen.Id("ServiceName").String(),
+		jen.Id("Path").Index().String(),
+	)
+
+	f.Func().Params(jen.Id("e").Id("ServiceNotRegisteredError")).Id("Error").Params().String().Block(
+		jen.Id("msg").Op(":=").Lit("no factory registered for ").Op("+").Id("e").Dot("ServiceType"),
+		jen.If(jen.Id("e").Dot("ServiceName").Op("!=").Lit("")).Block(
+			jen.Id("msg").Op("+=").Qual("fmt", "Sprintf").Call(jen.Lit(" with name '%s'"), jen.Id("e").Dot("ServiceName")),
+		),
+		jen.Line(),
+		jen.If(jen.Len(jen.Id("e").Dot("Path")).Op(">").Lit(0)).Block(
+			jen.Id("msg").Op("+=").Lit(" (required by ").Op("+").Qual("strings", "Join").Call(jen.Id("e").Dot("Path"), jen.Lit(" -> ")).Op("+").Lit(")"),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("msg")),
+	)
+
+	f.Func().Params(jen.Id("ServiceNotRegisteredError")).Id("serviceLocatorError").Params().Block()
+}
+
+func generateServiceConstructionError(f *jen.File) {
+	f.Comment("ServiceConstructionError is returned when the factory of a service fails.")
+	f.Comment("")
+	f.Comment("Path lists the services visited by the resolution until the failure.")
+	f.Type().Id("ServiceConstructionError").Struct(
+		jen.Id("ServiceType").String(),
+		jen.Id("ServiceName").String(),
+		jen.Id("Path").Index().String(),
+		jen.Id("Err").Error(),
+	)
+
+	f.Func().Params(jen.Id("e").Id("ServiceConstructionError")).Id("Error").Params().String().Block(
+		jen.Id("dependencyPath").Op(":=").Qual("strings", "Join").Call(jen.Id("e").Dot("Path"), jen.Lit(" -> ")),
+		jen.Line(),
+		jen.If(jen.Id("e").Dot("ServiceName").Op("!=").Lit("")).Block(
+			jen.Return(jen.Qual("fmt", "Sprintf").Call(
+				jen.Lit("failed to construct %s '%s' (%s): %v"),
+				jen.Id("e").Dot("ServiceType"),
+				jen.Id("e").Dot("ServiceName"),
+				jen.Id("dependencyPath"),
+				jen.Id("e").Dot("Err"),
+			)),
+		),
+		jen.Line(),
 		jen.Return(jen.Qual("fmt", "Sprintf").Call(
-			jen.Lit("circular dependency detected for %s '%s': %s"),
+			jen.Lit("failed to construct %s (%s): %v"),
 			jen.Id("e").Dot("ServiceType"),
-			jen.Id("e").Dot("ServiceName"),
 			jen.Id("dependencyPath"),
+			jen.Id("e").Dot("Err"),
 		)),
 	)
+
+	f.Func().Params(jen.Id("e").Id("ServiceConstructionError")).Id("Unwrap").Params().Error().Block(
+		jen.Return(jen.Id("e").Dot("Err")),
+	)
+
+	f.Func().Params(jen.Id("ServiceConstructionError")).Id("serviceLocatorError").Params().Block()
+}
+
+func generateServiceLocatorError(f *jen.File) {
+	f.Comment("serviceLocatorError is implemented by errors reported by the service locator.")
+	f.Type().Id("serviceLocatorError").Interface(
+		jen.Id("serviceLocatorError").Params(),
+	)
+}
+
+func generateResolutionHooks(f *jen.File, cfg config) {
+	params := func(g *jen.Group) {
+		g.Id("ctx").Qual("context", "Context")
+		g.Id("serviceType")
+		g.Id("serviceName").String()
+		g.Id("path").Index().String()
+	}
+
+	f.Commentf("ResolutionHooks observe how a {%s} resolves services.", cfg.registryName)
+	f.Comment("")
+	f.Comment("Hooks receive the type and the name (empty for unnamed services) of the service")
+	f.Comment("and the path of services being constructed when the hook is called (the last one being the innermost).")
+	f.Comment("Hooks are called synchronously and concurrently: they must be safe for concurrent use and return quickly.")
+	f.Type().Id("ResolutionHooks").Interface(
+		jen.Comment("ResolveStart is called when a service is requested."),
+		jen.Id("ResolveStart").ParamsFunc(params),
+		jen.Line(),
+		jen.Comment("CacheHit is called when a service is resolved from a cached instance."),
+		jen.Id("CacheHit").ParamsFunc(params),
+		jen.Line(),
+		jen.Comment("FactoryStart is called before constructing a service (the service being the last element of the path)."),
+		jen.Comment("The returned context (ctx or a context derived from it, eg. carrying a tracing span)"),
+		jen.Comment("is passed to the factory, to the services it resolves and to FactoryEnd."),
+		jen.Id("FactoryStart").ParamsFunc(params).Qual("context", "Context"),
+		jen.Line(),
+		jen.Comment("FactoryEnd is called after constructing a service (including decorators) with the error returned by the factory."),
+		jen.Id("FactoryEnd").ParamsFunc(func(g *jen.Group) {
+			params(g)
+			g.Id("duration").Qual("time", "Duration")
+			g.Id("err").Error()
+		}),
+		jen.Line(),
+		jen.Comment("CircularDependency is called when resolving a service would result in a circular dependency."),
+		jen.Id("CircularDependency").ParamsFunc(params),
+	)
+}
+
+func generateDependencyGraph(f *jen.File) {
+	f.Comment("serviceID identifies a service in dependency graphs.")
+	f.Func().Id("serviceID").Params(jen.Id("serviceType"), jen.Id("serviceName").String()).String().Block(
+		jen.If(jen.Id("serviceName").Op("==").Lit("")).Block(
+			jen.Return(jen.Id("serviceType")),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("serviceType").Op("+").Lit(":").Op("+").Id("serviceName")),
+	)
+
+	f.Comment("DependencyGraph describes which services depend on which other services.")
+	f.Comment("")
+	f.Comment("Services are identified by their type (and name, separated by a colon, for named services).")
+	f.Comment("Names resolved by pattern or default factories are recorded as \"*\" (eg. \"Job:*\").")
+	f.Type().Id("DependencyGraph").Struct(
+		jen.Id("Services").Index().String().Tag(map[string]string{"json": "services"}),
+		jen.Id("Dependencies").Index().Id("ServiceDependency").Tag(map[string]string{"json": "dependencies"}),
+	)
+
+	f.Comment("ServiceDependency records that the factory of a service resolved another service.")
+	f.Type().Id("ServiceDependency").Struct(
+		jen.Id("Service").String().Tag(map[string]string{"json": "service"}),
+		jen.Id("Dependency").String().Tag(map[string]string{"json": "dependency"}),
+	)
+
+	f.Comment("DOT renders the graph in the Graphviz DOT language.")
+	f.Func().Params(jen.Id("g").Id("DependencyGraph")).Id("DOT").Params().String().Block(
+		jen.Var().Id("b").Qual("strings", "Builder"),
+		jen.Line(),
+		jen.Id("b").Dot("WriteString").Call(jen.Lit("digraph services {\n")),
+		jen.Line(),
+		jen.For(jen.Id("_, service").Op(":=").Range().Id("g").Dot("Services")).Block(
+			jen.Qual("fmt", "Fprintf").Call(jen.Op("&").Id("b"), jen.Lit("\t%q;\n"), jen.Id("service")),
+		),
+		jen.Line(),
+		jen.For(jen.Id("_, dependency").Op(":=").Range().Id("g").Dot("Dependencies")).Block(
+			jen.Qual("fmt", "Fprintf").Call(jen.Op("&").Id("b"), jen.Lit("\t%q -> %q;\n"), jen.Id("dependency").Dot("Service"), jen.Id("dependency").Dot("Dependency")),
+		),
+		jen.Line(),
+		jen.Id("b").Dot("WriteString").Call(jen.Lit("}\n")),
+		jen.Line(),
+		jen.Return(jen.Id("b").Dot("String").Call()),
+	)
+
+	f.Comment("Mermaid renders the graph as a Mermaid flowchart.")
+	f.Func().Params(jen.Id("g").Id("DependencyGraph")).Id("Mermaid").Params().String().Block(
+		jen.Var().Id("b").Qual("strings", "Builder"),
+		jen.Line(),
+		jen.Id("b").Dot("WriteString").Call(jen.Lit("flowchart TD\n")),
+		jen.Line(),
+		jen.Comment("Service identifiers may contain characters Mermaid does not accept in node IDs"),
+		jen.Id("ids").Op(":=").Make(jen.Map(jen.String()).String(), jen.Len(jen.Id("g").Dot("Services"))),
+		jen.Line(),
+		jen.For(jen.Id("i, service").Op(":=").Range().Id("g").Dot("Services")).Block(
+			jen.Id("ids").Index(jen.Id("service")).Op("=").Qual("fmt", "Sprintf").Call(jen.Lit("s%d"), jen.Id("i")),
+			jen.Line(),
+			jen.Qual("fmt", "Fprintf").Call(
+				jen.Op("&").Id("b"),
+				jen.Lit("\t%s[\"%s\"]\n"),
+				jen.Id("ids").Index(jen.Id("service")),
+				jen.Qual("strings", "ReplaceAll").Call(jen.Id("service"), jen.Lit("\""), jen.Lit("#quot;")),
+			),
+		),
+		jen.Line(),
+		jen.For(jen.Id("_, dependency").Op(":=").Range().Id("g").Dot("Dependencies")).Block(
+			jen.Qual("fmt", "Fprintf").Call(jen.Op("&").Id("b"), jen.Lit("\t%s --> %s\n"), jen.Id("ids").Index(jen.Id("dependency").Dot("Service")), jen.Id("ids").Index(jen.Id("dependency").Dot("Dependency"))),
+		),
+		jen.Line(),
+		jen.Return(jen.Id("b").Dot("String").Call()),
+	)
+
+	f.Comment("JSON renders the graph as JSON.")
+	f.Func().Params(jen.Id("g").Id("DependencyGraph")).Id("JSON").Params().Params(jen.Index().Byte(), jen.Error()).Block(
+		jen.Return(jen.Qual("encoding/json", "MarshalIndent").Call(jen.Id("g"), jen.Lit(""), jen.Lit("  "))),
+	)
+}
+
+// debugEndpoints lists the files served by the debug handler along with their description.
+var debugEndpoints = [][2]string{
+	{"services.json", "services (JSON)"},
+	{"graph.dot", "dependency graph (Graphviz DOT)"},
+	{"graph.mermaid", "dependency graph (Mermaid)"},
+	{"graph.json", "dependency graph (JSON)"},
+}
+
+// generateDebugHandler generates an HTTP handler serving the state of the registry (similar to net/http/pprof).
+func generateDebugHandler(f *jen.File, cfg config) {
+	w := jen.Id("w")
+
+	contentType := func(value string) jen.Code {
+		return w.Clone().Dot("Header").Call().Dot("Set").Call(jen.Lit("Content-Type"), jen.Lit(value))
+	}
+
+	f.Comment("DebugHandler returns an HTTP handler serving the state of the registry.")
+	f.Comment("")
+	f.Comment("The handler serves the following files relative to the path it is mounted on:")
+	f.Comment("")
+	for _, endpoint := range debugEndpoints {
+		f.Commentf("  - %s: %s", endpoint[0], endpoint[1])
+	}
+	f.Comment("")
+	f.Comment("Any path ending with a slash serves a plain text overview of the services (listing the files above),")
+	f.Comment("so the handler is expected to be mounted on a path ending with a slash:")
+	f.Comment("")
+	f.Comment("	mux.Handle(\"/debug/services/\", registry.DebugHandler())")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("DebugHandler").Params().Qual("net/http", "Handler").Block(
+		jen.Return(jen.Qual("net/http", "HandlerFunc").Call(jen.Func().Params(
+			jen.Id("w").Qual("net/http", "ResponseWriter"),
+			jen.Id("req").Op("*").Qual("net/http", "Request"),
+		).Block(
+			jen.If(jen.Id("req").Dot("Method").Op("!=").Qual("net/http", "MethodGet").Op("&&").Id("req").Dot("Method").Op("!=").Qual("net/http", "MethodHead")).Block(
+				w.Clone().Dot("Header").Call().Dot("Set").Call(jen.Lit("Allow"), jen.Lit("GET, HEAD")),
+				jen.Qual("net/http", "Error").Call(jen.Id("w"), jen.Lit("method not allowed"), jen.Qual("net/http", "StatusMethodNotAllowed")),
+				jen.Line(),
+				jen.Return(),
+			),
+			jen.Line(),
+			jen.If(jen.Id("req").Dot("URL").Dot("Path").Op("==").Lit("").Op("||").Qual("strings", "HasSuffix").Call(jen.Id("req").Dot("URL").Dot("Path"), jen.Lit("/"))).Block(
+				contentType("text/plain; charset=utf-8"),
+				jen.Id("r").Dot("writeDebugIndex").Call(jen.Id("w")),
+				jen.Line(),
+				jen.Return(),
+			),
+			jen.Line(),
+			jen.Switch(jen.Qual("path", "Base").Call(jen.Id("req").Dot("URL").Dot("Path"))).Block(
+				jen.Case(jen.Lit("services.json")).Block(
+					jen.List(jen.Id("data"), jen.Err()).Op(":=").Qual("encoding/json", "MarshalIndent").Call(jen.Id("r").Dot("Services").Call(), jen.Lit(""), jen.Lit("  ")),
+					jen.Id("writeDebugJSON").Call(jen.Id("w"), jen.Id("data"), jen.Err()),
+				),
+				jen.Case(jen.Lit("graph.dot")).Block(
+					contentType("text/vnd.graphviz; charset=utf-8"),
+					jen.Qual("io", "WriteString").Call(jen.Id("w"), jen.Id("r").Dot("DependencyGraph").Call().Dot("DOT").Call()),
+				),
+				jen.Case(jen.Lit("graph.mermaid")).Block(
+					contentType("text/plain; charset=utf-8"),
+					jen.Qual("io", "WriteString").Call(jen.Id("w"), jen.Id("r").Dot("DependencyGraph").Call().Dot("Mermaid").Call()),
+				),
+				jen.Case(jen.Lit("graph.json")).Block(
+					jen.List(jen.Id("data"), jen.Err()).Op(":=").Id("r").Dot("DependencyGraph").Call().Dot("JSON").Call(),
+					jen.Id("writeDebugJSON").Call(jen.Id("w"), jen.Id("data"), jen.Err()),
+				),
+				jen.Default().Block(
+					jen.Qual("net/http", "NotFound").Call(jen.Id("w"), jen.Id("req")),
+				),
+			),
+		))),
+	)
+
+	f.Line()
+
+	f.Comment("writeDebugIndex writes a plain text overview of the services of the registry to w.")
+	f.Func().Params(jen.Id("r").Op("*").Id(cfg.registryName)).Id("writeDebugIndex").Params(jen.Id("w").Qual("io", "Writer")).BlockFunc(func(g *jen.Group) {
+		newTabWriter := jen.Qual("text/tabwriter", "NewWriter").Call(jen.Id("w"), jen.Lit(0), jen.Lit(0), jen.Lit(2), jen.LitRune(' '), jen.Lit(0))
+
+		g.Qual("fmt", "Fprint").Call(jen.Id("w"), jen.Lit("Endpoints:\n\n"))
+		g.Line()
+		g.Id("tw").Op(":=").Add(newTabWriter)
+		for _, endpoint := range debugEndpoints {
+			g.Qual("fmt", "Fprintln").Call(jen.Id("tw"), jen.Lit(endpoint[0]+"\t"+endpoint[1]))
+		}
+		g.Id("tw").Dot("Flush").Call()
+
+		g.Line()
+
+		g.Qual("fmt", "Fprint").Call(jen.Id("w"), jen.Lit("\nServices:\n\n"))
+		g.Line()
+		g.Id("tw").Op("=").Add(newTabWriter)
+		g.Qual("fmt", "Fprintln").Call(jen.Id("tw"), jen.Lit("NAME\tTYPE\tSCOPE\tREGISTERED\tNAMES\tINSTANCES\tDEPENDENCIES"))
+		g.Line()
+		g.For(jen.Id("_, service").Op(":=").Range().Id("r").Dot("Services").Call()).Block(
+			jen.Id("instances").Op(":=").Make(jen.Index().String(), jen.Lit(0), jen.Len(jen.Id("service").Dot("Instances"))),
+			jen.Line(),
+			jen.For(jen.Id("_, instance").Op(":=").Range().Id("service").Dot("Instances")).Block(
+				jen.Id("description").Op(":=").Qual("fmt", "Sprintf").Call(
+					jen.Lit("%s (%s)"),
+					jen.Id("instance").Dot("ConstructedAt").Dot("Format").Call(jen.Qual("time", "RFC3339")),
+					jen.Id("instance").Dot("Duration"),
+				),
+				jen.If(jen.Id("instance").Dot("Name").Op("!=").Lit("")).Block(
+					jen.Id("description").Op("=").Id("instance").Dot("Name").Op("+").Lit(": ").Op("+").Id("description"),
+				),
+				jen.Line(),
+				jen.Id("instances").Op("=").Append(jen.Id("instances"), jen.Id("description")),
+			),
+			jen.Line(),
+			jen.Qual("fmt", "Fprintf").Call(
+				jen.Id("tw"),
+				jen.Lit("%s\t%s\t%s\t%t\t%s\t%s\t%s\n"),
+				jen.Id("service").Dot("Name"),
+				jen.Id("service").Dot("Type"),
+				jen.Id("service").Dot("Scope"),
+				jen.Id("service").Dot("Registered"),
+				jen.Id("debugList").Call(jen.Id("service").Dot("Names")),
+				jen.Id("debugList").Call(jen.Id("instances")),
+				jen.Id("debugList").Call(jen.Id("service").Dot("Dependencies")),
+			),
+		)
+		g.Line()
+		g.Id("tw").Dot("Flush").Call()
+	})
+
+	f.Line()
+
+	f.Comment("writeDebugJSON writes data (or err) to w.")
+	f.Func().Id("writeDebugJSON").Params(jen.Id("w").Qual("net/http", "ResponseWriter"), jen.Id("data").Index().Byte(), jen.Err().Error()).Block(
+		jen.If(jen.Err().Op("!=").Nil()).Block(
+			jen.Qual("net/http", "Error").Call(jen.Id("w"), jen.Err().Dot("Error").Call(), jen.Qual("net/http", "StatusInternalServerError")),
+			jen.Line(),
+			jen.Return(),
+		),
+		jen.Line(),
+		contentType("application/json"),
+		jen.Id("w").Dot("Write").Call(jen.Id("data")),
+	)
+
+	f.Line()
+
+	f.Comment("debugList joins values (or returns a dash if there are none).")
+	f.Func().Id("debugList").Params(jen.Id("values").Index().String()).String().Block(
+		jen.If(jen.Len(jen.Id("values")).Op("==").Lit(0)).Block(
+			jen.Return(jen.Lit("-")),
+		),
+		jen.Line(),
+		jen.Return(jen.Qual("strings", "Join").Call(jen.Id("values"), jen.Lit(", "))),
+	)
+}
+
+func generateServiceAlreadyRegisteredError(f *jen.File) {
+	f.Comment("ServiceAlreadyRegisteredError is returned when registering a factory for a service that already has one")
+	f.Comment("(depending on the {RegistrationPolicy}).")
+	f.Type().Id("ServiceAlreadyRegisteredError").Struct(
+		jen.Id("ServiceType").String(),
+		jen.Id("ServiceName").String(),
+	)
+
+	f.Func().Params(jen.Id("e").Id("ServiceAlreadyRegisteredError")).Id("Error").Params().String().Block(
+		jen.If(jen.Id("e").Dot("ServiceName").Op("!=").Lit("")).Block(
+			jen.Return(jen.Qual("fmt", "Sprintf").Call(
+				jen.Lit("factory already registered for %s with name '%s'"),
+				jen.Id("e").Dot("ServiceType"),
+				jen.Id("e").Dot("ServiceName"),
+			)),
+		),
+		jen.Line(),
+		jen.Return(jen.Lit("factory already registered for ").Op("+").Id("e").Dot("ServiceType")),
+	)
+
+	f.Func().Params(jen.Id("ServiceAlreadyRegisteredError")).Id("serviceLocatorError").Params().Block()
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadTestPackage loads a package from the testdata directory.
+func loadTestPackage(t *testing.T, name string) *packages.Package {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	pkgs, errs := load(context.Background(), wd, os.Environ(), "", []string{"./testdata/" + name}, defaultOutput)
+	require.Empty(t, errs)
+	require.Len(t, pkgs, 1)
+
+	return pkgs[0]
+}
+
+func testConfig() config {
+	return config{
+		interfaceName: defaultInterfaceName,
+		registryName:  defaultRegistryName,
+		output:        defaultOutput,
+		patterns:      []string{"."},
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]string{"-interface", "Container", "-registry-name", "ContainerRegistry", "-output", "container_gen.go", "./a", "./b"})
+	require.NoError(t, err)
+
+	expected := testConfig()
+	expected.interfaceName = "Container"
+	expected.registryName = "ContainerRegistry"
+	expected.output = "container_gen.go"
+	expected.patterns = []string{"./a", "./b"}
+
+	assert.Equal(t, expected, cfg)
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	cfg, err := parseConfig(nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, testConfig(), cfg)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	_, err := parseConfig([]string{"-unknown"})
+	assert.Error(t, err)
+
+	_, err = parseConfig([]string{"-interface", "service-locator"})
+	assert.EqualError(t, err, `invalid interface name: "service-locator"`)
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config)
+		err    string
+	}{
+		{
+			name:   "Default",
+			modify: func(cfg *config) {},
+		},
+		{
+			name:   "InvalidInterfaceName",
+			modify: func(cfg *config) { cfg.interfaceName = "service-locator" },
+			err:    `invalid interface name: "service-locator"`,
+		},
+		{
+			name:   "InvalidRegistryName",
+			modify: func(cfg *config) { cfg.registryName = "" },
+			err:    `invalid registry name: ""`,
+		},
+		{
+			name:   "RegistryNameClash",
+			modify: func(cfg *config) { cfg.registryName = cfg.interfaceName },
+			err:    `registry name must differ from the interface name: "ServiceLocator"`,
+		},
+		{
+			name: "ScopeNameClash",
+			modify: func(cfg *config) {
+				cfg.interfaceName = "ContainerScope"
+				cfg.registryName = "ContainerRegistry"
+			},
+			err: `scope name (derived from the registry name) must differ from the interface name: "ContainerScope"`,
+		},
+		{
+			name:   "EmptyOutput",
+			modify: func(cfg *config) { cfg.output = "" },
+			err:    "output file name must not be empty",
+		},
+		{
+			name: "AbsoluteOutputWithMultiplePackages",
+			modify: func(cfg *config) {
+				cfg.output = "/tmp/service_locator_gen.go"
+				cfg.patterns = []string{"./a", "./b"}
+			},
+			err: "absolute output path cannot be used with multiple package patterns",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testConfig()
+			test.modify(&cfg)
+
+			err := validateConfig(cfg)
+
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseServiceDefinitions(t *testing.T) {
+	pkg := loadTestPackage(t, "diagnostics")
+
+	services, diagnostics, err := parseServiceDefinitions(pkg, "ServiceLocator")
+	require.NoError(t, err)
+
+	var names []string
+	for _, svc := range services {
+		names = append(names, svc.name)
+	}
+
+	assert.Equal(t, []string{"Config", "ServiceB"}, names)
+
+	tests := []struct {
+		method string
+		reason string
+	}{
+		{"GetAlias", "must return exactly two values (service and error), got 1"},
+		{"GetTimeout", "second result must be error, got string"},
+		{"Config", "method name must be Get followed by an exported service name"},
+		{"GetSettings", "method name must be GetConfig for service type *Config"},
+		{"GetEmpty", "service type struct{} is not supported"},
+		{"GetJob", "parameter must be (name string), got (id int)"},
+		{"GetHandler", "must accept at most one parameter, got 2"},
+		{"GetWorker", "directive //servicelocator:scoped conflicts with a previous scope directive"},
+		{"GetCache", "directive //servicelocator:required primary only applies to named services"},
+		{"GetClock", "unknown directive //servicelocator:lazy"},
+	}
+
+	require.Len(t, diagnostics, len(tests))
+
+	for i, test := range tests {
+		t.Run(test.method, func(t *testing.T) {
+			assert.Equal(t, test.method, diagnostics[i].method)
+			assert.Equal(t, test.reason, diagnostics[i].reason)
+			assert.Equal(t, "svc.go", filepath.Base(diagnostics[i].pos.Filename))
+		})
+	}
+}
+
+func TestParseServiceDefinitionsErrors(t *testing.T) {
+	pkg := loadTestPackage(t, "diagnostics")
+
+	_, _, err := parseServiceDefinitions(pkg, "Locator")
+	assert.EqualError(t, err, "interface Locator not found")
+
+	_, _, err = parseServiceDefinitions(pkg, "Config")
+	assert.EqualError(t, err, "Config is not an interface")
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name   string
+		pkg    string
+		strict bool
+		err    string
+	}{
+		{
+			name:   "Strict",
+			pkg:    "diagnostics",
+			strict: true,
+			err:    "10 method(s) of ServiceLocator rejected in strict mode",
+		},
+		{
+			name: "SkippedMethods",
+			pkg:  "skipped",
+		},
+		{
+			name: "SkippedMethodWithoutError",
+			pkg:  "diagnostics",
+			err:  "method GetAlias cannot be implemented by the generated code: method does not return an error",
+		},
+		{
+			name: "UnsupportedSkippedMethod",
+			pkg:  "unsupported",
+			err:  "method GetEmpty cannot be implemented by the generated code: unsupported type struct{}",
+		},
+		{
+			name: "SkippedMethodClash",
+			pkg:  "clash",
+			err:  "method Validate cannot be implemented by the generated code: name is used by the registry",
+		},
+		{
+			name: "InvalidConstructor",
+			pkg:  "constructors",
+			err:  "constructor NewMissing of Missing: function not found",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			pkg := loadTestPackage(t, test.pkg)
+
+			cfg := testConfig()
+			cfg.output = filepath.Join(t.TempDir(), defaultOutput)
+			cfg.strict = test.strict
+
+			err := generate(cfg, pkg)
+
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				assert.NoFileExists(t, cfg.output)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			// Skipped methods are implemented by stubs: the generated code compiles
+			file, err := parser.ParseFile(pkg.Fset, cfg.output, nil, 0)
+			require.NoError(t, err)
+
+			conf := types.Config{Importer: importer.ForCompiler(pkg.Fset, "source", nil)}
+
+			_, err = conf.Check(pkg.PkgPath, pkg.Fset, append([]*ast.File{file}, pkg.Syntax...), nil)
+			assert.NoError(t, err)
+		})
+	}
+}
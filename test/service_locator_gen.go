@@ -1,11 +1,22 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	subtest "github.com/sagikazarmark/go-service-locator/test/subtest"
+	"io"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"time"
 )
 
 // ServiceFactory creates a new instance of T.
@@ -14,247 +25,5900 @@ type ServiceFactory[T any] func(ServiceLocator) (T, error)
 // NamedServiceFactory creates a new named instance of T.
 type NamedServiceFactory[T any] func(string, ServiceLocator) (T, error)
 
+// ContextServiceFactory creates a new instance of T.
+//
+// The context is the one passed to the Get*Context method the resolution started with.
+type ContextServiceFactory[T any] func(context.Context, ServiceLocator) (T, error)
+
+// NamedContextServiceFactory creates a new named instance of T.
+//
+// The context is the one passed to the Get*Context method the resolution started with.
+type NamedContextServiceFactory[T any] func(context.Context, string, ServiceLocator) (T, error)
+
+// ServiceDecorator wraps an instance of T (eg. with logging or metrics).
+type ServiceDecorator[T any] func(T, ServiceLocator) (T, error)
+
+// decorate applies decorators to an instance returned by a factory.
+func decorate[T any](c *serviceLocationContext, instance T, decorators []ServiceDecorator[T]) (T, error) {
+	for _, decorator := range decorators {
+		var err error
+
+		instance, err = decorator(instance, c)
+		if err != nil {
+			return instance, err
+		}
+	}
+
+	return instance, nil
+}
+
+// namedServiceFactoryPattern is a factory of named services registered for names matching a pattern.
+type namedServiceFactoryPattern[T any] struct {
+	pattern string
+	factory NamedContextServiceFactory[T]
+}
+
 // ServiceRegistry allows registering service factories to construct new instances of a service.
 // ServiceRegistry is also the primary {ServiceLocator} entrypoint.
 type ServiceRegistry struct {
 	mu sync.Mutex
 
-	instanceServiceA  ServiceA
-	factoryServiceA   ServiceFactory[ServiceA]
-	instancesServiceB map[string]ServiceB
-	factoriesServiceB map[string]NamedServiceFactory[ServiceB]
-	instanceServiceC  subtest.ServiceC
-	factoryServiceC   ServiceFactory[subtest.ServiceC]
+	instanceAny                any
+	constructedAny             bool
+	factoryAny                 ContextServiceFactory[any]
+	callAny                    *serviceCall[any]
+	generationAny              uint64
+	decoratorsAny              []ServiceDecorator[any]
+	factoryBuffer              ContextServiceFactory[*bytes.Buffer]
+	decoratorsBuffer           []ServiceDecorator[*bytes.Buffer]
+	instanceClock              func() time.Time
+	constructedClock           bool
+	factoryClock               ContextServiceFactory[func() time.Time]
+	callClock                  *serviceCall[func() time.Time]
+	generationClock            uint64
+	decoratorsClock            []ServiceDecorator[func() time.Time]
+	instanceConfig             *Config
+	constructedConfig          bool
+	factoryConfig              ContextServiceFactory[*Config]
+	callConfig                 *serviceCall[*Config]
+	generationConfig           uint64
+	decoratorsConfig           []ServiceDecorator[*Config]
+	instanceDB                 *sql.DB
+	constructedDB              bool
+	factoryDB                  ContextServiceFactory[*sql.DB]
+	callDB                     *serviceCall[*sql.DB]
+	generationDB               uint64
+	decoratorsDB               []ServiceDecorator[*sql.DB]
+	instanceHandlers           []Handler
+	constructedHandlers        bool
+	factoryHandlers            ContextServiceFactory[[]Handler]
+	callHandlers               *serviceCall[[]Handler]
+	generationHandlers         uint64
+	decoratorsHandlers         []ServiceDecorator[[]Handler]
+	factoriesJob               map[string]NamedContextServiceFactory[*Job]
+	patternFactoriesJob        []namedServiceFactoryPattern[*Job]
+	defaultFactoryJob          NamedContextServiceFactory[*Job]
+	decoratorsJob              map[string][]ServiceDecorator[*Job]
+	factoryMailer              ContextServiceFactory[Mailer]
+	decoratorsMailer           []ServiceDecorator[Mailer]
+	constructorFactoryMailer   bool
+	factoryRequest             ContextServiceFactory[*Request]
+	decoratorsRequest          []ServiceDecorator[*Request]
+	instanceServiceA           ServiceA
+	constructedServiceA        bool
+	factoryServiceA            ContextServiceFactory[ServiceA]
+	callServiceA               *serviceCall[ServiceA]
+	generationServiceA         uint64
+	decoratorsServiceA         []ServiceDecorator[ServiceA]
+	instancesServiceB          map[string]ServiceB
+	factoriesServiceB          map[string]NamedContextServiceFactory[ServiceB]
+	callsServiceB              map[string]*serviceCall[ServiceB]
+	generationsServiceB        map[string]uint64
+	fallbackGenerationServiceB uint64
+	patternFactoriesServiceB   []namedServiceFactoryPattern[ServiceB]
+	defaultFactoryServiceB     NamedContextServiceFactory[ServiceB]
+	decoratorsServiceB         map[string][]ServiceDecorator[ServiceB]
+	instanceServiceC           subtest.ServiceC
+	constructedServiceC        bool
+	factoryServiceC            ContextServiceFactory[subtest.ServiceC]
+	callServiceC               *serviceCall[subtest.ServiceC]
+	generationServiceC         uint64
+	decoratorsServiceC         []ServiceDecorator[subtest.ServiceC]
+	factoriesSession           map[string]NamedContextServiceFactory[*Session]
+	patternFactoriesSession    []namedServiceFactoryPattern[*Session]
+	defaultFactorySession      NamedContextServiceFactory[*Session]
+	decoratorsSession          map[string][]ServiceDecorator[*Session]
+	instanceUserRepo           Repo[User]
+	constructedUserRepo        bool
+	factoryUserRepo            ContextServiceFactory[Repo[User]]
+	callUserRepo               *serviceCall[Repo[User]]
+	generationUserRepo         uint64
+	decoratorsUserRepo         []ServiceDecorator[Repo[User]]
+	factoriesWorker            map[string]NamedContextServiceFactory[*Worker]
+	patternFactoriesWorker     []namedServiceFactoryPattern[*Worker]
+	defaultFactoryWorker       NamedContextServiceFactory[*Worker]
+	decoratorsWorker           map[string][]ServiceDecorator[*Worker]
+	constructorFactoryWorker   bool
+
+	// waiting records constructions waiting for instances constructed by other ones
+	waiting map[*serviceWait]struct{}
+
+	// instanceOrder records instances in construction order, so they can be closed in reverse order
+	instanceOrder []any
+	closed        bool
+
+	maxDepth           int
+	initWorkers        int
+	registrationPolicy RegistrationPolicy
+	hooks              ResolutionHooks
+
+	// services and dependencies record the dependency graph of resolved services
+	services     map[string]struct{}
+	instanceInfo map[string]ServiceInstanceInfo
+	dependencies map[ServiceDependency]struct{}
+}
+
+// ServiceRegistryOption configures a {ServiceRegistry}.
+type ServiceRegistryOption func(*ServiceRegistry)
+
+// WithMaxDepth limits how deep services can depend on other services.
+// Resolving a service beyond that depth returns a {MaxDepthExceededError}.
+//
+// The depth is not limited by default.
+func WithMaxDepth(maxDepth int) ServiceRegistryOption {
+	return func(r *ServiceRegistry) {
+		r.maxDepth = maxDepth
+	}
+}
+
+// WithInitWorkers limits how many services {ServiceRegistry.InitAll} constructs concurrently.
+//
+// The default is {runtime.GOMAXPROCS}.
+func WithInitWorkers(workers int) ServiceRegistryOption {
+	return func(r *ServiceRegistry) {
+		r.initWorkers = workers
+	}
+}
+
+// RegistrationPolicy determines what happens when registering a factory for a service that already has one.
+type RegistrationPolicy int
+
+const (
+	// AllowOverride replaces the previous factory (default).
+	AllowOverride RegistrationPolicy = iota
+
+	// ErrorOnDuplicate keeps the previous factory and returns a {ServiceAlreadyRegisteredError}.
+	ErrorOnDuplicate
+
+	// PanicOnDuplicate panics with a {ServiceAlreadyRegisteredError}.
+	PanicOnDuplicate
+)
+
+// WithRegistrationPolicy sets what happens when registering a factory for a service that already has one.
+func WithRegistrationPolicy(policy RegistrationPolicy) ServiceRegistryOption {
+	return func(r *ServiceRegistry) {
+		r.registrationPolicy = policy
+	}
+}
+
+// WithResolutionHooks sets the hooks observing how services are resolved.
+func WithResolutionHooks(hooks ResolutionHooks) ServiceRegistryOption {
+	return func(r *ServiceRegistry) {
+		r.hooks = hooks
+	}
 }
 
+// ErrServiceRegistryClosed is returned when retrieving a service from a closed {ServiceRegistry}.
+var ErrServiceRegistryClosed = errors.New("service registry is closed")
+
 // NewServiceRegistry instantiates a new {ServiceRegistry}.
-func NewServiceRegistry() *ServiceRegistry {
-	return &ServiceRegistry{instancesServiceB: make(map[string]ServiceB), factoriesServiceB: make(map[string]NamedServiceFactory[ServiceB])}
+func NewServiceRegistry(opts ...ServiceRegistryOption) *ServiceRegistry {
+	r := &ServiceRegistry{factoriesJob: make(map[string]NamedContextServiceFactory[*Job]), decoratorsJob: make(map[string][]ServiceDecorator[*Job]), instancesServiceB: make(map[string]ServiceB), factoriesServiceB: make(map[string]NamedContextServiceFactory[ServiceB]), decoratorsServiceB: make(map[string][]ServiceDecorator[ServiceB]), callsServiceB: make(map[string]*serviceCall[ServiceB]), generationsServiceB: make(map[string]uint64), factoriesSession: make(map[string]NamedContextServiceFactory[*Session]), decoratorsSession: make(map[string][]ServiceDecorator[*Session]), factoriesWorker: make(map[string]NamedContextServiceFactory[*Worker]), decoratorsWorker: make(map[string][]ServiceDecorator[*Worker]), factoryMailer: constructMailer, constructorFactoryMailer: true, defaultFactoryWorker: constructWorker, constructorFactoryWorker: true, waiting: make(map[*serviceWait]struct{}), services: make(map[string]struct{}), instanceInfo: make(map[string]ServiceInstanceInfo), dependencies: make(map[ServiceDependency]struct{}), initWorkers: runtime.GOMAXPROCS(0)}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
-// RegisterServiceA registers a factory for {ServiceA}.
-func (r *ServiceRegistry) RegisterServiceA(factory ServiceFactory[ServiceA]) {
+// RegisterAny registers a factory for {Any}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterAny(factory ServiceFactory[any]) error {
+	return r.RegisterAnyContext(func(_ context.Context, serviceLocator ServiceLocator) (any, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterAnyContext registers a factory for {Any} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterAnyContext(factory ContextServiceFactory[any]) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.factoryServiceA = factory
-}
+	if r.factoryAny != nil {
+		if err := r.duplicateRegistration("Any", ""); err != nil {
+			return err
+		}
+	}
 
-// GetServiceA retrieves an instance of {ServiceA}.
-func (r *ServiceRegistry) GetServiceA() (ServiceA, error) {
-	return r.getServiceA(newServiceLocationContext(r, 0))
+	r.factoryAny = factory
+	r.generationAny++
+	var zero any
+	r.instanceAny = zero
+	r.constructedAny = false
+	delete(r.instanceInfo, "Any")
+
+	return nil
 }
 
-func (r *ServiceRegistry) getServiceA(ctx *serviceLocationContext) (ServiceA, error) {
+// RegisterAnyInstance registers an instance of {Any}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterAnyInstance(instance any) error {
 	r.mu.Lock()
-	instance := r.instanceServiceA
-	instanceOk := instance != nil
-	factory := r.factoryServiceA
-	factoryOk := factory != nil
-	r.mu.Unlock()
+	defer r.mu.Unlock()
 
-	if instanceOk {
-		return instance, nil
+	if r.factoryAny != nil {
+		if err := r.duplicateRegistration("Any", ""); err != nil {
+			return err
+		}
 	}
 
-	if ctx.isVisitedServiceA() {
-		return nil, newCircularDependencyError("ServiceA", "", ctx.dependencyGraph)
+	r.factoryAny = func(context.Context, ServiceLocator) (any, error) {
+		return instance, nil
 	}
-	ctx.markVisitedServiceA()
+	r.instanceAny = instance
+	r.constructedAny = true
+	r.generationAny++
 
-	if !factoryOk {
-		return nil, errors.New("no factory registered for ServiceA")
-	}
+	service := serviceID("Any", "")
 
-	instance, err := factory(ctx)
-	if err != nil {
-		return nil, err
-	}
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
 
+	return nil
+}
+
+// DecorateAny registers a decorator wrapping instances of {Any}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateAny(decorator ServiceDecorator[any]) {
 	r.mu.Lock()
-	r.instanceServiceA = instance
-	r.mu.Unlock()
+	defer r.mu.Unlock()
 
-	return instance, nil
+	r.decoratorsAny = append(r.decoratorsAny, decorator)
 }
 
-// RegisterServiceB registers a factory for {ServiceB}.
-func (r *ServiceRegistry) RegisterServiceB(serviceName string, factory NamedServiceFactory[ServiceB]) {
+// UnregisterAny removes the factory of {Any}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterAny() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.factoriesServiceB[serviceName] = factory
+	r.factoryAny = nil
+	r.generationAny++
+	var zero any
+	r.instanceAny = zero
+	r.constructedAny = false
+	delete(r.instanceInfo, "Any")
 }
 
-// GetServiceB retrieves an instance of {ServiceB}.
-func (r *ServiceRegistry) GetServiceB(serviceName string) (ServiceB, error) {
-	return r.getServiceB(serviceName, newServiceLocationContext(r, 0))
+// GetAny retrieves an instance of {Any}.
+func (r *ServiceRegistry) GetAny() (any, error) {
+	return r.GetAnyContext(context.Background())
+}
+
+// GetAnyContext is like {ServiceRegistry.GetAny}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetAnyContext(ctx context.Context) (any, error) {
+	return r.getAny(newServiceLocationContext(ctx, r, nil, r.maxDepth))
 }
 
-func (r *ServiceRegistry) getServiceB(serviceName string, ctx *serviceLocationContext) (ServiceB, error) {
+func (r *ServiceRegistry) getAny(c *serviceLocationContext) (any, error) {
+	var zero any
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Any", "", c.path())
+	}
+
 	r.mu.Lock()
-	instance, instanceOk := r.instancesServiceB[serviceName]
-	factory, factoryOk := r.factoriesServiceB[serviceName]
-	r.mu.Unlock()
 
-	if instanceOk {
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "Any", "")
+
+	if r.constructedAny {
+		instance := r.instanceAny
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Any", "", c.path())
+		}
+
 		return instance, nil
 	}
 
-	if ctx.isVisitedServiceB(serviceName) {
-		return nil, newCircularDependencyError("ServiceB", serviceName, ctx.dependencyGraph)
+	if c.isConstructing("Any", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Any", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Any", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callAny; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Any", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("Any", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
 	}
-	ctx.markVisitedServiceB(serviceName)
+
+	factory := r.factoryAny
+	factoryOk := factory != nil
+	decorators := r.decoratorsAny
+	generation := r.generationAny
 
 	if !factoryOk {
-		return nil, fmt.Errorf("no factory registered for ServiceB with name '%s'", serviceName)
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Any",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
 	}
 
-	instance, err := factory(serviceName, ctx)
+	child, err := c.enter("Any", "")
 	if err != nil {
-		return nil, err
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[any]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callAny = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Any", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Any", "", err)
+		}
+
+		r.mu.Lock()
+		r.callAny = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Any", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Any", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Any", "", call.err)
 	}
 
 	r.mu.Lock()
-	r.instancesServiceB[serviceName] = instance
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationAny == generation {
+			r.instanceAny = call.instance
+			r.constructedAny = true
+			r.instanceInfo[serviceID("Any", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
 	r.mu.Unlock()
 
-	return instance, nil
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
 }
 
-// RegisterServiceC registers a factory for {ServiceC}.
-func (r *ServiceRegistry) RegisterServiceC(factory ServiceFactory[subtest.ServiceC]) {
+// RegisterBuffer registers a factory for {Buffer}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterBuffer(factory ServiceFactory[*bytes.Buffer]) error {
+	return r.RegisterBufferContext(func(_ context.Context, serviceLocator ServiceLocator) (*bytes.Buffer, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterBufferContext registers a factory for {Buffer} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterBufferContext(factory ContextServiceFactory[*bytes.Buffer]) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.factoryServiceC = factory
+	if r.factoryBuffer != nil {
+		if err := r.duplicateRegistration("Buffer", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryBuffer = factory
+
+	return nil
 }
 
-// GetServiceC retrieves an instance of {ServiceC}.
-func (r *ServiceRegistry) GetServiceC() (subtest.ServiceC, error) {
-	return r.getServiceC(newServiceLocationContext(r, 0))
+// DecorateBuffer registers a decorator wrapping instances of {Buffer}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+func (r *ServiceRegistry) DecorateBuffer(decorator ServiceDecorator[*bytes.Buffer]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsBuffer = append(r.decoratorsBuffer, decorator)
 }
 
-func (r *ServiceRegistry) getServiceC(ctx *serviceLocationContext) (subtest.ServiceC, error) {
+// UnregisterBuffer removes the factory of {Buffer}.
+func (r *ServiceRegistry) UnregisterBuffer() {
 	r.mu.Lock()
-	instance := r.instanceServiceC
-	instanceOk := instance != nil
-	factory := r.factoryServiceC
+	defer r.mu.Unlock()
+
+	r.factoryBuffer = nil
+}
+
+// GetBuffer creates a new instance of {Buffer}.
+func (r *ServiceRegistry) GetBuffer() (*bytes.Buffer, error) {
+	return r.GetBufferContext(context.Background())
+}
+
+// GetBufferContext is like {ServiceRegistry.GetBuffer}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetBufferContext(ctx context.Context) (*bytes.Buffer, error) {
+	return r.getBuffer(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getBuffer(c *serviceLocationContext) (*bytes.Buffer, error) {
+	var zero *bytes.Buffer
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Buffer", "", c.path())
+	}
+
+	// Transient services may be requested multiple times during the same resolution,
+	// but not while being constructed.
+	if c.isConstructing("Buffer", "") {
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Buffer", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Buffer", "")
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.recordDependency(c, "Buffer", "")
+	factory := r.factoryBuffer
 	factoryOk := factory != nil
+	decorators := r.decoratorsBuffer
 	r.mu.Unlock()
 
-	if instanceOk {
-		return instance, nil
+	if closed {
+		return zero, ErrServiceRegistryClosed
 	}
 
-	if ctx.isVisitedServiceC() {
-		return nil, newCircularDependencyError("ServiceC", "", ctx.dependencyGraph)
+	if !factoryOk {
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Buffer",
+		}
 	}
-	ctx.markVisitedServiceC()
 
-	if !factoryOk {
-		return nil, errors.New("no factory registered for ServiceC")
+	if err := c.ctx.Err(); err != nil {
+		return zero, err
 	}
 
-	instance, err := factory(ctx)
+	child, err := c.enter("Buffer", "")
 	if err != nil {
-		return nil, err
+		return zero, err
 	}
 
-	r.mu.Lock()
-	r.instanceServiceC = instance
-	r.mu.Unlock()
+	var start time.Time
 
-	return instance, nil
-}
+	// Spans started by FactoryStart must end if the factory (or a decorator) panics
+	defer func() {
+		if p := recover(); p != nil {
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Buffer", "", child.path(), time.Since(start), fmt.Errorf("panic: %v", p))
+			}
 
-type serviceLocationContext struct {
-	registry        *ServiceRegistry
-	dependencyGraph []string
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Buffer", "", child.path())
+	}
+	start = time.Now()
 
-	visitLock sync.Mutex
+	instance, err := factory(child.ctx, child)
+	if err == nil {
+		instance, err = decorate(child, instance, decorators)
+	}
 
-	visitedServiceA bool
-	visitedServiceB map[string]bool
-	visitedServiceC bool
-}
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Buffer", "", child.path(), time.Since(start), err)
+	}
 
-func newServiceLocationContext(registry *ServiceRegistry, maxDepth int) *serviceLocationContext {
-	return &serviceLocationContext{registry: registry, visitedServiceB: make(map[string]bool)}
+	if err != nil {
+		return zero, child.constructionError("Buffer", "", err)
+	}
+
+	return instance, nil
 }
 
-func (c *serviceLocationContext) GetServiceA() (ServiceA, error) {
-	return c.registry.getServiceA(c)
+// RegisterClock registers a factory for {Clock}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterClock(factory ServiceFactory[func() time.Time]) error {
+	return r.RegisterClockContext(func(_ context.Context, serviceLocator ServiceLocator) (func() time.Time, error) {
+		return factory(serviceLocator)
+	})
 }
 
-func (c *serviceLocationContext) isVisitedServiceA() bool {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+// RegisterClockContext registers a factory for {Clock} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterClockContext(factory ContextServiceFactory[func() time.Time]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	return c.visitedServiceA
-}
+	if r.factoryClock != nil {
+		if err := r.duplicateRegistration("Clock", ""); err != nil {
+			return err
+		}
+	}
 
-func (c *serviceLocationContext) markVisitedServiceA() {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+	r.factoryClock = factory
+	r.generationClock++
+	var zero func() time.Time
+	r.instanceClock = zero
+	r.constructedClock = false
+	delete(r.instanceInfo, "Clock")
 
-	c.visitedServiceA = true
-	c.dependencyGraph = append(c.dependencyGraph, "ServiceA")
+	return nil
 }
 
-func (c *serviceLocationContext) GetServiceB(serviceName string) (ServiceB, error) {
-	return c.registry.getServiceB(serviceName, c)
-}
+// RegisterClockInstance registers an instance of {Clock}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterClockInstance(instance func() time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-func (c *serviceLocationContext) isVisitedServiceB(serviceName string) bool {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+	if r.factoryClock != nil {
+		if err := r.duplicateRegistration("Clock", ""); err != nil {
+			return err
+		}
+	}
 
-	return c.visitedServiceB[serviceName]
-}
+	r.factoryClock = func(context.Context, ServiceLocator) (func() time.Time, error) {
+		return instance, nil
+	}
+	r.instanceClock = instance
+	r.constructedClock = true
+	r.generationClock++
 
-func (c *serviceLocationContext) markVisitedServiceB(serviceName string) {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+	service := serviceID("Clock", "")
 
-	c.visitedServiceB[serviceName] = true
-	c.dependencyGraph = append(c.dependencyGraph, "ServiceB:"+serviceName)
-}
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
 
-func (c *serviceLocationContext) GetServiceC() (subtest.ServiceC, error) {
-	return c.registry.getServiceC(c)
+	return nil
 }
 
-func (c *serviceLocationContext) isVisitedServiceC() bool {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+// DecorateClock registers a decorator wrapping instances of {Clock}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateClock(decorator ServiceDecorator[func() time.Time]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	return c.visitedServiceC
+	r.decoratorsClock = append(r.decoratorsClock, decorator)
 }
 
-func (c *serviceLocationContext) markVisitedServiceC() {
-	c.visitLock.Lock()
-	defer c.visitLock.Unlock()
+// UnregisterClock removes the factory of {Clock}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterClock() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	c.visitedServiceC = true
-	c.dependencyGraph = append(c.dependencyGraph, "ServiceC")
+	r.factoryClock = nil
+	r.generationClock++
+	var zero func() time.Time
+	r.instanceClock = zero
+	r.constructedClock = false
+	delete(r.instanceInfo, "Clock")
 }
 
-// CircularDependencyError is returned when there is a circular dependency between two services.
-type CircularDependencyError struct {
-	ServiceType     string
-	ServiceName     string
-	DependencyGraph []string
+// GetClock retrieves an instance of {Clock}.
+func (r *ServiceRegistry) GetClock() (func() time.Time, error) {
+	return r.GetClockContext(context.Background())
 }
 
-func newCircularDependencyError(serviceType string, serviceName string, dependencyGraph []string) CircularDependencyError {
-	return CircularDependencyError{
-		DependencyGraph: dependencyGraph,
-		ServiceName:     serviceName,
-		ServiceType:     serviceType,
-	}
+// GetClockContext is like {ServiceRegistry.GetClock}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetClockContext(ctx context.Context) (func() time.Time, error) {
+	return r.getClock(newServiceLocationContext(ctx, r, nil, r.maxDepth))
 }
-func (e CircularDependencyError) Error() string {
-	dependencyPath := strings.Join(e.DependencyGraph, " -> ")
-	return fmt.Sprintf("circular dependency detected for %s '%s': %s", e.ServiceType, e.ServiceName, dependencyPath)
+
+func (r *ServiceRegistry) getClock(c *serviceLocationContext) (func() time.Time, error) {
+	var zero func() time.Time
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Clock", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "Clock", "")
+
+	if r.constructedClock {
+		instance := r.instanceClock
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Clock", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("Clock", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Clock", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Clock", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callClock; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Clock", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("Clock", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryClock
+	factoryOk := factory != nil
+	decorators := r.decoratorsClock
+	generation := r.generationClock
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Clock",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("Clock", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[func() time.Time]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callClock = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Clock", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Clock", "", err)
+		}
+
+		r.mu.Lock()
+		r.callClock = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Clock", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Clock", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Clock", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationClock == generation {
+			r.instanceClock = call.instance
+			r.constructedClock = true
+			r.instanceInfo[serviceID("Clock", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterConfig registers a factory for {Config}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterConfig(factory ServiceFactory[*Config]) error {
+	return r.RegisterConfigContext(func(_ context.Context, serviceLocator ServiceLocator) (*Config, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterConfigContext registers a factory for {Config} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterConfigContext(factory ContextServiceFactory[*Config]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryConfig != nil {
+		if err := r.duplicateRegistration("Config", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryConfig = factory
+	r.generationConfig++
+	var zero *Config
+	r.instanceConfig = zero
+	r.constructedConfig = false
+	delete(r.instanceInfo, "Config")
+
+	return nil
+}
+
+// RegisterConfigInstance registers an instance of {Config}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterConfigInstance(instance *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryConfig != nil {
+		if err := r.duplicateRegistration("Config", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryConfig = func(context.Context, ServiceLocator) (*Config, error) {
+		return instance, nil
+	}
+	r.instanceConfig = instance
+	r.constructedConfig = true
+	r.generationConfig++
+
+	service := serviceID("Config", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateConfig registers a decorator wrapping instances of {Config}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateConfig(decorator ServiceDecorator[*Config]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsConfig = append(r.decoratorsConfig, decorator)
+}
+
+// UnregisterConfig removes the factory of {Config}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterConfig() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryConfig = nil
+	r.generationConfig++
+	var zero *Config
+	r.instanceConfig = zero
+	r.constructedConfig = false
+	delete(r.instanceInfo, "Config")
+}
+
+// GetConfig retrieves an instance of {Config}.
+func (r *ServiceRegistry) GetConfig() (*Config, error) {
+	return r.GetConfigContext(context.Background())
+}
+
+// GetConfigContext is like {ServiceRegistry.GetConfig}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetConfigContext(ctx context.Context) (*Config, error) {
+	return r.getConfig(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getConfig(c *serviceLocationContext) (*Config, error) {
+	var zero *Config
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Config", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "Config", "")
+
+	if r.constructedConfig {
+		instance := r.instanceConfig
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Config", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("Config", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Config", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Config", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callConfig; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Config", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("Config", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryConfig
+	factoryOk := factory != nil
+	decorators := r.decoratorsConfig
+	generation := r.generationConfig
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Config",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("Config", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[*Config]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callConfig = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Config", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Config", "", err)
+		}
+
+		r.mu.Lock()
+		r.callConfig = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Config", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Config", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Config", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationConfig == generation {
+			r.instanceConfig = call.instance
+			r.constructedConfig = true
+			r.instanceInfo[serviceID("Config", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterDB registers a factory for {DB}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterDB(factory ServiceFactory[*sql.DB]) error {
+	return r.RegisterDBContext(func(_ context.Context, serviceLocator ServiceLocator) (*sql.DB, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterDBContext registers a factory for {DB} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterDBContext(factory ContextServiceFactory[*sql.DB]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryDB != nil {
+		if err := r.duplicateRegistration("DB", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryDB = factory
+	r.generationDB++
+	var zero *sql.DB
+	r.instanceDB = zero
+	r.constructedDB = false
+	delete(r.instanceInfo, "DB")
+
+	return nil
+}
+
+// RegisterDBInstance registers an instance of {DB}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterDBInstance(instance *sql.DB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryDB != nil {
+		if err := r.duplicateRegistration("DB", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryDB = func(context.Context, ServiceLocator) (*sql.DB, error) {
+		return instance, nil
+	}
+	r.instanceDB = instance
+	r.constructedDB = true
+	r.generationDB++
+
+	service := serviceID("DB", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateDB registers a decorator wrapping instances of {DB}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateDB(decorator ServiceDecorator[*sql.DB]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsDB = append(r.decoratorsDB, decorator)
+}
+
+// UnregisterDB removes the factory of {DB}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterDB() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryDB = nil
+	r.generationDB++
+	var zero *sql.DB
+	r.instanceDB = zero
+	r.constructedDB = false
+	delete(r.instanceInfo, "DB")
+}
+
+// GetDB retrieves an instance of {DB}.
+func (r *ServiceRegistry) GetDB() (*sql.DB, error) {
+	return r.GetDBContext(context.Background())
+}
+
+// GetDBContext is like {ServiceRegistry.GetDB}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetDBContext(ctx context.Context) (*sql.DB, error) {
+	return r.getDB(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getDB(c *serviceLocationContext) (*sql.DB, error) {
+	var zero *sql.DB
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "DB", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "DB", "")
+
+	if r.constructedDB {
+		instance := r.instanceDB
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "DB", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("DB", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "DB", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("DB", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callDB; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "DB", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("DB", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryDB
+	factoryOk := factory != nil
+	decorators := r.decoratorsDB
+	generation := r.generationDB
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "DB",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("DB", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[*sql.DB]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callDB = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "DB", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("DB", "", err)
+		}
+
+		r.mu.Lock()
+		r.callDB = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "DB", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "DB", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("DB", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationDB == generation {
+			r.instanceDB = call.instance
+			r.constructedDB = true
+			r.instanceInfo[serviceID("DB", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterHandlers registers a factory for {Handlers}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterHandlers(factory ServiceFactory[[]Handler]) error {
+	return r.RegisterHandlersContext(func(_ context.Context, serviceLocator ServiceLocator) ([]Handler, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterHandlersContext registers a factory for {Handlers} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterHandlersContext(factory ContextServiceFactory[[]Handler]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryHandlers != nil {
+		if err := r.duplicateRegistration("Handlers", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryHandlers = factory
+	r.generationHandlers++
+	var zero []Handler
+	r.instanceHandlers = zero
+	r.constructedHandlers = false
+	delete(r.instanceInfo, "Handlers")
+
+	return nil
+}
+
+// RegisterHandlersInstance registers an instance of {Handlers}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterHandlersInstance(instance []Handler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryHandlers != nil {
+		if err := r.duplicateRegistration("Handlers", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryHandlers = func(context.Context, ServiceLocator) ([]Handler, error) {
+		return instance, nil
+	}
+	r.instanceHandlers = instance
+	r.constructedHandlers = true
+	r.generationHandlers++
+
+	service := serviceID("Handlers", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateHandlers registers a decorator wrapping instances of {Handlers}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateHandlers(decorator ServiceDecorator[[]Handler]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsHandlers = append(r.decoratorsHandlers, decorator)
+}
+
+// UnregisterHandlers removes the factory of {Handlers}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterHandlers() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryHandlers = nil
+	r.generationHandlers++
+	var zero []Handler
+	r.instanceHandlers = zero
+	r.constructedHandlers = false
+	delete(r.instanceInfo, "Handlers")
+}
+
+// GetHandlers retrieves an instance of {Handlers}.
+func (r *ServiceRegistry) GetHandlers() ([]Handler, error) {
+	return r.GetHandlersContext(context.Background())
+}
+
+// GetHandlersContext is like {ServiceRegistry.GetHandlers}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetHandlersContext(ctx context.Context) ([]Handler, error) {
+	return r.getHandlers(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getHandlers(c *serviceLocationContext) ([]Handler, error) {
+	var zero []Handler
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Handlers", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "Handlers", "")
+
+	if r.constructedHandlers {
+		instance := r.instanceHandlers
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Handlers", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("Handlers", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Handlers", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Handlers", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callHandlers; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Handlers", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("Handlers", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryHandlers
+	factoryOk := factory != nil
+	decorators := r.decoratorsHandlers
+	generation := r.generationHandlers
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Handlers",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("Handlers", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[[]Handler]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callHandlers = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Handlers", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Handlers", "", err)
+		}
+
+		r.mu.Lock()
+		r.callHandlers = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Handlers", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Handlers", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Handlers", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationHandlers == generation {
+			r.instanceHandlers = call.instance
+			r.constructedHandlers = true
+			r.instanceInfo[serviceID("Handlers", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterJob registers a factory for {Job}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterJob(serviceName string, factory NamedServiceFactory[*Job]) error {
+	return r.RegisterJobContext(serviceName, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Job, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterJobContext registers a factory for {Job} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterJobContext(serviceName string, factory NamedContextServiceFactory[*Job]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factoriesJob[serviceName]; ok {
+		if err := r.duplicateRegistration("Job", serviceName); err != nil {
+			return err
+		}
+	}
+
+	r.factoriesJob[serviceName] = factory
+
+	return nil
+}
+
+// DecorateJob registers a decorator wrapping instances of {Job}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+func (r *ServiceRegistry) DecorateJob(serviceName string, decorator ServiceDecorator[*Job]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsJob[serviceName] = append(r.decoratorsJob[serviceName], decorator)
+}
+
+// UnregisterJob removes the factory of {Job}.
+func (r *ServiceRegistry) UnregisterJob(serviceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.factoriesJob, serviceName)
+}
+
+// RegisterJobPattern registers a factory for {Job} used for names without a factory of their own matching pattern.
+//
+// Patterns use the syntax of {path.Match} (eg. "cache.*").
+// If multiple patterns match a name, the pattern registered first is used.
+// Registering a factory for the same pattern again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterJobPattern(pattern string, factory NamedServiceFactory[*Job]) error {
+	return r.RegisterJobPatternContext(pattern, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Job, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterJobPatternContext is like {ServiceRegistry.RegisterJobPattern}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterJobPatternContext(pattern string, factory NamedContextServiceFactory[*Job]) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for Job: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered := false
+
+	for i := range r.patternFactoriesJob {
+		if r.patternFactoriesJob[i].pattern != pattern {
+			continue
+		}
+
+		if err := r.duplicateRegistration("Job", pattern); err != nil {
+			return err
+		}
+
+		r.patternFactoriesJob[i].factory = factory
+		registered = true
+	}
+
+	if !registered {
+		r.patternFactoriesJob = append(r.patternFactoriesJob, namedServiceFactoryPattern[*Job]{
+			factory: factory,
+			pattern: pattern,
+		})
+	}
+
+	return nil
+}
+
+// UnregisterJobPattern removes the factory of {Job} registered for pattern.
+func (r *ServiceRegistry) UnregisterJobPattern(pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.patternFactoriesJob {
+		if p.pattern == pattern {
+			r.patternFactoriesJob = append(r.patternFactoriesJob[:i:i], r.patternFactoriesJob[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// RegisterJobDefault registers a factory for {Job} used for names without a factory of their own (or a matching pattern).
+//
+// Registering a default factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterJobDefault(factory NamedServiceFactory[*Job]) error {
+	return r.RegisterJobDefaultContext(func(_ context.Context, name string, serviceLocator ServiceLocator) (*Job, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterJobDefaultContext is like {ServiceRegistry.RegisterJobDefault}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterJobDefaultContext(factory NamedContextServiceFactory[*Job]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.defaultFactoryJob != nil {
+		if err := r.duplicateRegistration("Job", "*"); err != nil {
+			return err
+		}
+	}
+
+	r.defaultFactoryJob = factory
+
+	return nil
+}
+
+// UnregisterJobDefault removes the default factory of {Job}.
+func (r *ServiceRegistry) UnregisterJobDefault() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultFactoryJob = nil
+}
+
+// lookupJob returns the factory of {Job} for serviceName
+// (falling back to pattern factories and the default factory).
+func (r *ServiceRegistry) lookupJob(serviceName string) (NamedContextServiceFactory[*Job], bool) {
+	if factory, ok := r.factoriesJob[serviceName]; ok {
+		return factory, true
+	}
+
+	for _, p := range r.patternFactoriesJob {
+		if ok, _ := path.Match(p.pattern, serviceName); ok {
+			return p.factory, true
+		}
+	}
+
+	return r.defaultFactoryJob, r.defaultFactoryJob != nil
+}
+
+// GetJob creates a new instance of {Job}.
+func (r *ServiceRegistry) GetJob(serviceName string) (*Job, error) {
+	return r.GetJobContext(context.Background(), serviceName)
+}
+
+// GetJobContext is like {ServiceRegistry.GetJob}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetJobContext(ctx context.Context, serviceName string) (*Job, error) {
+	return r.getJob(serviceName, newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+// JobNames returns the names factories of {Job} are registered with (sorted).
+func (r *ServiceRegistry) JobNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedNames(r.factoriesJob)
+}
+
+// GetAllJob retrieves an instance of {Job} for every registered name.
+func (r *ServiceRegistry) GetAllJob() (map[string]*Job, error) {
+	return r.GetAllJobContext(context.Background())
+}
+
+// GetAllJobContext is like {ServiceRegistry.GetAllJob}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetAllJobContext(ctx context.Context) (map[string]*Job, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetAllJob()
+}
+
+func (r *ServiceRegistry) getJob(serviceName string, c *serviceLocationContext) (*Job, error) {
+	var zero *Job
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Job", serviceName, c.path())
+	}
+
+	// Transient services may be requested multiple times during the same resolution,
+	// but not while being constructed.
+	if c.isConstructing("Job", serviceName) {
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Job", serviceName, c.path())
+		}
+
+		return zero, c.circularDependencyError("Job", serviceName)
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.recordDependency(c, "Job", serviceName)
+	factory, factoryOk := r.lookupJob(serviceName)
+	decorators := r.decoratorsJob[serviceName]
+	r.mu.Unlock()
+
+	if closed {
+		return zero, ErrServiceRegistryClosed
+	}
+
+	if !factoryOk {
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceName: serviceName,
+			ServiceType: "Job",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	child, err := c.enter("Job", serviceName)
+	if err != nil {
+		return zero, err
+	}
+
+	var start time.Time
+
+	// Spans started by FactoryStart must end if the factory (or a decorator) panics
+	defer func() {
+		if p := recover(); p != nil {
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Job", serviceName, child.path(), time.Since(start), fmt.Errorf("panic: %v", p))
+			}
+
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Job", serviceName, child.path())
+	}
+	start = time.Now()
+
+	instance, err := factory(child.ctx, serviceName, child)
+	if err == nil {
+		instance, err = decorate(child, instance, decorators)
+	}
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Job", serviceName, child.path(), time.Since(start), err)
+	}
+
+	if err != nil {
+		return zero, child.constructionError("Job", serviceName, err)
+	}
+
+	return instance, nil
+}
+
+// RegisterMailer registers a factory for {Mailer}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// A factory calling {NewSMTPMailer} is registered by default: replacing it is not subject to the policy.
+func (r *ServiceRegistry) RegisterMailer(factory ServiceFactory[Mailer]) error {
+	return r.RegisterMailerContext(func(_ context.Context, serviceLocator ServiceLocator) (Mailer, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterMailerContext registers a factory for {Mailer} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterMailerContext(factory ContextServiceFactory[Mailer]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryMailer != nil && !r.constructorFactoryMailer {
+		if err := r.duplicateRegistration("Mailer", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryMailer = factory
+	r.constructorFactoryMailer = false
+
+	return nil
+}
+
+// DecorateMailer registers a decorator wrapping instances of {Mailer}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+func (r *ServiceRegistry) DecorateMailer(decorator ServiceDecorator[Mailer]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsMailer = append(r.decoratorsMailer, decorator)
+}
+
+// UnregisterMailer removes the factory of {Mailer}.
+func (r *ServiceRegistry) UnregisterMailer() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryMailer = nil
+	r.constructorFactoryMailer = false
+}
+
+// GetMailer creates a new instance of {Mailer}.
+func (r *ServiceRegistry) GetMailer() (Mailer, error) {
+	return r.GetMailerContext(context.Background())
+}
+
+// GetMailerContext is like {ServiceRegistry.GetMailer}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetMailerContext(ctx context.Context) (Mailer, error) {
+	return r.getMailer(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getMailer(c *serviceLocationContext) (Mailer, error) {
+	var zero Mailer
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Mailer", "", c.path())
+	}
+
+	// Transient services may be requested multiple times during the same resolution,
+	// but not while being constructed.
+	if c.isConstructing("Mailer", "") {
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Mailer", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Mailer", "")
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.recordDependency(c, "Mailer", "")
+	factory := r.factoryMailer
+	factoryOk := factory != nil
+	decorators := r.decoratorsMailer
+	r.mu.Unlock()
+
+	if closed {
+		return zero, ErrServiceRegistryClosed
+	}
+
+	if !factoryOk {
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Mailer",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	child, err := c.enter("Mailer", "")
+	if err != nil {
+		return zero, err
+	}
+
+	var start time.Time
+
+	// Spans started by FactoryStart must end if the factory (or a decorator) panics
+	defer func() {
+		if p := recover(); p != nil {
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Mailer", "", child.path(), time.Since(start), fmt.Errorf("panic: %v", p))
+			}
+
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Mailer", "", child.path())
+	}
+	start = time.Now()
+
+	instance, err := factory(child.ctx, child)
+	if err == nil {
+		instance, err = decorate(child, instance, decorators)
+	}
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Mailer", "", child.path(), time.Since(start), err)
+	}
+
+	if err != nil {
+		return zero, child.constructionError("Mailer", "", err)
+	}
+
+	return instance, nil
+}
+
+// RegisterRequest registers a factory for {Request}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterRequest(factory ServiceFactory[*Request]) error {
+	return r.RegisterRequestContext(func(_ context.Context, serviceLocator ServiceLocator) (*Request, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterRequestContext registers a factory for {Request} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterRequestContext(factory ContextServiceFactory[*Request]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryRequest != nil {
+		if err := r.duplicateRegistration("Request", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryRequest = factory
+
+	return nil
+}
+
+// DecorateRequest registers a decorator wrapping instances of {Request}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateRequest(decorator ServiceDecorator[*Request]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsRequest = append(r.decoratorsRequest, decorator)
+}
+
+// UnregisterRequest removes the factory of {Request}.
+func (r *ServiceRegistry) UnregisterRequest() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryRequest = nil
+}
+
+// GetRequest retrieves an instance of {Request}.
+// Request is a scoped service: it can only be retrieved from a {ServiceScope}.
+func (r *ServiceRegistry) GetRequest() (*Request, error) {
+	return r.GetRequestContext(context.Background())
+}
+
+// GetRequestContext is like {ServiceRegistry.GetRequest}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetRequestContext(ctx context.Context) (*Request, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetRequest()
+}
+
+// RegisterServiceA registers a factory for {ServiceA}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterServiceA(factory ServiceFactory[ServiceA]) error {
+	return r.RegisterServiceAContext(func(_ context.Context, serviceLocator ServiceLocator) (ServiceA, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterServiceAContext registers a factory for {ServiceA} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterServiceAContext(factory ContextServiceFactory[ServiceA]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryServiceA != nil {
+		if err := r.duplicateRegistration("ServiceA", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryServiceA = factory
+	r.generationServiceA++
+	var zero ServiceA
+	r.instanceServiceA = zero
+	r.constructedServiceA = false
+	delete(r.instanceInfo, "ServiceA")
+
+	return nil
+}
+
+// RegisterServiceAInstance registers an instance of {ServiceA}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterServiceAInstance(instance ServiceA) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryServiceA != nil {
+		if err := r.duplicateRegistration("ServiceA", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryServiceA = func(context.Context, ServiceLocator) (ServiceA, error) {
+		return instance, nil
+	}
+	r.instanceServiceA = instance
+	r.constructedServiceA = true
+	r.generationServiceA++
+
+	service := serviceID("ServiceA", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateServiceA registers a decorator wrapping instances of {ServiceA}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateServiceA(decorator ServiceDecorator[ServiceA]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsServiceA = append(r.decoratorsServiceA, decorator)
+}
+
+// UnregisterServiceA removes the factory of {ServiceA}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterServiceA() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryServiceA = nil
+	r.generationServiceA++
+	var zero ServiceA
+	r.instanceServiceA = zero
+	r.constructedServiceA = false
+	delete(r.instanceInfo, "ServiceA")
+}
+
+// GetServiceA retrieves an instance of {ServiceA}.
+func (r *ServiceRegistry) GetServiceA() (ServiceA, error) {
+	return r.GetServiceAContext(context.Background())
+}
+
+// GetServiceAContext is like {ServiceRegistry.GetServiceA}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetServiceAContext(ctx context.Context) (ServiceA, error) {
+	return r.getServiceA(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getServiceA(c *serviceLocationContext) (ServiceA, error) {
+	var zero ServiceA
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "ServiceA", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "ServiceA", "")
+
+	if r.constructedServiceA {
+		instance := r.instanceServiceA
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "ServiceA", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("ServiceA", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "ServiceA", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("ServiceA", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callServiceA; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "ServiceA", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("ServiceA", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryServiceA
+	factoryOk := factory != nil
+	decorators := r.decoratorsServiceA
+	generation := r.generationServiceA
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "ServiceA",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("ServiceA", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[ServiceA]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callServiceA = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "ServiceA", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("ServiceA", "", err)
+		}
+
+		r.mu.Lock()
+		r.callServiceA = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "ServiceA", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "ServiceA", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("ServiceA", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationServiceA == generation {
+			r.instanceServiceA = call.instance
+			r.constructedServiceA = true
+			r.instanceInfo[serviceID("ServiceA", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterServiceB registers a factory for {ServiceB}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterServiceB(serviceName string, factory NamedServiceFactory[ServiceB]) error {
+	return r.RegisterServiceBContext(serviceName, func(_ context.Context, name string, serviceLocator ServiceLocator) (ServiceB, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterServiceBContext registers a factory for {ServiceB} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterServiceBContext(serviceName string, factory NamedContextServiceFactory[ServiceB]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factoriesServiceB[serviceName]; ok {
+		if err := r.duplicateRegistration("ServiceB", serviceName); err != nil {
+			return err
+		}
+	}
+
+	r.factoriesServiceB[serviceName] = factory
+	r.generationsServiceB[serviceName]++
+	delete(r.instancesServiceB, serviceName)
+	delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+
+	return nil
+}
+
+// RegisterServiceBInstance registers an instance of {ServiceB}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterServiceBInstance(serviceName string, instance ServiceB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factoriesServiceB[serviceName]; ok {
+		if err := r.duplicateRegistration("ServiceB", serviceName); err != nil {
+			return err
+		}
+	}
+
+	r.factoriesServiceB[serviceName] = func(context.Context, string, ServiceLocator) (ServiceB, error) {
+		return instance, nil
+	}
+	r.instancesServiceB[serviceName] = instance
+	r.generationsServiceB[serviceName]++
+
+	service := serviceID("ServiceB", serviceName)
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{Name: serviceName, ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateServiceB registers a decorator wrapping instances of {ServiceB}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateServiceB(serviceName string, decorator ServiceDecorator[ServiceB]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsServiceB[serviceName] = append(r.decoratorsServiceB[serviceName], decorator)
+}
+
+// UnregisterServiceB removes the factory of {ServiceB}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterServiceB(serviceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.factoriesServiceB, serviceName)
+	r.generationsServiceB[serviceName]++
+	delete(r.instancesServiceB, serviceName)
+	delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+}
+
+// RegisterServiceBPattern registers a factory for {ServiceB} used for names without a factory of their own matching pattern.
+//
+// Patterns use the syntax of {path.Match} (eg. "cache.*").
+// If multiple patterns match a name, the pattern registered first is used.
+// Registering a factory for the same pattern again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterServiceBPattern(pattern string, factory NamedServiceFactory[ServiceB]) error {
+	return r.RegisterServiceBPatternContext(pattern, func(_ context.Context, name string, serviceLocator ServiceLocator) (ServiceB, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterServiceBPatternContext is like {ServiceRegistry.RegisterServiceBPattern}, but registers a factory that accepts the context of the resolution.
+//
+// Instances constructed by pattern and default factories are discarded.
+func (r *ServiceRegistry) RegisterServiceBPatternContext(pattern string, factory NamedContextServiceFactory[ServiceB]) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for ServiceB: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered := false
+
+	for i := range r.patternFactoriesServiceB {
+		if r.patternFactoriesServiceB[i].pattern != pattern {
+			continue
+		}
+
+		if err := r.duplicateRegistration("ServiceB", pattern); err != nil {
+			return err
+		}
+
+		r.patternFactoriesServiceB[i].factory = factory
+		registered = true
+	}
+
+	if !registered {
+		r.patternFactoriesServiceB = append(r.patternFactoriesServiceB, namedServiceFactoryPattern[ServiceB]{
+			factory: factory,
+			pattern: pattern,
+		})
+	}
+
+	r.fallbackGenerationServiceB++
+	for serviceName := range r.instancesServiceB {
+		if _, ok := r.factoriesServiceB[serviceName]; !ok {
+			delete(r.instancesServiceB, serviceName)
+			delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+		}
+	}
+
+	return nil
+}
+
+// UnregisterServiceBPattern removes the factory of {ServiceB} registered for pattern.
+func (r *ServiceRegistry) UnregisterServiceBPattern(pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.patternFactoriesServiceB {
+		if p.pattern == pattern {
+			r.patternFactoriesServiceB = append(r.patternFactoriesServiceB[:i:i], r.patternFactoriesServiceB[i+1:]...)
+
+			break
+		}
+	}
+
+	r.fallbackGenerationServiceB++
+	for serviceName := range r.instancesServiceB {
+		if _, ok := r.factoriesServiceB[serviceName]; !ok {
+			delete(r.instancesServiceB, serviceName)
+			delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+		}
+	}
+}
+
+// RegisterServiceBDefault registers a factory for {ServiceB} used for names without a factory of their own (or a matching pattern).
+//
+// Registering a default factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterServiceBDefault(factory NamedServiceFactory[ServiceB]) error {
+	return r.RegisterServiceBDefaultContext(func(_ context.Context, name string, serviceLocator ServiceLocator) (ServiceB, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterServiceBDefaultContext is like {ServiceRegistry.RegisterServiceBDefault}, but registers a factory that accepts the context of the resolution.
+//
+// Instances constructed by pattern and default factories are discarded.
+func (r *ServiceRegistry) RegisterServiceBDefaultContext(factory NamedContextServiceFactory[ServiceB]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.defaultFactoryServiceB != nil {
+		if err := r.duplicateRegistration("ServiceB", "*"); err != nil {
+			return err
+		}
+	}
+
+	r.defaultFactoryServiceB = factory
+
+	r.fallbackGenerationServiceB++
+	for serviceName := range r.instancesServiceB {
+		if _, ok := r.factoriesServiceB[serviceName]; !ok {
+			delete(r.instancesServiceB, serviceName)
+			delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+		}
+	}
+
+	return nil
+}
+
+// UnregisterServiceBDefault removes the default factory of {ServiceB}.
+func (r *ServiceRegistry) UnregisterServiceBDefault() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultFactoryServiceB = nil
+
+	r.fallbackGenerationServiceB++
+	for serviceName := range r.instancesServiceB {
+		if _, ok := r.factoriesServiceB[serviceName]; !ok {
+			delete(r.instancesServiceB, serviceName)
+			delete(r.instanceInfo, serviceID("ServiceB", serviceName))
+		}
+	}
+}
+
+// lookupServiceB returns the factory of {ServiceB} for serviceName
+// (falling back to pattern factories and the default factory).
+func (r *ServiceRegistry) lookupServiceB(serviceName string) (NamedContextServiceFactory[ServiceB], bool) {
+	if factory, ok := r.factoriesServiceB[serviceName]; ok {
+		return factory, true
+	}
+
+	for _, p := range r.patternFactoriesServiceB {
+		if ok, _ := path.Match(p.pattern, serviceName); ok {
+			return p.factory, true
+		}
+	}
+
+	return r.defaultFactoryServiceB, r.defaultFactoryServiceB != nil
+}
+
+// GetServiceB retrieves an instance of {ServiceB}.
+func (r *ServiceRegistry) GetServiceB(serviceName string) (ServiceB, error) {
+	return r.GetServiceBContext(context.Background(), serviceName)
+}
+
+// GetServiceBContext is like {ServiceRegistry.GetServiceB}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetServiceBContext(ctx context.Context, serviceName string) (ServiceB, error) {
+	return r.getServiceB(serviceName, newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+// ServiceBNames returns the names factories of {ServiceB} are registered with (sorted).
+func (r *ServiceRegistry) ServiceBNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedNames(r.factoriesServiceB)
+}
+
+// GetAllServiceB retrieves an instance of {ServiceB} for every registered name.
+func (r *ServiceRegistry) GetAllServiceB() (map[string]ServiceB, error) {
+	return r.GetAllServiceBContext(context.Background())
+}
+
+// GetAllServiceBContext is like {ServiceRegistry.GetAllServiceB}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetAllServiceBContext(ctx context.Context) (map[string]ServiceB, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetAllServiceB()
+}
+
+func (r *ServiceRegistry) getServiceB(serviceName string, c *serviceLocationContext) (ServiceB, error) {
+	var zero ServiceB
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "ServiceB", serviceName, c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "ServiceB", serviceName)
+
+	if instance, ok := r.instancesServiceB[serviceName]; ok {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "ServiceB", serviceName, c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("ServiceB", serviceName) {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "ServiceB", serviceName, c.path())
+		}
+
+		return zero, c.circularDependencyError("ServiceB", serviceName)
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callsServiceB[serviceName]; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "ServiceB", serviceName, c.path())
+			}
+
+			return zero, c.circularDependencyError("ServiceB", serviceName)
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory, factoryOk := r.lookupServiceB(serviceName)
+	decorators := r.decoratorsServiceB[serviceName]
+	_, exact := r.factoriesServiceB[serviceName]
+	generation := r.generationsServiceB[serviceName]
+	fallbackGeneration := r.fallbackGenerationServiceB
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceName: serviceName,
+			ServiceType: "ServiceB",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("ServiceB", serviceName)
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[ServiceB]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callsServiceB[serviceName] = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "ServiceB", serviceName, child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("ServiceB", serviceName, err)
+		}
+
+		r.mu.Lock()
+		delete(r.callsServiceB, serviceName)
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "ServiceB", serviceName, child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, serviceName, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "ServiceB", serviceName, child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("ServiceB", serviceName, call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction:
+		// pattern and default factories only matter for names without a factory of their own
+		if r.generationsServiceB[serviceName] == generation && (exact || r.fallbackGenerationServiceB == fallbackGeneration) {
+			r.instancesServiceB[serviceName] = call.instance
+			r.instanceInfo[serviceID("ServiceB", serviceName)] = ServiceInstanceInfo{Name: serviceName, ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterServiceC registers a factory for {ServiceC}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterServiceC(factory ServiceFactory[subtest.ServiceC]) error {
+	return r.RegisterServiceCContext(func(_ context.Context, serviceLocator ServiceLocator) (subtest.ServiceC, error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterServiceCContext registers a factory for {ServiceC} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterServiceCContext(factory ContextServiceFactory[subtest.ServiceC]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryServiceC != nil {
+		if err := r.duplicateRegistration("ServiceC", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryServiceC = factory
+	r.generationServiceC++
+	var zero subtest.ServiceC
+	r.instanceServiceC = zero
+	r.constructedServiceC = false
+	delete(r.instanceInfo, "ServiceC")
+
+	return nil
+}
+
+// RegisterServiceCInstance registers an instance of {ServiceC}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterServiceCInstance(instance subtest.ServiceC) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryServiceC != nil {
+		if err := r.duplicateRegistration("ServiceC", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryServiceC = func(context.Context, ServiceLocator) (subtest.ServiceC, error) {
+		return instance, nil
+	}
+	r.instanceServiceC = instance
+	r.constructedServiceC = true
+	r.generationServiceC++
+
+	service := serviceID("ServiceC", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateServiceC registers a decorator wrapping instances of {ServiceC}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateServiceC(decorator ServiceDecorator[subtest.ServiceC]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsServiceC = append(r.decoratorsServiceC, decorator)
+}
+
+// UnregisterServiceC removes the factory of {ServiceC}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterServiceC() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryServiceC = nil
+	r.generationServiceC++
+	var zero subtest.ServiceC
+	r.instanceServiceC = zero
+	r.constructedServiceC = false
+	delete(r.instanceInfo, "ServiceC")
+}
+
+// GetServiceC retrieves an instance of {ServiceC}.
+func (r *ServiceRegistry) GetServiceC() (subtest.ServiceC, error) {
+	return r.GetServiceCContext(context.Background())
+}
+
+// GetServiceCContext is like {ServiceRegistry.GetServiceC}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetServiceCContext(ctx context.Context) (subtest.ServiceC, error) {
+	return r.getServiceC(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getServiceC(c *serviceLocationContext) (subtest.ServiceC, error) {
+	var zero subtest.ServiceC
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "ServiceC", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "ServiceC", "")
+
+	if r.constructedServiceC {
+		instance := r.instanceServiceC
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "ServiceC", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("ServiceC", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "ServiceC", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("ServiceC", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callServiceC; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "ServiceC", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("ServiceC", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryServiceC
+	factoryOk := factory != nil
+	decorators := r.decoratorsServiceC
+	generation := r.generationServiceC
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "ServiceC",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("ServiceC", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[subtest.ServiceC]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callServiceC = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "ServiceC", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("ServiceC", "", err)
+		}
+
+		r.mu.Lock()
+		r.callServiceC = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "ServiceC", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "ServiceC", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("ServiceC", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationServiceC == generation {
+			r.instanceServiceC = call.instance
+			r.constructedServiceC = true
+			r.instanceInfo[serviceID("ServiceC", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterSession registers a factory for {Session}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterSession(serviceName string, factory NamedServiceFactory[*Session]) error {
+	return r.RegisterSessionContext(serviceName, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Session, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterSessionContext registers a factory for {Session} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterSessionContext(serviceName string, factory NamedContextServiceFactory[*Session]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factoriesSession[serviceName]; ok {
+		if err := r.duplicateRegistration("Session", serviceName); err != nil {
+			return err
+		}
+	}
+
+	r.factoriesSession[serviceName] = factory
+
+	return nil
+}
+
+// DecorateSession registers a decorator wrapping instances of {Session}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateSession(serviceName string, decorator ServiceDecorator[*Session]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsSession[serviceName] = append(r.decoratorsSession[serviceName], decorator)
+}
+
+// UnregisterSession removes the factory of {Session}.
+func (r *ServiceRegistry) UnregisterSession(serviceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.factoriesSession, serviceName)
+}
+
+// RegisterSessionPattern registers a factory for {Session} used for names without a factory of their own matching pattern.
+//
+// Patterns use the syntax of {path.Match} (eg. "cache.*").
+// If multiple patterns match a name, the pattern registered first is used.
+// Registering a factory for the same pattern again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterSessionPattern(pattern string, factory NamedServiceFactory[*Session]) error {
+	return r.RegisterSessionPatternContext(pattern, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Session, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterSessionPatternContext is like {ServiceRegistry.RegisterSessionPattern}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterSessionPatternContext(pattern string, factory NamedContextServiceFactory[*Session]) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for Session: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered := false
+
+	for i := range r.patternFactoriesSession {
+		if r.patternFactoriesSession[i].pattern != pattern {
+			continue
+		}
+
+		if err := r.duplicateRegistration("Session", pattern); err != nil {
+			return err
+		}
+
+		r.patternFactoriesSession[i].factory = factory
+		registered = true
+	}
+
+	if !registered {
+		r.patternFactoriesSession = append(r.patternFactoriesSession, namedServiceFactoryPattern[*Session]{
+			factory: factory,
+			pattern: pattern,
+		})
+	}
+
+	return nil
+}
+
+// UnregisterSessionPattern removes the factory of {Session} registered for pattern.
+func (r *ServiceRegistry) UnregisterSessionPattern(pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.patternFactoriesSession {
+		if p.pattern == pattern {
+			r.patternFactoriesSession = append(r.patternFactoriesSession[:i:i], r.patternFactoriesSession[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// RegisterSessionDefault registers a factory for {Session} used for names without a factory of their own (or a matching pattern).
+//
+// Registering a default factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterSessionDefault(factory NamedServiceFactory[*Session]) error {
+	return r.RegisterSessionDefaultContext(func(_ context.Context, name string, serviceLocator ServiceLocator) (*Session, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterSessionDefaultContext is like {ServiceRegistry.RegisterSessionDefault}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterSessionDefaultContext(factory NamedContextServiceFactory[*Session]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.defaultFactorySession != nil {
+		if err := r.duplicateRegistration("Session", "*"); err != nil {
+			return err
+		}
+	}
+
+	r.defaultFactorySession = factory
+
+	return nil
+}
+
+// UnregisterSessionDefault removes the default factory of {Session}.
+func (r *ServiceRegistry) UnregisterSessionDefault() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultFactorySession = nil
+}
+
+// lookupSession returns the factory of {Session} for serviceName
+// (falling back to pattern factories and the default factory).
+func (r *ServiceRegistry) lookupSession(serviceName string) (NamedContextServiceFactory[*Session], bool) {
+	if factory, ok := r.factoriesSession[serviceName]; ok {
+		return factory, true
+	}
+
+	for _, p := range r.patternFactoriesSession {
+		if ok, _ := path.Match(p.pattern, serviceName); ok {
+			return p.factory, true
+		}
+	}
+
+	return r.defaultFactorySession, r.defaultFactorySession != nil
+}
+
+// GetSession retrieves an instance of {Session}.
+// Session is a scoped service: it can only be retrieved from a {ServiceScope}.
+func (r *ServiceRegistry) GetSession(serviceName string) (*Session, error) {
+	return r.GetSessionContext(context.Background(), serviceName)
+}
+
+// GetSessionContext is like {ServiceRegistry.GetSession}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetSessionContext(ctx context.Context, serviceName string) (*Session, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetSession(serviceName)
+}
+
+// SessionNames returns the names factories of {Session} are registered with (sorted).
+func (r *ServiceRegistry) SessionNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedNames(r.factoriesSession)
+}
+
+// GetAllSession retrieves an instance of {Session} for every registered name.
+func (r *ServiceRegistry) GetAllSession() (map[string]*Session, error) {
+	return r.GetAllSessionContext(context.Background())
+}
+
+// GetAllSessionContext is like {ServiceRegistry.GetAllSession}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetAllSessionContext(ctx context.Context) (map[string]*Session, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetAllSession()
+}
+
+// RegisterUserRepo registers a factory for {UserRepo}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterUserRepo(factory ServiceFactory[Repo[User]]) error {
+	return r.RegisterUserRepoContext(func(_ context.Context, serviceLocator ServiceLocator) (Repo[User], error) {
+		return factory(serviceLocator)
+	})
+}
+
+// RegisterUserRepoContext registers a factory for {UserRepo} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+// Overriding a factory discards the instance constructed by the previous one.
+func (r *ServiceRegistry) RegisterUserRepoContext(factory ContextServiceFactory[Repo[User]]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryUserRepo != nil {
+		if err := r.duplicateRegistration("UserRepo", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryUserRepo = factory
+	r.generationUserRepo++
+	var zero Repo[User]
+	r.instanceUserRepo = zero
+	r.constructedUserRepo = false
+	delete(r.instanceInfo, "UserRepo")
+
+	return nil
+}
+
+// RegisterUserRepoInstance registers an instance of {UserRepo}.
+//
+// Registering an instance is subject to the {RegistrationPolicy} of the registry (just like registering a factory).
+// The instance is closed when closing the registry.
+func (r *ServiceRegistry) RegisterUserRepoInstance(instance Repo[User]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.factoryUserRepo != nil {
+		if err := r.duplicateRegistration("UserRepo", ""); err != nil {
+			return err
+		}
+	}
+
+	r.factoryUserRepo = func(context.Context, ServiceLocator) (Repo[User], error) {
+		return instance, nil
+	}
+	r.instanceUserRepo = instance
+	r.constructedUserRepo = true
+	r.generationUserRepo++
+
+	service := serviceID("UserRepo", "")
+
+	r.instanceOrder = append(r.instanceOrder, instance)
+	r.services[service] = struct{}{}
+	r.instanceInfo[service] = ServiceInstanceInfo{ConstructedAt: time.Now()}
+
+	return nil
+}
+
+// DecorateUserRepo registers a decorator wrapping instances of {UserRepo}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+// Instances constructed before registering a decorator (or registered as instances) are not decorated.
+func (r *ServiceRegistry) DecorateUserRepo(decorator ServiceDecorator[Repo[User]]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsUserRepo = append(r.decoratorsUserRepo, decorator)
+}
+
+// UnregisterUserRepo removes the factory of {UserRepo}.
+//
+// The instance constructed by the factory is discarded.
+func (r *ServiceRegistry) UnregisterUserRepo() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factoryUserRepo = nil
+	r.generationUserRepo++
+	var zero Repo[User]
+	r.instanceUserRepo = zero
+	r.constructedUserRepo = false
+	delete(r.instanceInfo, "UserRepo")
+}
+
+// GetUserRepo retrieves an instance of {UserRepo}.
+func (r *ServiceRegistry) GetUserRepo() (Repo[User], error) {
+	return r.GetUserRepoContext(context.Background())
+}
+
+// GetUserRepoContext is like {ServiceRegistry.GetUserRepo}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetUserRepoContext(ctx context.Context) (Repo[User], error) {
+	return r.getUserRepo(newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+func (r *ServiceRegistry) getUserRepo(c *serviceLocationContext) (Repo[User], error) {
+	var zero Repo[User]
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "UserRepo", "", c.path())
+	}
+
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	r.recordDependency(c, "UserRepo", "")
+
+	if r.constructedUserRepo {
+		instance := r.instanceUserRepo
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "UserRepo", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("UserRepo", "") {
+		r.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "UserRepo", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("UserRepo", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := r.callUserRepo; call != nil {
+		if r.wouldDeadlock(c.construction, call.owner) {
+			r.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "UserRepo", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("UserRepo", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		r.waiting[wait] = struct{}{}
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		r.mu.Lock()
+		delete(r.waiting, wait)
+		r.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := r.factoryUserRepo
+	factoryOk := factory != nil
+	decorators := r.decoratorsUserRepo
+	generation := r.generationUserRepo
+
+	if !factoryOk {
+		r.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "UserRepo",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("UserRepo", "")
+	if err != nil {
+		r.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[Repo[User]]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	r.callUserRepo = call
+	r.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "UserRepo", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("UserRepo", "", err)
+		}
+
+		r.mu.Lock()
+		r.callUserRepo = nil
+		r.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "UserRepo", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "UserRepo", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("UserRepo", "", call.err)
+	}
+
+	r.mu.Lock()
+	var closedErr error
+	if r.closed {
+		closedErr = ErrServiceRegistryClosed
+	}
+	if call.err == nil && closedErr == nil {
+		// The instance is not cached if the factory was replaced (or removed) during the construction
+		if r.generationUserRepo == generation {
+			r.instanceUserRepo = call.instance
+			r.constructedUserRepo = true
+			r.instanceInfo[serviceID("UserRepo", "")] = ServiceInstanceInfo{ConstructedAt: start, Duration: duration}
+		}
+
+		r.instanceOrder = append(r.instanceOrder, call.instance)
+	}
+	r.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// RegisterWorker registers a factory for {Worker}.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterWorker(serviceName string, factory NamedServiceFactory[*Worker]) error {
+	return r.RegisterWorkerContext(serviceName, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Worker, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterWorkerContext registers a factory for {Worker} that accepts the context of the resolution.
+//
+// Registering a factory again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterWorkerContext(serviceName string, factory NamedContextServiceFactory[*Worker]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factoriesWorker[serviceName]; ok {
+		if err := r.duplicateRegistration("Worker", serviceName); err != nil {
+			return err
+		}
+	}
+
+	r.factoriesWorker[serviceName] = factory
+
+	return nil
+}
+
+// DecorateWorker registers a decorator wrapping instances of {Worker}.
+//
+// Decorators are applied in registration order to instances returned by the factory.
+func (r *ServiceRegistry) DecorateWorker(serviceName string, decorator ServiceDecorator[*Worker]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decoratorsWorker[serviceName] = append(r.decoratorsWorker[serviceName], decorator)
+}
+
+// UnregisterWorker removes the factory of {Worker}.
+func (r *ServiceRegistry) UnregisterWorker(serviceName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.factoriesWorker, serviceName)
+}
+
+// RegisterWorkerPattern registers a factory for {Worker} used for names without a factory of their own matching pattern.
+//
+// Patterns use the syntax of {path.Match} (eg. "cache.*").
+// If multiple patterns match a name, the pattern registered first is used.
+// Registering a factory for the same pattern again is subject to the {RegistrationPolicy} of the registry.
+func (r *ServiceRegistry) RegisterWorkerPattern(pattern string, factory NamedServiceFactory[*Worker]) error {
+	return r.RegisterWorkerPatternContext(pattern, func(_ context.Context, name string, serviceLocator ServiceLocator) (*Worker, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterWorkerPatternContext is like {ServiceRegistry.RegisterWorkerPattern}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterWorkerPatternContext(pattern string, factory NamedContextServiceFactory[*Worker]) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for Worker: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registered := false
+
+	for i := range r.patternFactoriesWorker {
+		if r.patternFactoriesWorker[i].pattern != pattern {
+			continue
+		}
+
+		if err := r.duplicateRegistration("Worker", pattern); err != nil {
+			return err
+		}
+
+		r.patternFactoriesWorker[i].factory = factory
+		registered = true
+	}
+
+	if !registered {
+		r.patternFactoriesWorker = append(r.patternFactoriesWorker, namedServiceFactoryPattern[*Worker]{
+			factory: factory,
+			pattern: pattern,
+		})
+	}
+
+	return nil
+}
+
+// UnregisterWorkerPattern removes the factory of {Worker} registered for pattern.
+func (r *ServiceRegistry) UnregisterWorkerPattern(pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, p := range r.patternFactoriesWorker {
+		if p.pattern == pattern {
+			r.patternFactoriesWorker = append(r.patternFactoriesWorker[:i:i], r.patternFactoriesWorker[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// RegisterWorkerDefault registers a factory for {Worker} used for names without a factory of their own (or a matching pattern).
+//
+// Registering a default factory again is subject to the {RegistrationPolicy} of the registry.
+// A default factory calling {NewWorker} is registered by default: replacing it is not subject to the policy.
+func (r *ServiceRegistry) RegisterWorkerDefault(factory NamedServiceFactory[*Worker]) error {
+	return r.RegisterWorkerDefaultContext(func(_ context.Context, name string, serviceLocator ServiceLocator) (*Worker, error) {
+		return factory(name, serviceLocator)
+	})
+}
+
+// RegisterWorkerDefaultContext is like {ServiceRegistry.RegisterWorkerDefault}, but registers a factory that accepts the context of the resolution.
+func (r *ServiceRegistry) RegisterWorkerDefaultContext(factory NamedContextServiceFactory[*Worker]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.defaultFactoryWorker != nil && !r.constructorFactoryWorker {
+		if err := r.duplicateRegistration("Worker", "*"); err != nil {
+			return err
+		}
+	}
+
+	r.defaultFactoryWorker = factory
+	r.constructorFactoryWorker = false
+
+	return nil
+}
+
+// UnregisterWorkerDefault removes the default factory of {Worker}.
+func (r *ServiceRegistry) UnregisterWorkerDefault() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultFactoryWorker = nil
+	r.constructorFactoryWorker = false
+}
+
+// lookupWorker returns the factory of {Worker} for serviceName
+// (falling back to pattern factories and the default factory).
+func (r *ServiceRegistry) lookupWorker(serviceName string) (NamedContextServiceFactory[*Worker], bool) {
+	if factory, ok := r.factoriesWorker[serviceName]; ok {
+		return factory, true
+	}
+
+	for _, p := range r.patternFactoriesWorker {
+		if ok, _ := path.Match(p.pattern, serviceName); ok {
+			return p.factory, true
+		}
+	}
+
+	return r.defaultFactoryWorker, r.defaultFactoryWorker != nil
+}
+
+// GetWorker creates a new instance of {Worker}.
+func (r *ServiceRegistry) GetWorker(serviceName string) (*Worker, error) {
+	return r.GetWorkerContext(context.Background(), serviceName)
+}
+
+// GetWorkerContext is like {ServiceRegistry.GetWorker}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetWorkerContext(ctx context.Context, serviceName string) (*Worker, error) {
+	return r.getWorker(serviceName, newServiceLocationContext(ctx, r, nil, r.maxDepth))
+}
+
+// WorkerNames returns the names factories of {Worker} are registered with (sorted).
+func (r *ServiceRegistry) WorkerNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedNames(r.factoriesWorker)
+}
+
+// GetAllWorker retrieves an instance of {Worker} for every registered name.
+func (r *ServiceRegistry) GetAllWorker() (map[string]*Worker, error) {
+	return r.GetAllWorkerContext(context.Background())
+}
+
+// GetAllWorkerContext is like {ServiceRegistry.GetAllWorker}, but passes ctx to the factories called during the resolution.
+func (r *ServiceRegistry) GetAllWorkerContext(ctx context.Context) (map[string]*Worker, error) {
+	return newServiceLocationContext(ctx, r, nil, r.maxDepth).GetAllWorker()
+}
+
+func (r *ServiceRegistry) getWorker(serviceName string, c *serviceLocationContext) (*Worker, error) {
+	var zero *Worker
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Worker", serviceName, c.path())
+	}
+
+	// Transient services may be requested multiple times during the same resolution,
+	// but not while being constructed.
+	if c.isConstructing("Worker", serviceName) {
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Worker", serviceName, c.path())
+		}
+
+		return zero, c.circularDependencyError("Worker", serviceName)
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.recordDependency(c, "Worker", serviceName)
+	factory, factoryOk := r.lookupWorker(serviceName)
+	decorators := r.decoratorsWorker[serviceName]
+	r.mu.Unlock()
+
+	if closed {
+		return zero, ErrServiceRegistryClosed
+	}
+
+	if !factoryOk {
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceName: serviceName,
+			ServiceType: "Worker",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	child, err := c.enter("Worker", serviceName)
+	if err != nil {
+		return zero, err
+	}
+
+	var start time.Time
+
+	// Spans started by FactoryStart must end if the factory (or a decorator) panics
+	defer func() {
+		if p := recover(); p != nil {
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Worker", serviceName, child.path(), time.Since(start), fmt.Errorf("panic: %v", p))
+			}
+
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Worker", serviceName, child.path())
+	}
+	start = time.Now()
+
+	instance, err := factory(child.ctx, serviceName, child)
+	if err == nil {
+		instance, err = decorate(child, instance, decorators)
+	}
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Worker", serviceName, child.path(), time.Since(start), err)
+	}
+
+	if err != nil {
+		return zero, child.constructionError("Worker", serviceName, err)
+	}
+
+	return instance, nil
+}
+
+// duplicateRegistration applies the registration policy to registering a service again.
+func (r *ServiceRegistry) duplicateRegistration(serviceType, serviceName string) error {
+	err := ServiceAlreadyRegisteredError{
+		ServiceName: serviceName,
+		ServiceType: serviceType,
+	}
+
+	switch r.registrationPolicy {
+	case ErrorOnDuplicate:
+		return err
+	case PanicOnDuplicate:
+		panic(err)
+	}
+
+	return nil
+}
+
+// wouldDeadlock checks if waiter waiting for an instance constructed by owner would never return,
+// because owner is (directly or indirectly) waiting for an instance constructed by waiter (or by a service depending on it).
+//
+// It must be called while holding the registry lock.
+func (r *ServiceRegistry) wouldDeadlock(waiter, owner *serviceConstruction) bool {
+	// Nothing waits for the start of a resolution
+	if waiter == nil {
+		return false
+	}
+
+	// blocked lists constructions that cannot finish before owner finishes
+	blocked := []*serviceConstruction{owner}
+	seen := map[*serviceConstruction]bool{owner: true}
+
+	for i := 0; i < len(blocked); i++ {
+		if blocked[i].contains(waiter) {
+			return true
+		}
+
+		for wait := range r.waiting {
+			if !seen[wait.owner] && blocked[i].contains(wait.waiter) {
+				seen[wait.owner] = true
+				blocked = append(blocked, wait.owner)
+			}
+		}
+	}
+
+	return false
+}
+
+// recordDependency records that a service is resolved (by the service currently being constructed, if any).
+//
+// It must be called while holding the registry lock.
+func (r *ServiceRegistry) recordDependency(c *serviceLocationContext, serviceType, serviceName string) {
+	service := r.graphNode(serviceType, serviceName)
+
+	r.services[service] = struct{}{}
+
+	if dependent := c.construction; dependent != nil {
+		r.dependencies[ServiceDependency{
+			Dependency: service,
+			Service:    r.graphNode(dependent.serviceType, dependent.serviceName),
+		}] = struct{}{}
+	}
+}
+
+// graphNode identifies a service in the dependency graph.
+//
+// Names without a factory of their own (resolved by pattern or default factories) are recorded as "*",
+// so that resolving arbitrary names does not grow the graph.
+//
+// It must be called while holding the registry lock.
+func (r *ServiceRegistry) graphNode(serviceType, serviceName string) string {
+	switch serviceType {
+	case "Job":
+		if _, ok := r.factoriesJob[serviceName]; !ok {
+			return serviceID(serviceType, "*")
+		}
+	case "ServiceB":
+		if _, ok := r.factoriesServiceB[serviceName]; !ok {
+			return serviceID(serviceType, "*")
+		}
+	case "Session":
+		if _, ok := r.factoriesSession[serviceName]; !ok {
+			return serviceID(serviceType, "*")
+		}
+	case "Worker":
+		if _, ok := r.factoriesWorker[serviceName]; !ok {
+			return serviceID(serviceType, "*")
+		}
+	}
+
+	return serviceID(serviceType, serviceName)
+}
+
+// DependencyGraph returns the services resolved so far and their dependencies on each other.
+func (r *ServiceRegistry) DependencyGraph() DependencyGraph {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var graph DependencyGraph
+
+	for service := range r.services {
+		graph.Services = append(graph.Services, service)
+	}
+
+	for dependency := range r.dependencies {
+		graph.Dependencies = append(graph.Dependencies, dependency)
+	}
+
+	sort.Strings(graph.Services)
+	sort.Slice(graph.Dependencies, func(i, j int) bool {
+		a, b := graph.Dependencies[i], graph.Dependencies[j]
+
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+
+		return a.Dependency < b.Dependency
+	})
+
+	return graph
+}
+
+// Validate checks that a factory is registered for every service
+// (and every required name of named services).
+//
+// Missing factories are reported as {ServiceNotRegisteredError} combined with {errors.Join}.
+func (r *ServiceRegistry) Validate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+
+	if r.factoryAny == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Any"})
+	}
+
+	if r.factoryBuffer == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Buffer"})
+	}
+
+	if r.factoryClock == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Clock"})
+	}
+
+	if r.factoryConfig == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Config"})
+	}
+
+	if r.factoryDB == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "DB"})
+	}
+
+	if r.factoryHandlers == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Handlers"})
+	}
+
+	for _, serviceName := range []string{"job"} {
+		if _, ok := r.lookupJob(serviceName); !ok {
+			errs = append(errs, ServiceNotRegisteredError{
+				ServiceName: serviceName,
+				ServiceType: "Job",
+			})
+		}
+	}
+
+	if r.factoryMailer == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Mailer"})
+	}
+
+	if r.factoryRequest == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "Request"})
+	}
+
+	if r.factoryServiceA == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "ServiceA"})
+	}
+
+	if r.factoryServiceC == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "ServiceC"})
+	}
+
+	if r.factoryUserRepo == nil {
+		errs = append(errs, ServiceNotRegisteredError{ServiceType: "UserRepo"})
+	}
+
+	return errors.Join(errs...)
+}
+
+// ServiceInfo describes a service of a {ServiceRegistry}.
+type ServiceInfo struct {
+	// Name is the name of the service (eg. the name of the type).
+	Name string `json:"name"`
+
+	// Type is the type of the service qualified by its package name.
+	Type string `json:"type"`
+
+	// ImportPath is the import path of the package declaring the type of the service (if any).
+	ImportPath string `json:"importPath,omitempty"`
+
+	// Scope is either singleton, transient or scoped.
+	Scope string `json:"scope"`
+
+	Named bool `json:"named"`
+
+	// Names lists the names factories of a named service are registered with (sorted).
+	Names []string `json:"names,omitempty"`
+
+	// Registered reports whether a factory is registered for the service (or any name of a named service).
+	Registered bool `json:"registered"`
+
+	// Constructed reports whether an instance of the service is cached by the registry.
+	Constructed bool `json:"constructed"`
+
+	// Instances lists the instances cached by the registry (sorted by name).
+	Instances []ServiceInstanceInfo `json:"instances,omitempty"`
+
+	// Dependencies lists the services the service depends on (recorded when resolving services).
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// ServiceInstanceInfo describes an instance cached by the registry.
+type ServiceInstanceInfo struct {
+	// Name is the name of the instance (for named services).
+	Name string `json:"name,omitempty"`
+
+	// ConstructedAt is the time the construction of the instance started.
+	ConstructedAt time.Time `json:"constructedAt"`
+
+	// Duration is the time it took to construct the instance (zero for registered instances).
+	Duration time.Duration `json:"duration"`
+}
+
+// Services describes the services of the registry (in the order they are declared).
+//
+// Instances of scoped services are cached by scopes: they are not listed.
+func (r *ServiceRegistry) Services() []ServiceInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return []ServiceInfo{
+		r.serviceInfo(ServiceInfo{
+			Name:       "Any",
+			Type:       "any",
+			Scope:      "singleton",
+			Registered: r.factoryAny != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Buffer",
+			Type:       "*bytes.Buffer",
+			ImportPath: "bytes",
+			Scope:      "transient",
+			Registered: r.factoryBuffer != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Clock",
+			Type:       "func() time.Time",
+			Scope:      "singleton",
+			Registered: r.factoryClock != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Config",
+			Type:       "*test.Config",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "singleton",
+			Registered: r.factoryConfig != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "DB",
+			Type:       "*sql.DB",
+			ImportPath: "database/sql",
+			Scope:      "singleton",
+			Registered: r.factoryDB != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Handlers",
+			Type:       "[]test.Handler",
+			Scope:      "singleton",
+			Registered: r.factoryHandlers != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Job",
+			Type:       "*test.Job",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "transient",
+			Named:      true,
+			Names:      sortedNames(r.factoriesJob),
+			Registered: len(r.factoriesJob) > 0 || len(r.patternFactoriesJob) > 0 || r.defaultFactoryJob != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Mailer",
+			Type:       "test.Mailer",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "transient",
+			Registered: r.factoryMailer != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Request",
+			Type:       "*test.Request",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "scoped",
+			Registered: r.factoryRequest != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "ServiceA",
+			Type:       "test.ServiceA",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "singleton",
+			Registered: r.factoryServiceA != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "ServiceB",
+			Type:       "test.ServiceB",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "singleton",
+			Named:      true,
+			Names:      sortedNames(r.factoriesServiceB),
+			Registered: len(r.factoriesServiceB) > 0 || len(r.patternFactoriesServiceB) > 0 || r.defaultFactoryServiceB != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "ServiceC",
+			Type:       "subtest.ServiceC",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test/subtest",
+			Scope:      "singleton",
+			Registered: r.factoryServiceC != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Session",
+			Type:       "*test.Session",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "scoped",
+			Named:      true,
+			Names:      sortedNames(r.factoriesSession),
+			Registered: len(r.factoriesSession) > 0 || len(r.patternFactoriesSession) > 0 || r.defaultFactorySession != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "UserRepo",
+			Type:       "test.Repo[test.User]",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "singleton",
+			Registered: r.factoryUserRepo != nil,
+		}),
+		r.serviceInfo(ServiceInfo{
+			Name:       "Worker",
+			Type:       "*test.Worker",
+			ImportPath: "github.com/sagikazarmark/go-service-locator/test",
+			Scope:      "transient",
+			Named:      true,
+			Names:      sortedNames(r.factoriesWorker),
+			Registered: len(r.factoriesWorker) > 0 || len(r.patternFactoriesWorker) > 0 || r.defaultFactoryWorker != nil,
+		}),
+	}
+}
+
+// serviceInfo completes info with the instances and the dependencies recorded by the registry.
+//
+// It must be called while holding the registry lock.
+func (r *ServiceRegistry) serviceInfo(info ServiceInfo) ServiceInfo {
+	matches := func(service string) bool {
+		if info.Named {
+			return strings.HasPrefix(service, info.Name+":")
+		}
+
+		return service == info.Name
+	}
+
+	for service, instance := range r.instanceInfo {
+		if matches(service) {
+			info.Instances = append(info.Instances, instance)
+		}
+	}
+
+	sort.Slice(info.Instances, func(i, j int) bool {
+		return info.Instances[i].Name < info.Instances[j].Name
+	})
+
+	info.Constructed = len(info.Instances) > 0
+
+	seen := make(map[string]bool)
+
+	for dependency := range r.dependencies {
+		if matches(dependency.Service) && !seen[dependency.Dependency] {
+			seen[dependency.Dependency] = true
+			info.Dependencies = append(info.Dependencies, dependency.Dependency)
+		}
+	}
+
+	sort.Strings(info.Dependencies)
+
+	return info
+}
+
+// sortedNames returns the keys of m (sorted).
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// InitAll constructs every registered singleton (including every registered name of named services).
+//
+// Services are constructed concurrently by a limited number of workers (see {WithInitWorkers}),
+// so services that do not depend on each other are constructed in parallel.
+// Errors are combined with {errors.Join} (reporting each failing service once).
+func (r *ServiceRegistry) InitAll(ctx context.Context) error {
+	var inits []func() error
+
+	r.mu.Lock()
+
+	if r.factoryAny != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetAnyContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryClock != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetClockContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryConfig != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetConfigContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryDB != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetDBContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryHandlers != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetHandlersContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryServiceA != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetServiceAContext(ctx)
+
+			return err
+		})
+	}
+
+	namesServiceB := make([]string, 0, len(r.factoriesServiceB))
+	for serviceName := range r.factoriesServiceB {
+		namesServiceB = append(namesServiceB, serviceName)
+	}
+	sort.Strings(namesServiceB)
+
+	for _, serviceName := range namesServiceB {
+		serviceName := serviceName
+
+		inits = append(inits, func() error {
+			_, err := r.GetServiceBContext(ctx, serviceName)
+
+			return err
+		})
+	}
+
+	if r.factoryServiceC != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetServiceCContext(ctx)
+
+			return err
+		})
+	}
+
+	if r.factoryUserRepo != nil {
+		inits = append(inits, func() error {
+			_, err := r.GetUserRepoContext(ctx)
+
+			return err
+		})
+	}
+
+	workers := r.initWorkers
+	r.mu.Unlock()
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Services waiting for a dependency wait for the worker constructing it,
+	// so workers never wait for services that are not being constructed.
+	jobs := make(chan int)
+	errs := make([]error, len(inits))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers && i < len(inits); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				errs[job] = inits[job]()
+			}
+		}()
+	}
+
+	for job := range inits {
+		jobs <- job
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	// Services depending on a failed service report the failure of that service as well
+	var combined []error
+	seen := make(map[string]bool)
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		key := err.Error()
+
+		var constructionErr ServiceConstructionError
+		if errors.As(err, &constructionErr) {
+			key = serviceID(constructionErr.ServiceType, constructionErr.ServiceName)
+		}
+
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		combined = append(combined, err)
+	}
+
+	return errors.Join(combined...)
+}
+
+// Close closes service instances in reverse construction order.
+// Retrieving services from a closed registry returns {ErrServiceRegistryClosed}.
+//
+// Instances implementing either {io.Closer} or Close(context.Context) error are closed.
+// Instances of scoped services are closed by their scope.
+// Instances still under construction when calling Close are closed when their construction completes
+// (and the pending resolutions return {ErrServiceRegistryClosed}).
+func (r *ServiceRegistry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	instances := r.instanceOrder
+	r.instanceOrder = nil
+	r.mu.Unlock()
+
+	return closeInstances(ctx, instances)
+}
+
+// constructMailer constructs {Mailer} with {NewSMTPMailer}, resolving its parameters from the {ServiceLocator}.
+func constructMailer(ctx context.Context, serviceLocator ServiceLocator) (Mailer, error) {
+	var zero Mailer
+
+	config, err := serviceLocator.GetConfig()
+	if err != nil {
+		return zero, err
+	}
+
+	clock, err := serviceLocator.GetClock()
+	if err != nil {
+		return zero, err
+	}
+
+	instance, err := NewSMTPMailer(ctx, config, clock)
+	if err != nil {
+		return zero, err
+	}
+
+	return instance, nil
+}
+
+// constructWorker constructs {Worker} with {NewWorker}, resolving its parameters from the {ServiceLocator}.
+func constructWorker(ctx context.Context, name string, serviceLocator ServiceLocator) (*Worker, error) {
+	var zero *Worker
+
+	mailer, err := serviceLocator.GetMailer()
+	if err != nil {
+		return zero, err
+	}
+
+	return NewWorker(name, mailer), nil
+}
+
+// ServiceScope caches instances of scoped services (eg. for the duration of a request).
+// ServiceScope implements {ServiceLocator}: other services are resolved from the parent {ServiceRegistry}.
+type ServiceScope struct {
+	registry *ServiceRegistry
+
+	// The state of the scope is guarded by the registry lock.
+	instanceRequest    *Request
+	constructedRequest bool
+	callRequest        *serviceCall[*Request]
+	instancesSession   map[string]*Session
+	callsSession       map[string]*serviceCall[*Session]
+
+	// instanceOrder records instances in construction order, so they can be closed in reverse order
+	instanceOrder []any
+	closed        bool
+}
+
+// ErrServiceScopeClosed is returned when retrieving a service from a closed {ServiceScope}.
+var ErrServiceScopeClosed = errors.New("service scope is closed")
+
+// NewScope creates a new {ServiceScope}.
+func (r *ServiceRegistry) NewScope() *ServiceScope {
+	return &ServiceScope{registry: r, instancesSession: make(map[string]*Session), callsSession: make(map[string]*serviceCall[*Session])}
+}
+
+// GetAny retrieves an instance of {Any}.
+func (s *ServiceScope) GetAny() (any, error) {
+	return s.GetAnyContext(context.Background())
+}
+
+// GetAnyContext is like {ServiceScope.GetAny}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetAnyContext(ctx context.Context) (any, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetAny()
+}
+
+// GetBuffer creates a new instance of {Buffer}.
+func (s *ServiceScope) GetBuffer() (*bytes.Buffer, error) {
+	return s.GetBufferContext(context.Background())
+}
+
+// GetBufferContext is like {ServiceScope.GetBuffer}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetBufferContext(ctx context.Context) (*bytes.Buffer, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetBuffer()
+}
+
+// GetClock retrieves an instance of {Clock}.
+func (s *ServiceScope) GetClock() (func() time.Time, error) {
+	return s.GetClockContext(context.Background())
+}
+
+// GetClockContext is like {ServiceScope.GetClock}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetClockContext(ctx context.Context) (func() time.Time, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetClock()
+}
+
+// GetConfig retrieves an instance of {Config}.
+func (s *ServiceScope) GetConfig() (*Config, error) {
+	return s.GetConfigContext(context.Background())
+}
+
+// GetConfigContext is like {ServiceScope.GetConfig}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetConfigContext(ctx context.Context) (*Config, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetConfig()
+}
+
+// GetDB retrieves an instance of {DB}.
+func (s *ServiceScope) GetDB() (*sql.DB, error) {
+	return s.GetDBContext(context.Background())
+}
+
+// GetDBContext is like {ServiceScope.GetDB}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetDBContext(ctx context.Context) (*sql.DB, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetDB()
+}
+
+// GetHandlers retrieves an instance of {Handlers}.
+func (s *ServiceScope) GetHandlers() ([]Handler, error) {
+	return s.GetHandlersContext(context.Background())
+}
+
+// GetHandlersContext is like {ServiceScope.GetHandlers}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetHandlersContext(ctx context.Context) ([]Handler, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetHandlers()
+}
+
+// GetJob creates a new instance of {Job}.
+func (s *ServiceScope) GetJob(serviceName string) (*Job, error) {
+	return s.GetJobContext(context.Background(), serviceName)
+}
+
+// GetJobContext is like {ServiceScope.GetJob}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetJobContext(ctx context.Context, serviceName string) (*Job, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetJob(serviceName)
+}
+
+// JobNames returns the names factories of {Job} are registered with (sorted).
+func (s *ServiceScope) JobNames() []string {
+	return s.registry.JobNames()
+}
+
+// GetAllJob retrieves an instance of {Job} for every registered name.
+func (s *ServiceScope) GetAllJob() (map[string]*Job, error) {
+	return s.GetAllJobContext(context.Background())
+}
+
+// GetAllJobContext is like {ServiceScope.GetAllJob}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetAllJobContext(ctx context.Context) (map[string]*Job, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetAllJob()
+}
+
+// GetMailer creates a new instance of {Mailer}.
+func (s *ServiceScope) GetMailer() (Mailer, error) {
+	return s.GetMailerContext(context.Background())
+}
+
+// GetMailerContext is like {ServiceScope.GetMailer}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetMailerContext(ctx context.Context) (Mailer, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetMailer()
+}
+
+// GetRequest retrieves an instance of {Request}.
+func (s *ServiceScope) GetRequest() (*Request, error) {
+	return s.GetRequestContext(context.Background())
+}
+
+// GetRequestContext is like {ServiceScope.GetRequest}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetRequestContext(ctx context.Context) (*Request, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetRequest()
+}
+
+func (s *ServiceScope) getRequest(c *serviceLocationContext) (*Request, error) {
+	var zero *Request
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Request", "", c.path())
+	}
+
+	s.registry.mu.Lock()
+
+	if s.registry.closed {
+		s.registry.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	s.registry.recordDependency(c, "Request", "")
+
+	if s.closed {
+		s.registry.mu.Unlock()
+
+		return zero, ErrServiceScopeClosed
+	}
+
+	if s.constructedRequest {
+		instance := s.instanceRequest
+		s.registry.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Request", "", c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("Request", "") {
+		s.registry.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Request", "", c.path())
+		}
+
+		return zero, c.circularDependencyError("Request", "")
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := s.callRequest; call != nil {
+		if s.registry.wouldDeadlock(c.construction, call.owner) {
+			s.registry.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Request", "", c.path())
+			}
+
+			return zero, c.circularDependencyError("Request", "")
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		s.registry.waiting[wait] = struct{}{}
+		s.registry.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		s.registry.mu.Lock()
+		delete(s.registry.waiting, wait)
+		s.registry.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory := s.registry.factoryRequest
+	factoryOk := factory != nil
+	decorators := s.registry.decoratorsRequest
+
+	if !factoryOk {
+		s.registry.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceType: "Request",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		s.registry.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("Request", "")
+	if err != nil {
+		s.registry.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[*Request]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	s.callRequest = call
+	s.registry.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Request", "", child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Request", "", err)
+		}
+
+		s.registry.mu.Lock()
+		s.callRequest = nil
+		s.registry.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Request", "", child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Request", "", child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Request", "", call.err)
+	}
+
+	s.registry.mu.Lock()
+	var closedErr error
+	if s.registry.closed {
+		closedErr = ErrServiceRegistryClosed
+	} else if s.closed {
+		closedErr = ErrServiceScopeClosed
+	}
+	if call.err == nil && closedErr == nil {
+		s.instanceRequest = call.instance
+		s.constructedRequest = true
+
+		s.instanceOrder = append(s.instanceOrder, call.instance)
+	}
+	s.registry.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// GetServiceA retrieves an instance of {ServiceA}.
+func (s *ServiceScope) GetServiceA() (ServiceA, error) {
+	return s.GetServiceAContext(context.Background())
+}
+
+// GetServiceAContext is like {ServiceScope.GetServiceA}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetServiceAContext(ctx context.Context) (ServiceA, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetServiceA()
+}
+
+// GetServiceB retrieves an instance of {ServiceB}.
+func (s *ServiceScope) GetServiceB(serviceName string) (ServiceB, error) {
+	return s.GetServiceBContext(context.Background(), serviceName)
+}
+
+// GetServiceBContext is like {ServiceScope.GetServiceB}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetServiceBContext(ctx context.Context, serviceName string) (ServiceB, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetServiceB(serviceName)
+}
+
+// ServiceBNames returns the names factories of {ServiceB} are registered with (sorted).
+func (s *ServiceScope) ServiceBNames() []string {
+	return s.registry.ServiceBNames()
+}
+
+// GetAllServiceB retrieves an instance of {ServiceB} for every registered name.
+func (s *ServiceScope) GetAllServiceB() (map[string]ServiceB, error) {
+	return s.GetAllServiceBContext(context.Background())
+}
+
+// GetAllServiceBContext is like {ServiceScope.GetAllServiceB}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetAllServiceBContext(ctx context.Context) (map[string]ServiceB, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetAllServiceB()
+}
+
+// GetServiceC retrieves an instance of {ServiceC}.
+func (s *ServiceScope) GetServiceC() (subtest.ServiceC, error) {
+	return s.GetServiceCContext(context.Background())
+}
+
+// GetServiceCContext is like {ServiceScope.GetServiceC}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetServiceCContext(ctx context.Context) (subtest.ServiceC, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetServiceC()
+}
+
+// GetSession retrieves an instance of {Session}.
+func (s *ServiceScope) GetSession(serviceName string) (*Session, error) {
+	return s.GetSessionContext(context.Background(), serviceName)
+}
+
+// GetSessionContext is like {ServiceScope.GetSession}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetSessionContext(ctx context.Context, serviceName string) (*Session, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetSession(serviceName)
+}
+
+// SessionNames returns the names factories of {Session} are registered with (sorted).
+func (s *ServiceScope) SessionNames() []string {
+	return s.registry.SessionNames()
+}
+
+// GetAllSession retrieves an instance of {Session} for every registered name.
+func (s *ServiceScope) GetAllSession() (map[string]*Session, error) {
+	return s.GetAllSessionContext(context.Background())
+}
+
+// GetAllSessionContext is like {ServiceScope.GetAllSession}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetAllSessionContext(ctx context.Context) (map[string]*Session, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetAllSession()
+}
+
+func (s *ServiceScope) getSession(serviceName string, c *serviceLocationContext) (*Session, error) {
+	var zero *Session
+
+	if hooks := c.registry.hooks; hooks != nil {
+		hooks.ResolveStart(c.ctx, "Session", serviceName, c.path())
+	}
+
+	s.registry.mu.Lock()
+
+	if s.registry.closed {
+		s.registry.mu.Unlock()
+
+		return zero, ErrServiceRegistryClosed
+	}
+
+	s.registry.recordDependency(c, "Session", serviceName)
+
+	if s.closed {
+		s.registry.mu.Unlock()
+
+		return zero, ErrServiceScopeClosed
+	}
+
+	if instance, ok := s.instancesSession[serviceName]; ok {
+		s.registry.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CacheHit(c.ctx, "Session", serviceName, c.path())
+		}
+
+		return instance, nil
+	}
+
+	if c.isConstructing("Session", serviceName) {
+		s.registry.mu.Unlock()
+
+		if hooks := c.registry.hooks; hooks != nil {
+			hooks.CircularDependency(c.ctx, "Session", serviceName, c.path())
+		}
+
+		return zero, c.circularDependencyError("Session", serviceName)
+	}
+
+	// Wait for the instance if it is already being constructed
+	if call := s.callsSession[serviceName]; call != nil {
+		if s.registry.wouldDeadlock(c.construction, call.owner) {
+			s.registry.mu.Unlock()
+
+			if hooks := c.registry.hooks; hooks != nil {
+				hooks.CircularDependency(c.ctx, "Session", serviceName, c.path())
+			}
+
+			return zero, c.circularDependencyError("Session", serviceName)
+		}
+
+		wait := &serviceWait{
+			owner:  call.owner,
+			waiter: c.construction,
+		}
+		s.registry.waiting[wait] = struct{}{}
+		s.registry.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-c.ctx.Done():
+		}
+
+		s.registry.mu.Lock()
+		delete(s.registry.waiting, wait)
+		s.registry.mu.Unlock()
+
+		select {
+		case <-call.done:
+		default:
+			return zero, c.ctx.Err()
+		}
+
+		if call.err != nil {
+			return zero, call.err
+		}
+
+		return call.instance, nil
+	}
+
+	factory, factoryOk := s.registry.lookupSession(serviceName)
+	decorators := s.registry.decoratorsSession[serviceName]
+
+	if !factoryOk {
+		s.registry.mu.Unlock()
+
+		return zero, ServiceNotRegisteredError{
+			Path:        c.path(),
+			ServiceName: serviceName,
+			ServiceType: "Session",
+		}
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		s.registry.mu.Unlock()
+
+		return zero, err
+	}
+
+	child, err := c.enter("Session", serviceName)
+	if err != nil {
+		s.registry.mu.Unlock()
+
+		return zero, err
+	}
+
+	call := &serviceCall[*Session]{
+		done:  make(chan struct{}),
+		owner: child.construction,
+	}
+	s.callsSession[serviceName] = call
+	s.registry.mu.Unlock()
+
+	var start time.Time
+
+	// Resolutions waiting for the instance must not hang if the factory (or a decorator) panics
+	defer func() {
+		p := recover()
+		if p != nil {
+			err := fmt.Errorf("panic: %v", p)
+
+			if hooks := child.registry.hooks; hooks != nil {
+				hooks.FactoryEnd(child.ctx, "Session", serviceName, child.path(), time.Since(start), err)
+			}
+
+			call.err = child.constructionError("Session", serviceName, err)
+		}
+
+		s.registry.mu.Lock()
+		delete(s.callsSession, serviceName)
+		s.registry.mu.Unlock()
+
+		close(call.done)
+
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if hooks := child.registry.hooks; hooks != nil {
+		child.ctx = hooks.FactoryStart(child.ctx, "Session", serviceName, child.path())
+	}
+	start = time.Now()
+
+	call.instance, call.err = factory(child.ctx, serviceName, child)
+	if call.err == nil {
+		call.instance, call.err = decorate(child, call.instance, decorators)
+	}
+
+	duration := time.Since(start)
+
+	if hooks := child.registry.hooks; hooks != nil {
+		hooks.FactoryEnd(child.ctx, "Session", serviceName, child.path(), duration, call.err)
+	}
+
+	if call.err != nil {
+		call.err = child.constructionError("Session", serviceName, call.err)
+	}
+
+	s.registry.mu.Lock()
+	var closedErr error
+	if s.registry.closed {
+		closedErr = ErrServiceRegistryClosed
+	} else if s.closed {
+		closedErr = ErrServiceScopeClosed
+	}
+	if call.err == nil && closedErr == nil {
+		s.instancesSession[serviceName] = call.instance
+
+		s.instanceOrder = append(s.instanceOrder, call.instance)
+	}
+	s.registry.mu.Unlock()
+
+	// Instances constructed after closing would never be closed otherwise
+	if call.err == nil && closedErr != nil {
+		call.err = closedErr
+
+		if err := closeInstances(child.ctx, []any{call.instance}); err != nil {
+			call.err = errors.Join(closedErr, err)
+		}
+	}
+
+	if call.err != nil {
+		return zero, call.err
+	}
+
+	return call.instance, nil
+}
+
+// GetUserRepo retrieves an instance of {UserRepo}.
+func (s *ServiceScope) GetUserRepo() (Repo[User], error) {
+	return s.GetUserRepoContext(context.Background())
+}
+
+// GetUserRepoContext is like {ServiceScope.GetUserRepo}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetUserRepoContext(ctx context.Context) (Repo[User], error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetUserRepo()
+}
+
+// GetWorker creates a new instance of {Worker}.
+func (s *ServiceScope) GetWorker(serviceName string) (*Worker, error) {
+	return s.GetWorkerContext(context.Background(), serviceName)
+}
+
+// GetWorkerContext is like {ServiceScope.GetWorker}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetWorkerContext(ctx context.Context, serviceName string) (*Worker, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetWorker(serviceName)
+}
+
+// WorkerNames returns the names factories of {Worker} are registered with (sorted).
+func (s *ServiceScope) WorkerNames() []string {
+	return s.registry.WorkerNames()
+}
+
+// GetAllWorker retrieves an instance of {Worker} for every registered name.
+func (s *ServiceScope) GetAllWorker() (map[string]*Worker, error) {
+	return s.GetAllWorkerContext(context.Background())
+}
+
+// GetAllWorkerContext is like {ServiceScope.GetAllWorker}, but passes ctx to the factories called during the resolution.
+func (s *ServiceScope) GetAllWorkerContext(ctx context.Context) (map[string]*Worker, error) {
+	return newServiceLocationContext(ctx, s.registry, s, s.registry.maxDepth).GetAllWorker()
+}
+
+// Close closes instances of scoped services in reverse construction order.
+// Retrieving services from a closed scope returns {ErrServiceScopeClosed}.
+//
+// Instances implementing either {io.Closer} or Close(context.Context) error are closed.
+// Instances still under construction when calling Close are closed when their construction completes
+// (and the pending resolutions return {ErrServiceScopeClosed}).
+func (s *ServiceScope) Close(ctx context.Context) error {
+	s.registry.mu.Lock()
+	s.closed = true
+	instances := s.instanceOrder
+	s.instanceOrder = nil
+	s.registry.mu.Unlock()
+
+	return closeInstances(ctx, instances)
+}
+
+// serviceCall tracks the construction of an instance of T,
+// so that concurrent requests for the same instance wait for a single factory call.
+type serviceCall[T any] struct {
+	done  chan struct{}
+	owner *serviceConstruction
+
+	instance T
+	err      error
+}
+
+// serviceWait records that a construction (or the start of a resolution if waiter is nil)
+// is waiting for an instance constructed by another one.
+type serviceWait struct {
+	waiter *serviceConstruction
+	owner  *serviceConstruction
+}
+
+// closeInstances closes instances in reverse order and returns the combined errors.
+func closeInstances(ctx context.Context, instances []any) error {
+	var errs []error
+
+	for i := len(instances) - 1; i >= 0; i-- {
+		var err error
+
+		switch instance := instances[i].(type) {
+		case interface {
+			Close(context.Context) error
+		}:
+			err = instance.Close(ctx)
+		case io.Closer:
+			err = instance.Close()
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// serviceLocationContext is the {ServiceLocator} passed to factories.
+//
+// Services located through it are resolved with the context the resolution started with.
+// Factories receive a context of their own, so the path of the resolution is tracked
+// even if factories resolve services concurrently or retry failed resolutions.
+type serviceLocationContext struct {
+	ctx      context.Context
+	registry *ServiceRegistry
+	scope    *ServiceScope
+
+	// construction is the service constructed with this context (nil at the start of a resolution)
+	construction *serviceConstruction
+	maxDepth     int
+}
+
+// serviceConstruction is a service being constructed.
+type serviceConstruction struct {
+	serviceType string
+	serviceName string
+	service     string
+
+	// parent is the service depending on this one (if any)
+	parent *serviceConstruction
+	depth  int
+}
+
+// contains checks if other is c or a dependency constructed (directly or indirectly) for c.
+func (c *serviceConstruction) contains(other *serviceConstruction) bool {
+	for ; other != nil; other = other.parent {
+		if other == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newServiceLocationContext(ctx context.Context, registry *ServiceRegistry, scope *ServiceScope, maxDepth int) *serviceLocationContext {
+	return &serviceLocationContext{
+		ctx:      ctx,
+		maxDepth: maxDepth,
+		registry: registry,
+		scope:    scope,
+	}
+}
+
+// unscoped returns a context for resolving services outside of the current scope.
+//
+// Singletons are cached by the registry, so they must not depend on instances of the current scope.
+func (c *serviceLocationContext) unscoped() *serviceLocationContext {
+	if c.scope == nil {
+		return c
+	}
+
+	unscoped := *c
+	unscoped.scope = nil
+
+	return &unscoped
+}
+
+// enter returns the context for constructing a service,
+// unless that would exceed the maximum depth of the resolution.
+func (c *serviceLocationContext) enter(serviceType, serviceName string) (*serviceLocationContext, error) {
+	depth := 0
+	if c.construction != nil {
+		depth = c.construction.depth
+	}
+
+	if c.maxDepth > 0 && depth >= c.maxDepth {
+		return nil, MaxDepthExceededError{
+			DependencyGraph: c.path(),
+			MaxDepth:        c.maxDepth,
+			ServiceName:     serviceName,
+			ServiceType:     serviceType,
+		}
+	}
+
+	child := *c
+	child.construction = &serviceConstruction{
+		depth:       depth + 1,
+		parent:      c.construction,
+		service:     serviceID(serviceType, serviceName),
+		serviceName: serviceName,
+		serviceType: serviceType,
+	}
+
+	return &child, nil
+}
+
+// path returns the services being constructed (the last one being the innermost).
+func (c *serviceLocationContext) path() []string {
+	var path []string
+
+	for construction := c.construction; construction != nil; construction = construction.parent {
+		path = append(path, construction.service)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
+
+// isConstructing checks if a service is being constructed by the current resolution path.
+func (c *serviceLocationContext) isConstructing(serviceType, serviceName string) bool {
+	service := serviceID(serviceType, serviceName)
+
+	for construction := c.construction; construction != nil; construction = construction.parent {
+		if construction.service == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+// circularDependencyError reports that resolving a service would result in a circular dependency.
+//
+// If the service is being constructed by the current resolution path, the error only contains the cycle.
+func (c *serviceLocationContext) circularDependencyError(serviceType, serviceName string) CircularDependencyError {
+	service := serviceID(serviceType, serviceName)
+	path := c.path()
+
+	for i, s := range path {
+		if s == service {
+			path = path[i:]
+
+			break
+		}
+	}
+
+	return newCircularDependencyError(serviceType, serviceName, append(path, service))
+}
+
+// constructionError wraps an error returned by the factory of a service,
+// unless the error is already reported by the service locator (eg. by a dependency of the service).
+func (c *serviceLocationContext) constructionError(serviceType, serviceName string, err error) error {
+	var serviceLocatorErr serviceLocatorError
+	if errors.As(err, &serviceLocatorErr) {
+		return err
+	}
+
+	return ServiceConstructionError{
+		Err:         err,
+		Path:        c.path(),
+		ServiceName: serviceName,
+		ServiceType: serviceType,
+	}
+}
+
+func (c *serviceLocationContext) GetAny() (any, error) {
+	return c.registry.getAny(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetBuffer() (*bytes.Buffer, error) {
+	return c.registry.getBuffer(c)
+}
+
+func (c *serviceLocationContext) GetClock() (func() time.Time, error) {
+	return c.registry.getClock(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetConfig() (*Config, error) {
+	return c.registry.getConfig(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetDB() (*sql.DB, error) {
+	return c.registry.getDB(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetHandlers() ([]Handler, error) {
+	return c.registry.getHandlers(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetJob(serviceName string) (*Job, error) {
+	return c.registry.getJob(serviceName, c)
+}
+
+func (c *serviceLocationContext) JobNames() []string {
+	return c.registry.JobNames()
+}
+
+func (c *serviceLocationContext) GetAllJob() (map[string]*Job, error) {
+	instances := make(map[string]*Job)
+
+	for _, serviceName := range c.JobNames() {
+		instance, err := c.GetJob(serviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		instances[serviceName] = instance
+	}
+
+	return instances, nil
+}
+
+func (c *serviceLocationContext) GetMailer() (Mailer, error) {
+	return c.registry.getMailer(c)
+}
+
+func (c *serviceLocationContext) GetRequest() (*Request, error) {
+	if c.scope == nil {
+		var zero *Request
+
+		return zero, errors.New("Request is a scoped service: it can only be retrieved from a ServiceScope")
+	}
+
+	return c.scope.getRequest(c)
+}
+
+func (c *serviceLocationContext) GetServiceA() (ServiceA, error) {
+	return c.registry.getServiceA(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetServiceB(serviceName string) (ServiceB, error) {
+	return c.registry.getServiceB(serviceName, c.unscoped())
+}
+
+func (c *serviceLocationContext) ServiceBNames() []string {
+	return c.registry.ServiceBNames()
+}
+
+func (c *serviceLocationContext) GetAllServiceB() (map[string]ServiceB, error) {
+	instances := make(map[string]ServiceB)
+
+	for _, serviceName := range c.ServiceBNames() {
+		instance, err := c.GetServiceB(serviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		instances[serviceName] = instance
+	}
+
+	return instances, nil
+}
+
+func (c *serviceLocationContext) GetServiceC() (subtest.ServiceC, error) {
+	return c.registry.getServiceC(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetSession(serviceName string) (*Session, error) {
+	if c.scope == nil {
+		var zero *Session
+
+		return zero, errors.New("Session is a scoped service: it can only be retrieved from a ServiceScope")
+	}
+
+	return c.scope.getSession(serviceName, c)
+}
+
+func (c *serviceLocationContext) SessionNames() []string {
+	return c.registry.SessionNames()
+}
+
+func (c *serviceLocationContext) GetAllSession() (map[string]*Session, error) {
+	instances := make(map[string]*Session)
+
+	for _, serviceName := range c.SessionNames() {
+		instance, err := c.GetSession(serviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		instances[serviceName] = instance
+	}
+
+	return instances, nil
+}
+
+func (c *serviceLocationContext) GetUserRepo() (Repo[User], error) {
+	return c.registry.getUserRepo(c.unscoped())
+}
+
+func (c *serviceLocationContext) GetWorker(serviceName string) (*Worker, error) {
+	return c.registry.getWorker(serviceName, c)
+}
+
+func (c *serviceLocationContext) WorkerNames() []string {
+	return c.registry.WorkerNames()
+}
+
+func (c *serviceLocationContext) GetAllWorker() (map[string]*Worker, error) {
+	instances := make(map[string]*Worker)
+
+	for _, serviceName := range c.WorkerNames() {
+		instance, err := c.GetWorker(serviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		instances[serviceName] = instance
+	}
+
+	return instances, nil
+}
+
+// ResolutionHooks observe how a {ServiceRegistry} resolves services.
+//
+// Hooks receive the type and the name (empty for unnamed services) of the service
+// and the path of services being constructed when the hook is called (the last one being the innermost).
+// Hooks are called synchronously and concurrently: they must be safe for concurrent use and return quickly.
+type ResolutionHooks interface {
+	// ResolveStart is called when a service is requested.
+	ResolveStart(ctx context.Context, serviceType, serviceName string, path []string)
+
+	// CacheHit is called when a service is resolved from a cached instance.
+	CacheHit(ctx context.Context, serviceType, serviceName string, path []string)
+
+	// FactoryStart is called before constructing a service (the service being the last element of the path).
+	// The returned context (ctx or a context derived from it, eg. carrying a tracing span)
+	// is passed to the factory, to the services it resolves and to FactoryEnd.
+	FactoryStart(ctx context.Context, serviceType, serviceName string, path []string) context.Context
+
+	// FactoryEnd is called after constructing a service (including decorators) with the error returned by the factory.
+	FactoryEnd(ctx context.Context, serviceType, serviceName string, path []string, duration time.Duration, err error)
+
+	// CircularDependency is called when resolving a service would result in a circular dependency.
+	CircularDependency(ctx context.Context, serviceType, serviceName string, path []string)
+}
+
+// serviceID identifies a service in dependency graphs.
+func serviceID(serviceType, serviceName string) string {
+	if serviceName == "" {
+		return serviceType
+	}
+
+	return serviceType + ":" + serviceName
+}
+
+// DependencyGraph describes which services depend on which other services.
+//
+// Services are identified by their type (and name, separated by a colon, for named services).
+// Names resolved by pattern or default factories are recorded as "*" (eg. "Job:*").
+type DependencyGraph struct {
+	Services     []string            `json:"services"`
+	Dependencies []ServiceDependency `json:"dependencies"`
+}
+
+// ServiceDependency records that the factory of a service resolved another service.
+type ServiceDependency struct {
+	Service    string `json:"service"`
+	Dependency string `json:"dependency"`
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+func (g DependencyGraph) DOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph services {\n")
+
+	for _, service := range g.Services {
+		fmt.Fprintf(&b, "\t%q;\n", service)
+	}
+
+	for _, dependency := range g.Dependencies {
+		fmt.Fprintf(&b, "\t%q -> %q;\n", dependency.Service, dependency.Dependency)
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart.
+func (g DependencyGraph) Mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("flowchart TD\n")
+
+	// Service identifiers may contain characters Mermaid does not accept in node IDs
+	ids := make(map[string]string, len(g.Services))
+
+	for i, service := range g.Services {
+		ids[service] = fmt.Sprintf("s%d", i)
+
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", ids[service], strings.ReplaceAll(service, "\"", "#quot;"))
+	}
+
+	for _, dependency := range g.Dependencies {
+		fmt.Fprintf(&b, "\t%s --> %s\n", ids[dependency.Service], ids[dependency.Dependency])
+	}
+
+	return b.String()
+}
+
+// JSON renders the graph as JSON.
+func (g DependencyGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DebugHandler returns an HTTP handler serving the state of the registry.
+//
+// The handler serves the following files relative to the path it is mounted on:
+//
+//   - services.json: services (JSON)
+//   - graph.dot: dependency graph (Graphviz DOT)
+//   - graph.mermaid: dependency graph (Mermaid)
+//   - graph.json: dependency graph (JSON)
+//
+// Any path ending with a slash serves a plain text overview of the services (listing the files above),
+// so the handler is expected to be mounted on a path ending with a slash:
+//
+//	mux.Handle("/debug/services/", registry.DebugHandler())
+func (r *ServiceRegistry) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if req.URL.Path == "" || strings.HasSuffix(req.URL.Path, "/") {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			r.writeDebugIndex(w)
+
+			return
+		}
+
+		switch path.Base(req.URL.Path) {
+		case "services.json":
+			data, err := json.MarshalIndent(r.Services(), "", "  ")
+			writeDebugJSON(w, data, err)
+		case "graph.dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			io.WriteString(w, r.DependencyGraph().DOT())
+		case "graph.mermaid":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, r.DependencyGraph().Mermaid())
+		case "graph.json":
+			data, err := r.DependencyGraph().JSON()
+			writeDebugJSON(w, data, err)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+}
+
+// writeDebugIndex writes a plain text overview of the services of the registry to w.
+func (r *ServiceRegistry) writeDebugIndex(w io.Writer) {
+	fmt.Fprint(w, "Endpoints:\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "services.json\tservices (JSON)")
+	fmt.Fprintln(tw, "graph.dot\tdependency graph (Graphviz DOT)")
+	fmt.Fprintln(tw, "graph.mermaid\tdependency graph (Mermaid)")
+	fmt.Fprintln(tw, "graph.json\tdependency graph (JSON)")
+	tw.Flush()
+
+	fmt.Fprint(w, "\nServices:\n\n")
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tSCOPE\tREGISTERED\tNAMES\tINSTANCES\tDEPENDENCIES")
+
+	for _, service := range r.Services() {
+		instances := make([]string, 0, len(service.Instances))
+
+		for _, instance := range service.Instances {
+			description := fmt.Sprintf("%s (%s)", instance.ConstructedAt.Format(time.RFC3339), instance.Duration)
+			if instance.Name != "" {
+				description = instance.Name + ": " + description
+			}
+
+			instances = append(instances, description)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\t%s\n", service.Name, service.Type, service.Scope, service.Registered, debugList(service.Names), debugList(instances), debugList(service.Dependencies))
+	}
+
+	tw.Flush()
+}
+
+// writeDebugJSON writes data (or err) to w.
+func writeDebugJSON(w http.ResponseWriter, data []byte, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// debugList joins values (or returns a dash if there are none).
+func debugList(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// CircularDependencyError is returned when there is a circular dependency between two services.
+type CircularDependencyError struct {
+	ServiceType     string
+	ServiceName     string
+	DependencyGraph []string
+}
+
+func newCircularDependencyError(serviceType string, serviceName string, dependencyGraph []string) CircularDependencyError {
+	return CircularDependencyError{
+		DependencyGraph: dependencyGraph,
+		ServiceName:     serviceName,
+		ServiceType:     serviceType,
+	}
+}
+func (e CircularDependencyError) Error() string {
+	dependencyPath := strings.Join(e.DependencyGraph, " -> ")
+
+	if e.ServiceName != "" {
+		return fmt.Sprintf("circular dependency detected for %s '%s': %s", e.ServiceType, e.ServiceName, dependencyPath)
+	}
+
+	return fmt.Sprintf("circular dependency detected for %s: %s", e.ServiceType, dependencyPath)
+}
+func (CircularDependencyError) serviceLocatorError() {}
+
+// MaxDepthExceededError is returned when resolving a service exceeds the maximum depth of dependencies.
+type MaxDepthExceededError struct {
+	ServiceType     string
+	ServiceName     string
+	MaxDepth        int
+	DependencyGraph []string
+}
+
+func (e MaxDepthExceededError) Error() string {
+	dependencyPath := strings.Join(e.DependencyGraph, " -> ")
+
+	if e.ServiceName != "" {
+		return fmt.Sprintf("maximum depth (%d) exceeded for %s '%s': %s", e.MaxDepth, e.ServiceType, e.ServiceName, dependencyPath)
+	}
+
+	return fmt.Sprintf("maximum depth (%d) exceeded for %s: %s", e.MaxDepth, e.ServiceType, dependencyPath)
+}
+func (MaxDepthExceededError) serviceLocatorError() {}
+
+// ServiceNotRegisteredError is returned when there is no factory registered for a service.
+//
+// Path lists the services being constructed when the service was requested (empty if it was requested directly).
+type ServiceNotRegisteredError struct {
+	ServiceType string
+	ServiceName string
+	Path        []string
+}
+
+func (e ServiceNotRegisteredError) Error() string {
+	msg := "no factory registered for " + e.ServiceType
+	if e.ServiceName != "" {
+		msg += fmt.Sprintf(" with name '%s'", e.ServiceName)
+	}
+
+	if len(e.Path) > 0 {
+		msg += " (required by " + strings.Join(e.Path, " -> ") + ")"
+	}
+
+	return msg
+}
+func (ServiceNotRegisteredError) serviceLocatorError() {}
+
+// ServiceAlreadyRegisteredError is returned when registering a factory for a service that already has one
+// (depending on the {RegistrationPolicy}).
+type ServiceAlreadyRegisteredError struct {
+	ServiceType string
+	ServiceName string
+}
+
+func (e ServiceAlreadyRegisteredError) Error() string {
+	if e.ServiceName != "" {
+		return fmt.Sprintf("factory already registered for %s with name '%s'", e.ServiceType, e.ServiceName)
+	}
+
+	return "factory already registered for " + e.ServiceType
+}
+func (ServiceAlreadyRegisteredError) serviceLocatorError() {}
+
+// ServiceConstructionError is returned when the factory of a service fails.
+//
+// Path lists the services visited by the resolution until the failure.
+type ServiceConstructionError struct {
+	ServiceType string
+	ServiceName string
+	Path        []string
+	Err         error
+}
+
+func (e ServiceConstructionError) Error() string {
+	dependencyPath := strings.Join(e.Path, " -> ")
+
+	if e.ServiceName != "" {
+		return fmt.Sprintf("failed to construct %s '%s' (%s): %v", e.ServiceType, e.ServiceName, dependencyPath, e.Err)
+	}
+
+	return fmt.Sprintf("failed to construct %s (%s): %v", e.ServiceType, dependencyPath, e.Err)
+}
+func (e ServiceConstructionError) Unwrap() error {
+	return e.Err
+}
+func (ServiceConstructionError) serviceLocatorError() {}
+
+// serviceLocatorError is implemented by errors reported by the service locator.
+type serviceLocatorError interface {
+	serviceLocatorError()
 }
@@ -1,11 +1,27 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/sagikazarmark/go-service-locator/hooks"
+	"github.com/sagikazarmark/go-service-locator/test/subtest"
 )
 
 type serviceA struct {
@@ -91,6 +107,2212 @@ func TestCircularDependencyDetection(t *testing.T) {
 	assert.Equal(t, CircularDependencyError{
 		ServiceType:     "ServiceA",
 		ServiceName:     "",
-		DependencyGraph: []string{"ServiceA", "ServiceB:service"},
+		DependencyGraph: []string{"ServiceA", "ServiceB:service", "ServiceA"},
+	}, err)
+}
+
+func TestNonInterfaceServices(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var calls int
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		calls++
+
+		return &Config{Name: "test"}, nil
+	})
+
+	registry.RegisterHandlers(func(_ ServiceLocator) ([]Handler, error) {
+		calls++
+
+		return nil, nil
+	})
+
+	registry.RegisterUserRepo(func(serviceLocator ServiceLocator) (Repo[User], error) {
+		calls++
+
+		config, err := serviceLocator.GetConfig()
+		if err != nil {
+			return Repo[User]{}, err
+		}
+
+		return Repo[User]{Items: []User{{ID: config.Name}}}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		config, err := registry.GetConfig()
+		require.NoError(t, err)
+		assert.Equal(t, &Config{Name: "test"}, config)
+
+		// A nil slice still counts as a constructed instance
+		handlers, err := registry.GetHandlers()
+		require.NoError(t, err)
+		assert.Nil(t, handlers)
+
+		repo, err := registry.GetUserRepo()
+		require.NoError(t, err)
+		assert.Equal(t, Repo[User]{Items: []User{{ID: "test"}}}, repo)
+	}
+
+	assert.Equal(t, 3, calls)
+
+	// Services declared with alias types (including any)
+	registry.RegisterAny(func(_ ServiceLocator) (any, error) {
+		return "any", nil
+	})
+
+	registry.RegisterDBInstance(&sql.DB{})
+
+	anyService, err := registry.GetAny()
+	require.NoError(t, err)
+	assert.Equal(t, "any", anyService)
+
+	db, err := registry.GetDB()
+	require.NoError(t, err)
+	assert.NotNil(t, db)
+
+	_, err = registry.GetClock()
+
+	assert.ErrorContains(t, err, "no factory registered for Clock")
+}
+
+func TestConcurrentConstruction(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var callsA, callsB atomic.Int32
+
+	release := make(chan struct{})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		callsA.Add(1)
+
+		<-release
+
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return &serviceA{serviceB: serviceB}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		callsB.Add(1)
+
+		return &serviceB{}, nil
+	})
+
+	const n = 10
+
+	var wg sync.WaitGroup
+
+	servicesA := make([]ServiceA, n)
+	servicesB := make([]ServiceB, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			service, err := registry.GetServiceA()
+			assert.NoError(t, err)
+
+			servicesA[i] = service
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+
+			service, err := registry.GetServiceB("service")
+			assert.NoError(t, err)
+
+			servicesB[i] = service
+		}(i)
+	}
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), callsA.Load())
+	assert.Equal(t, int32(1), callsB.Load())
+
+	for i := 1; i < n; i++ {
+		assert.Same(t, servicesA[0], servicesA[i])
+		assert.Same(t, servicesB[0], servicesB[i])
+	}
+}
+
+func TestConcurrentCircularDependencyDetection(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	startedA := make(chan struct{})
+	startedB := make(chan struct{})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		close(startedA)
+		<-startedB
+
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		close(startedB)
+		<-startedA
+
+		_, err := serviceLocator.GetServiceA()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceB{}, nil
+	})
+
+	errs := make(chan error, 2)
+
+	go func() {
+		_, err := registry.GetServiceA()
+		errs <- err
+	}()
+
+	go func() {
+		_, err := registry.GetServiceB("service")
+		errs <- err
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			assert.ErrorAs(t, err, &CircularDependencyError{})
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlock: service resolution did not return")
+		}
+	}
+}
+
+func TestTransientServices(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		return new(bytes.Buffer), nil
+	})
+
+	registry.RegisterJob("job", func(name string, serviceLocator ServiceLocator) (*Job, error) {
+		// Requesting the same transient service multiple times is not a circular dependency
+		for i := 0; i < 2; i++ {
+			_, err := serviceLocator.GetBuffer()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &Job{Name: name}, nil
+	})
+
+	buffer1, err := registry.GetBuffer()
+	require.NoError(t, err)
+
+	buffer2, err := registry.GetBuffer()
+	require.NoError(t, err)
+
+	assert.NotSame(t, buffer1, buffer2)
+
+	job1, err := registry.GetJob("job")
+	require.NoError(t, err)
+
+	job2, err := registry.GetJob("job")
+	require.NoError(t, err)
+
+	assert.Equal(t, &Job{Name: "job"}, job1)
+	assert.NotSame(t, job1, job2)
+}
+
+func TestTransientCircularDependencyDetection(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterJob("job", func(name string, serviceLocator ServiceLocator) (*Job, error) {
+		_, err := serviceLocator.GetJob(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Job{Name: name}, nil
+	})
+
+	_, err := registry.GetJob("job")
+
+	assert.Equal(t, CircularDependencyError{
+		ServiceType:     "Job",
+		ServiceName:     "job",
+		DependencyGraph: []string{"Job:job", "Job:job"},
 	}, err)
 }
+
+func TestScopedServices(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{Name: "test"}, nil
+	})
+
+	registry.RegisterRequest(func(serviceLocator ServiceLocator) (*Request, error) {
+		config, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Request{Config: config}, nil
+	})
+
+	registry.RegisterSession("session", func(name string, serviceLocator ServiceLocator) (*Session, error) {
+		request, err := serviceLocator.GetRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Session{Name: name, Request: request}, nil
+	})
+
+	_, err := registry.GetRequest()
+	assert.ErrorContains(t, err, "Request is a scoped service")
+
+	scope1 := registry.NewScope()
+	scope2 := registry.NewScope()
+
+	session1, err := scope1.GetSession("session")
+	require.NoError(t, err)
+
+	request1, err := scope1.GetRequest()
+	require.NoError(t, err)
+
+	request2, err := scope2.GetRequest()
+	require.NoError(t, err)
+
+	assert.Same(t, request1, session1.Request)
+	assert.NotSame(t, request1, request2)
+
+	// Singletons are shared by scopes
+	config, err := registry.GetConfig()
+	require.NoError(t, err)
+
+	assert.Same(t, config, request1.Config)
+	assert.Same(t, config, request2.Config)
+
+	require.NoError(t, scope1.Close(context.Background()))
+
+	assert.True(t, session1.Closed)
+	assert.True(t, request1.Closed)
+	assert.False(t, request2.Closed)
+}
+
+func TestSingletonCannotDependOnScopedService(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterRequest(func(_ ServiceLocator) (*Request, error) {
+		return &Request{}, nil
+	})
+
+	_, err := registry.NewScope().GetServiceA()
+
+	assert.ErrorContains(t, err, "Request is a scoped service")
+}
+
+type closableServiceA struct {
+	closed *[]string
+}
+
+func (s closableServiceA) Foo() {}
+
+func (s closableServiceA) Close(_ context.Context) error {
+	*s.closed = append(*s.closed, "ServiceA")
+
+	return errors.New("ServiceA failed to close")
+}
+
+type closableServiceB struct {
+	name   string
+	closed *[]string
+}
+
+func (s closableServiceB) Bar() {}
+
+func (s closableServiceB) Close() error {
+	*s.closed = append(*s.closed, "ServiceB:"+s.name)
+
+	return errors.New("ServiceB failed to close")
+}
+
+func TestClose(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var closed []string
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return closableServiceA{closed: &closed}, nil
+	})
+
+	registry.RegisterServiceB("service", func(name string, _ ServiceLocator) (ServiceB, error) {
+		return closableServiceB{name: name, closed: &closed}, nil
+	})
+
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		return new(bytes.Buffer), nil
+	})
+
+	registry.RegisterRequest(func(_ ServiceLocator) (*Request, error) {
+		return &Request{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	scope := registry.NewScope()
+
+	request, err := scope.GetRequest()
+	require.NoError(t, err)
+
+	err = registry.Close(context.Background())
+
+	assert.EqualError(t, err, "ServiceA failed to close\nServiceB failed to close")
+	assert.Equal(t, []string{"ServiceA", "ServiceB:service"}, closed)
+
+	// Scoped instances are closed by their scope
+	assert.False(t, request.Closed)
+
+	_, err = registry.GetServiceA()
+	assert.ErrorIs(t, err, ErrServiceRegistryClosed)
+
+	_, err = registry.GetServiceB("service")
+	assert.ErrorIs(t, err, ErrServiceRegistryClosed)
+
+	_, err = registry.GetBuffer()
+	assert.ErrorIs(t, err, ErrServiceRegistryClosed)
+
+	_, err = scope.GetRequest()
+	assert.ErrorIs(t, err, ErrServiceRegistryClosed)
+
+	// Instances are closed only once
+	require.NoError(t, registry.Close(context.Background()))
+	assert.Len(t, closed, 2)
+}
+
+func TestCloseDuringConstruction(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var closed []string
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	registry.RegisterServiceB("service", func(name string, _ ServiceLocator) (ServiceB, error) {
+		close(started)
+		<-release
+
+		return closableServiceB{name: name, closed: &closed}, nil
+	})
+
+	done := make(chan error)
+
+	go func() {
+		_, err := registry.GetServiceB("service")
+		done <- err
+	}()
+
+	<-started
+
+	require.NoError(t, registry.Close(context.Background()))
+
+	close(release)
+
+	// The instance is closed instead of being cached
+	err := <-done
+	assert.ErrorIs(t, err, ErrServiceRegistryClosed)
+	assert.ErrorContains(t, err, "ServiceB failed to close")
+	assert.Equal(t, []string{"ServiceB:service"}, closed)
+}
+
+func TestScopeCloseDuringConstruction(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var request *Request
+
+	registry.RegisterRequest(func(_ ServiceLocator) (*Request, error) {
+		close(started)
+		<-release
+
+		request = &Request{}
+
+		return request, nil
+	})
+
+	scope := registry.NewScope()
+
+	done := make(chan error)
+
+	go func() {
+		_, err := scope.GetRequest()
+		done <- err
+	}()
+
+	<-started
+
+	require.NoError(t, scope.Close(context.Background()))
+
+	close(release)
+
+	assert.ErrorIs(t, <-done, ErrServiceScopeClosed)
+	assert.True(t, request.Closed)
+}
+
+func TestScopeClose(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterRequest(func(_ ServiceLocator) (*Request, error) {
+		return &Request{}, nil
+	})
+
+	scope := registry.NewScope()
+
+	require.NoError(t, scope.Close(context.Background()))
+
+	_, err := scope.GetRequest()
+	assert.ErrorIs(t, err, ErrServiceScopeClosed)
+
+	_, err = registry.NewScope().GetRequest()
+	assert.NoError(t, err)
+}
+
+type contextKey struct{}
+
+func TestContextAwareFactories(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var values []any
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: serviceB}, nil
+	})
+
+	registry.RegisterServiceBContext("service", func(ctx context.Context, _ string, _ ServiceLocator) (ServiceB, error) {
+		values = append(values, ctx.Value(contextKey{}))
+
+		return serviceB{}, nil
+	})
+
+	registry.RegisterRequestContext(func(ctx context.Context, _ ServiceLocator) (*Request, error) {
+		values = append(values, ctx.Value(contextKey{}))
+
+		return &Request{}, nil
+	})
+
+	// The context flows through nested lookups
+	_, err := registry.GetServiceAContext(context.WithValue(context.Background(), contextKey{}, "registry"))
+	require.NoError(t, err)
+
+	_, err = registry.NewScope().GetRequestContext(context.WithValue(context.Background(), contextKey{}, "scope"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []any{"registry", "scope"}, values)
+}
+
+func TestContextCancellation(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(_ ServiceLocator) (ServiceA, error) {
+		return serviceA{}, nil
+	})
+
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		return new(bytes.Buffer), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := registry.GetServiceAContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = registry.GetBufferContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Failed resolutions are not cached
+	_, err = registry.GetServiceA()
+	assert.NoError(t, err)
+}
+
+func TestContextCancellationWhileWaiting(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	registry.RegisterServiceA(func(_ ServiceLocator) (ServiceA, error) {
+		close(started)
+		<-release
+
+		return serviceA{}, nil
+	})
+
+	go func() {
+		_, _ = registry.GetServiceA()
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := registry.GetServiceAContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+}
+
+func TestMaxDepth(t *testing.T) {
+	newRegistry := func(opts ...ServiceRegistryOption) *ServiceRegistry {
+		registry := NewServiceRegistry(opts...)
+
+		// Each job depends on the next one, building their names dynamically
+		for i := 1; i <= 5; i++ {
+			registry.RegisterJob(strconv.Itoa(i), func(name string, serviceLocator ServiceLocator) (*Job, error) {
+				if name == "5" {
+					return &Job{Name: name}, nil
+				}
+
+				next, _ := strconv.Atoi(name)
+
+				_, err := serviceLocator.GetJob(strconv.Itoa(next + 1))
+				if err != nil {
+					return nil, err
+				}
+
+				return &Job{Name: name}, nil
+			})
+		}
+
+		return registry
+	}
+
+	_, err := newRegistry().GetJob("1")
+	require.NoError(t, err)
+
+	_, err = newRegistry(WithMaxDepth(5)).GetJob("1")
+	require.NoError(t, err)
+
+	_, err = newRegistry(WithMaxDepth(3)).GetJob("1")
+
+	assert.Equal(t, MaxDepthExceededError{
+		ServiceType:     "Job",
+		ServiceName:     "4",
+		MaxDepth:        3,
+		DependencyGraph: []string{"Job:1", "Job:2", "Job:3"},
+	}, err)
+	assert.EqualError(t, err, "maximum depth (3) exceeded for Job '4': Job:1 -> Job:2 -> Job:3")
+
+	registry := NewServiceRegistry(WithMaxDepth(1))
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{}, nil
+	})
+	registry.RegisterBuffer(func(serviceLocator ServiceLocator) (*bytes.Buffer, error) {
+		_, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return new(bytes.Buffer), nil
+	})
+
+	_, err = registry.GetBuffer()
+	assert.EqualError(t, err, "maximum depth (1) exceeded for Config: Buffer")
+}
+
+func TestServiceNotRegisteredError(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+
+	var notRegisteredErr ServiceNotRegisteredError
+	require.ErrorAs(t, err, &notRegisteredErr)
+
+	assert.Equal(t, ServiceNotRegisteredError{ServiceType: "ServiceB", ServiceName: "service", Path: []string{"ServiceA"}}, notRegisteredErr)
+	assert.EqualError(t, err, "no factory registered for ServiceB with name 'service' (required by ServiceA)")
+}
+
+func TestServiceConstructionError(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	errFactory := errors.New("failed to create service")
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return nil, errFactory
+	})
+
+	_, err := registry.GetServiceA()
+
+	var constructionErr ServiceConstructionError
+	require.ErrorAs(t, err, &constructionErr)
+
+	// The error is reported by the service that failed
+	assert.Equal(t, ServiceConstructionError{
+		ServiceType: "ServiceB",
+		ServiceName: "service",
+		Path:        []string{"ServiceA", "ServiceB:service"},
+		Err:         errFactory,
+	}, constructionErr)
+	assert.ErrorIs(t, err, errFactory)
+	assert.EqualError(t, err, "failed to construct ServiceB 'service' (ServiceA -> ServiceB:service): failed to create service")
+}
+
+func TestDependencyGraph(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: serviceB}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.RegisterBuffer(func(serviceLocator ServiceLocator) (*bytes.Buffer, error) {
+		// Resolved from the cache
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return new(bytes.Buffer), nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	_, err = registry.GetBuffer()
+	require.NoError(t, err)
+
+	graph := registry.DependencyGraph()
+
+	assert.Equal(t, DependencyGraph{
+		Services: []string{"Buffer", "ServiceA", "ServiceB:service"},
+		Dependencies: []ServiceDependency{
+			{Service: "Buffer", Dependency: "ServiceB:service"},
+			{Service: "ServiceA", Dependency: "ServiceB:service"},
+		},
+	}, graph)
+
+	assert.Equal(t, `digraph services {
+	"Buffer";
+	"ServiceA";
+	"ServiceB:service";
+	"Buffer" -> "ServiceB:service";
+	"ServiceA" -> "ServiceB:service";
+}
+`, graph.DOT())
+
+	assert.Equal(t, `flowchart TD
+	s0["Buffer"]
+	s1["ServiceA"]
+	s2["ServiceB:service"]
+	s0 --> s2
+	s1 --> s2
+`, graph.Mermaid())
+
+	data, err := graph.JSON()
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"services": ["Buffer", "ServiceA", "ServiceB:service"],
+		"dependencies": [
+			{"service": "Buffer", "dependency": "ServiceB:service"},
+			{"service": "ServiceA", "dependency": "ServiceB:service"}
+		]
+	}`, string(data))
+}
+
+func TestDependencyGraphFallbackFactories(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	require.NoError(t, registry.RegisterJobPattern("job-*", func(name string, serviceLocator ServiceLocator) (*Job, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return &Job{Name: name}, nil
+	}))
+
+	require.NoError(t, registry.RegisterJob("nightly", func(name string, _ ServiceLocator) (*Job, error) {
+		return &Job{Name: name}, nil
+	}))
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		for i := 0; i < 10; i++ {
+			_, err := serviceLocator.GetJob(fmt.Sprintf("job-%d", i))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := serviceLocator.GetJob("nightly")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	// Names resolved by pattern factories do not grow the graph
+	assert.Equal(t, DependencyGraph{
+		Services: []string{"Job:*", "Job:nightly", "ServiceA", "ServiceB:service"},
+		Dependencies: []ServiceDependency{
+			{Service: "Job:*", Dependency: "ServiceB:service"},
+			{Service: "ServiceA", Dependency: "Job:*"},
+			{Service: "ServiceA", Dependency: "Job:nightly"},
+		},
+	}, registry.DependencyGraph())
+}
+
+func TestServices(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: serviceB}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceB("other", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.RegisterConfigInstance(&Config{})
+
+	before := time.Now()
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	services := make(map[string]ServiceInfo)
+	var names []string
+
+	for _, service := range registry.Services() {
+		services[service.Name] = service
+		names = append(names, service.Name)
+	}
+
+	assert.Equal(t, []string{"Any", "Buffer", "Clock", "Config", "DB", "Handlers", "Job", "Mailer", "Request", "ServiceA", "ServiceB", "ServiceC", "Session", "UserRepo", "Worker"}, names)
+
+	serviceAInfo := services["ServiceA"]
+	assert.Equal(t, "test.ServiceA", serviceAInfo.Type)
+	assert.Equal(t, "github.com/sagikazarmark/go-service-locator/test", serviceAInfo.ImportPath)
+	assert.Equal(t, "singleton", serviceAInfo.Scope)
+	assert.False(t, serviceAInfo.Named)
+	assert.True(t, serviceAInfo.Registered)
+	assert.True(t, serviceAInfo.Constructed)
+	require.Len(t, serviceAInfo.Instances, 1)
+	assert.False(t, serviceAInfo.Instances[0].ConstructedAt.Before(before))
+	assert.Equal(t, []string{"ServiceB:service"}, serviceAInfo.Dependencies)
+
+	serviceBInfo := services["ServiceB"]
+	assert.True(t, serviceBInfo.Named)
+	assert.Equal(t, []string{"other", "service"}, serviceBInfo.Names)
+	assert.True(t, serviceBInfo.Registered)
+	assert.True(t, serviceBInfo.Constructed)
+	require.Len(t, serviceBInfo.Instances, 1)
+	assert.Equal(t, "service", serviceBInfo.Instances[0].Name)
+	assert.Empty(t, serviceBInfo.Dependencies)
+
+	configInfo := services["Config"]
+	assert.True(t, configInfo.Registered)
+	assert.True(t, configInfo.Constructed)
+	require.Len(t, configInfo.Instances, 1)
+	assert.Zero(t, configInfo.Instances[0].Duration)
+
+	assert.Equal(t, "*bytes.Buffer", services["Buffer"].Type)
+	assert.Equal(t, "transient", services["Buffer"].Scope)
+	assert.Equal(t, "scoped", services["Request"].Scope)
+	assert.Equal(t, "subtest.ServiceC", services["ServiceC"].Type)
+	assert.Equal(t, "test.Repo[test.User]", services["UserRepo"].Type)
+	assert.Equal(t, "*sql.DB", services["DB"].Type)
+	assert.Equal(t, "database/sql", services["DB"].ImportPath)
+	assert.Equal(t, "any", services["Any"].Type)
+	assert.Empty(t, services["Clock"].ImportPath)
+	assert.False(t, services["Clock"].Registered)
+	assert.False(t, services["Clock"].Constructed)
+
+	// Registering a factory again discards the instance
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	for _, service := range registry.Services() {
+		if service.Name == "ServiceB" {
+			assert.False(t, service.Constructed)
+			assert.Empty(t, service.Instances)
+		}
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: serviceB}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/services/", registry.DebugHandler())
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(t *testing.T, path string) (*http.Response, string) {
+		t.Helper()
+
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		require.NoError(t, err)
+
+		return resp, body.String()
+	}
+
+	t.Run("Index", func(t *testing.T) {
+		resp, body := get(t, "/debug/services/")
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Contains(t, body, "services.json")
+		assert.Regexp(t, `(?m)^ServiceA +test\.ServiceA +singleton +true +- +\S+ \(.+\) +ServiceB:service$`, body)
+		assert.Regexp(t, `(?m)^ServiceB +test\.ServiceB +singleton +true +service +service: \S+ \(.+\) +-$`, body)
+		assert.Regexp(t, `(?m)^Clock +func\(\) time\.Time +singleton +false +- +- +-$`, body)
+	})
+
+	t.Run("Services", func(t *testing.T) {
+		resp, body := get(t, "/debug/services/services.json")
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var services []ServiceInfo
+		require.NoError(t, json.Unmarshal([]byte(body), &services))
+
+		expected := registry.Services()
+		require.Len(t, services, len(expected))
+
+		for i := range expected {
+			assert.Equal(t, expected[i].Name, services[i].Name)
+			assert.Equal(t, expected[i].Constructed, services[i].Constructed)
+			assert.Equal(t, expected[i].Dependencies, services[i].Dependencies)
+		}
+	})
+
+	t.Run("Graph", func(t *testing.T) {
+		graph := registry.DependencyGraph()
+
+		resp, body := get(t, "/debug/services/graph.dot")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, graph.DOT(), body)
+
+		resp, body = get(t, "/debug/services/graph.mermaid")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, graph.Mermaid(), body)
+
+		data, err := graph.JSON()
+		require.NoError(t, err)
+
+		resp, body = get(t, "/debug/services/graph.json")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, string(data), body)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		resp, _ := get(t, "/debug/services/unknown")
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/debug/services/", "text/plain", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+	})
+}
+
+func TestValidate(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	err := registry.Validate()
+
+	var notRegisteredErr ServiceNotRegisteredError
+	require.ErrorAs(t, err, &notRegisteredErr)
+
+	assert.EqualError(t, err, strings.Join([]string{
+		"no factory registered for Any",
+		"no factory registered for Buffer",
+		"no factory registered for Clock",
+		"no factory registered for Config",
+		"no factory registered for DB",
+		"no factory registered for Handlers",
+		"no factory registered for Job with name 'job'",
+		"no factory registered for Request",
+		"no factory registered for ServiceA",
+		"no factory registered for ServiceC",
+		"no factory registered for UserRepo",
+	}, "\n"))
+
+	registry.RegisterAny(func(_ ServiceLocator) (any, error) { return nil, nil })
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) { return nil, nil })
+	registry.RegisterClock(func(_ ServiceLocator) (func() time.Time, error) { return time.Now, nil })
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) { return nil, nil })
+	registry.RegisterDB(func(_ ServiceLocator) (*DB, error) { return nil, nil })
+	registry.RegisterHandlers(func(_ ServiceLocator) ([]Handler, error) { return nil, nil })
+	registry.RegisterJob("job", func(_ string, _ ServiceLocator) (*Job, error) { return nil, nil })
+	registry.RegisterRequest(func(_ ServiceLocator) (*Request, error) { return nil, nil })
+	registry.RegisterServiceA(func(_ ServiceLocator) (ServiceA, error) { return nil, nil })
+	registry.RegisterServiceC(func(_ ServiceLocator) (subtest.ServiceC, error) { return nil, nil })
+	registry.RegisterUserRepo(func(_ ServiceLocator) (Repo[User], error) { return Repo[User]{}, nil })
+
+	// Named services are only validated when required
+	assert.NoError(t, registry.Validate())
+}
+
+func TestInitAll(t *testing.T) {
+	registry := NewServiceRegistry(WithInitWorkers(4))
+
+	var constructed atomic.Int32
+
+	// Independent services are constructed concurrently: each of them waits for the other one to start
+	var started sync.WaitGroup
+	started.Add(2)
+
+	waitForOthers := func() error {
+		started.Done()
+
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("services are not constructed concurrently")
+		}
+	}
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		constructed.Add(1)
+
+		return &Config{}, waitForOthers()
+	})
+
+	registry.RegisterHandlers(func(_ ServiceLocator) ([]Handler, error) {
+		constructed.Add(1)
+
+		return nil, waitForOthers()
+	})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		constructed.Add(1)
+
+		serviceB, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: serviceB}, nil
+	})
+
+	for _, name := range []string{"service", "other"} {
+		registry.RegisterServiceB(name, func(_ string, _ ServiceLocator) (ServiceB, error) {
+			constructed.Add(1)
+
+			return serviceB{}, nil
+		})
+	}
+
+	// Transient services are not constructed
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		constructed.Add(1)
+
+		return new(bytes.Buffer), nil
+	})
+
+	require.NoError(t, registry.InitAll(context.Background()))
+
+	assert.Equal(t, int32(5), constructed.Load())
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(5), constructed.Load())
+}
+
+func TestInitAllErrors(t *testing.T) {
+	registry := NewServiceRegistry(WithInitWorkers(1))
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return nil, errors.New("config failed")
+	})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return nil, errors.New("service failed")
+	})
+
+	err := registry.InitAll(context.Background())
+
+	// The failure of ServiceB is reported once (by ServiceA constructing it first)
+	assert.EqualError(t, err, "failed to construct Config (Config): config failed\nfailed to construct ServiceB 'service' (ServiceA -> ServiceB:service): service failed")
+}
+
+func TestRegistrationOverride(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	require.NoError(t, registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{Name: "first"}, nil
+	}))
+
+	config, err := registry.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "first", config.Name)
+
+	// Overriding the factory discards the cached instance
+	require.NoError(t, registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{Name: "second"}, nil
+	}))
+
+	config, err = registry.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "second", config.Name)
+
+	registry.UnregisterConfig()
+
+	_, err = registry.GetConfig()
+	assert.Equal(t, ServiceNotRegisteredError{ServiceType: "Config"}, err)
+}
+
+func TestRegistrationOverrideDuringConstruction(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	require.NoError(t, registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		close(started)
+		<-release
+
+		return &Config{Name: "first"}, nil
+	}))
+
+	done := make(chan *Config)
+
+	go func() {
+		config, err := registry.GetConfig()
+		assert.NoError(t, err)
+
+		done <- config
+	}()
+
+	<-started
+
+	require.NoError(t, registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{Name: "second"}, nil
+	}))
+
+	close(release)
+
+	// The pending resolution receives the instance constructed by the previous factory...
+	assert.Equal(t, "first", (<-done).Name)
+
+	// ...but it is not cached
+	config, err := registry.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "second", config.Name)
+}
+
+func TestUnrelatedRegistrationDuringConstruction(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var calls int
+
+	require.NoError(t, registry.RegisterServiceB("y", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		calls++
+
+		// Registrations of other names do not affect the instance of y
+		assert.NoError(t, registry.RegisterServiceB(fmt.Sprintf("x%d", calls), func(_ string, _ ServiceLocator) (ServiceB, error) {
+			return serviceB{}, nil
+		}))
+		assert.NoError(t, registry.RegisterServiceBPattern(fmt.Sprintf("p%d-*", calls), func(_ string, _ ServiceLocator) (ServiceB, error) {
+			return serviceB{}, nil
+		}))
+
+		return serviceB{}, nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := registry.GetServiceB("y")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, calls)
+
+	var defaultCalls int
+
+	require.NoError(t, registry.RegisterServiceBDefault(func(_ string, _ ServiceLocator) (ServiceB, error) {
+		defaultCalls++
+
+		// Names without a factory of their own are only affected by pattern and default factories
+		assert.NoError(t, registry.RegisterServiceB(fmt.Sprintf("z%d", defaultCalls), func(_ string, _ ServiceLocator) (ServiceB, error) {
+			return serviceB{}, nil
+		}))
+
+		return serviceB{}, nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := registry.GetServiceB("fallback")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, defaultCalls)
+}
+
+func TestRegistrationErrorOnDuplicate(t *testing.T) {
+	registry := NewServiceRegistry(WithRegistrationPolicy(ErrorOnDuplicate))
+
+	factory := func(name string, _ ServiceLocator) (*Job, error) {
+		return &Job{Name: name}, nil
+	}
+
+	require.NoError(t, registry.RegisterJob("job", factory))
+	require.NoError(t, registry.RegisterJob("other", factory))
+
+	err := registry.RegisterJob("job", factory)
+	assert.Equal(t, ServiceAlreadyRegisteredError{ServiceType: "Job", ServiceName: "job"}, err)
+
+	registry.UnregisterJob("job")
+
+	assert.NoError(t, registry.RegisterJob("job", factory))
+}
+
+func TestRegistrationPanicOnDuplicate(t *testing.T) {
+	registry := NewServiceRegistry(WithRegistrationPolicy(PanicOnDuplicate))
+
+	factory := func(_ ServiceLocator) (*Config, error) {
+		return &Config{}, nil
+	}
+
+	require.NoError(t, registry.RegisterConfig(factory))
+
+	assert.PanicsWithError(t, "factory already registered for Config", func() {
+		_ = registry.RegisterConfig(factory)
+	})
+}
+
+func TestRegisterInstance(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var closed []string
+
+	config := &Config{Name: "config"}
+
+	require.NoError(t, registry.RegisterConfigInstance(config))
+	require.NoError(t, registry.RegisterServiceAInstance(closableServiceA{closed: &closed}))
+	require.NoError(t, registry.RegisterServiceBInstance("service", closableServiceB{name: "service", closed: &closed}))
+
+	actualConfig, err := registry.GetConfig()
+	require.NoError(t, err)
+
+	assert.Same(t, config, actualConfig)
+
+	serviceB, err := registry.GetServiceB("service")
+	require.NoError(t, err)
+
+	assert.Equal(t, closableServiceB{name: "service", closed: &closed}, serviceB)
+
+	// Instances count as registered
+	assert.NotContains(t, registry.Validate().Error(), "Config")
+	assert.Equal(t, []string{"Config", "ServiceA", "ServiceB:service"}, registry.DependencyGraph().Services)
+
+	// Instances are closed in reverse registration order
+	assert.Error(t, registry.Close(context.Background()))
+	assert.Equal(t, []string{"ServiceB:service", "ServiceA"}, closed)
+}
+
+type decoratedServiceB struct {
+	ServiceB
+
+	decorations []string
+}
+
+func TestDecorators(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{Name: "config"}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceB("other", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.DecorateServiceB("service", func(service ServiceB, serviceLocator ServiceLocator) (ServiceB, error) {
+		// Decorators can resolve other services
+		config, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return decoratedServiceB{ServiceB: service, decorations: []string{config.Name}}, nil
+	})
+
+	registry.DecorateServiceB("service", func(service ServiceB, _ ServiceLocator) (ServiceB, error) {
+		decorated := service.(decoratedServiceB)
+		decorated.decorations = append(decorated.decorations, "second")
+
+		return decorated, nil
+	})
+
+	service, err := registry.GetServiceB("service")
+	require.NoError(t, err)
+
+	assert.Equal(t, decoratedServiceB{ServiceB: serviceB{}, decorations: []string{"config", "second"}}, service)
+
+	// Decorators are keyed by name
+	other, err := registry.GetServiceB("other")
+	require.NoError(t, err)
+
+	assert.Equal(t, serviceB{}, other)
+
+	assert.Contains(t, registry.DependencyGraph().Dependencies, ServiceDependency{Service: "ServiceB:service", Dependency: "Config"})
+}
+
+func TestDecoratorCircularDependencyDetection(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	})
+
+	registry.DecorateServiceB("service", func(service ServiceB, serviceLocator ServiceLocator) (ServiceB, error) {
+		_, err := serviceLocator.GetServiceA()
+		if err != nil {
+			return nil, err
+		}
+
+		return service, nil
+	})
+
+	_, err := registry.GetServiceA()
+
+	var circularErr CircularDependencyError
+	require.ErrorAs(t, err, &circularErr)
+
+	assert.Equal(t, "ServiceA", circularErr.ServiceType)
+}
+
+type recordingHooks struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHooks) record(event string, serviceType string, serviceName string, path []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if serviceName != "" {
+		serviceType += ":" + serviceName
+	}
+
+	h.events = append(h.events, fmt.Sprintf("%s %s %v", event, serviceType, path))
+}
+
+func (h *recordingHooks) ResolveStart(_ context.Context, serviceType string, serviceName string, path []string) {
+	h.record("ResolveStart", serviceType, serviceName, path)
+}
+
+func (h *recordingHooks) CacheHit(_ context.Context, serviceType string, serviceName string, path []string) {
+	h.record("CacheHit", serviceType, serviceName, path)
+}
+
+func (h *recordingHooks) FactoryStart(ctx context.Context, serviceType string, serviceName string, path []string) context.Context {
+	h.record("FactoryStart", serviceType, serviceName, path)
+
+	return ctx
+}
+
+func (h *recordingHooks) FactoryEnd(_ context.Context, serviceType string, serviceName string, path []string, _ time.Duration, err error) {
+	h.record(fmt.Sprintf("FactoryEnd(%v)", err), serviceType, serviceName, path)
+}
+
+func (h *recordingHooks) CircularDependency(_ context.Context, serviceType string, serviceName string, path []string) {
+	h.record("CircularDependency", serviceType, serviceName, path)
+}
+
+func TestResolutionHooks(t *testing.T) {
+	recorder := &recordingHooks{}
+	registry := NewServiceRegistry(WithResolutionHooks(recorder))
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		service, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: service}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		_, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceB{}, nil
+	})
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return nil, errors.New("no config")
+	})
+
+	_, err := registry.GetServiceA()
+	require.Error(t, err)
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		return &Config{}, nil
+	})
+
+	recorder.events = nil
+
+	_, err = registry.GetServiceA()
+	require.NoError(t, err)
+
+	_, err = registry.GetServiceA()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"ResolveStart ServiceA []",
+		"FactoryStart ServiceA [ServiceA]",
+		"ResolveStart ServiceB:service [ServiceA]",
+		"FactoryStart ServiceB:service [ServiceA ServiceB:service]",
+		"ResolveStart Config [ServiceA ServiceB:service]",
+		"FactoryStart Config [ServiceA ServiceB:service Config]",
+		"FactoryEnd(<nil>) Config [ServiceA ServiceB:service Config]",
+		"FactoryEnd(<nil>) ServiceB:service [ServiceA ServiceB:service]",
+		"FactoryEnd(<nil>) ServiceA [ServiceA]",
+		"ResolveStart ServiceA []",
+		"CacheHit ServiceA []",
+	}, recorder.events)
+}
+
+func TestResolutionHooksFactoryError(t *testing.T) {
+	recorder := &recordingHooks{}
+	registry := NewServiceRegistry(WithResolutionHooks(recorder))
+
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		return nil, errors.New("no buffer")
+	})
+
+	_, err := registry.GetBuffer()
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		"ResolveStart Buffer []",
+		"FactoryStart Buffer [Buffer]",
+		"FactoryEnd(no buffer) Buffer [Buffer]",
+	}, recorder.events)
+}
+
+func TestResolutionHooksCircularDependency(t *testing.T) {
+	recorder := &recordingHooks{}
+	registry := NewServiceRegistry(WithResolutionHooks(recorder))
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		_, err := serviceLocator.GetServiceA()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.Error(t, err)
+
+	assert.Contains(t, recorder.events, "CircularDependency ServiceA [ServiceA ServiceB:service]")
+}
+
+func TestTracingHooks(t *testing.T) {
+	tracer := hooks.NewInMemoryTracer()
+	registry := NewServiceRegistry(WithResolutionHooks(hooks.NewTracing(tracer)))
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetServiceB("service")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterServiceB("service", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		time.Sleep(10 * time.Millisecond)
+
+		return nil, errors.New("no service")
+	})
+
+	_, err := registry.GetServiceA()
+	require.Error(t, err)
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "construct ServiceA", spans[0].Name)
+	assert.Equal(t, 0, spans[0].ParentID)
+	assert.Equal(t, hooks.StatusError, spans[0].StatusCode)
+
+	// The span of a dependency is nested into the span of the service depending on it
+	assert.Equal(t, "construct ServiceB:service", spans[1].Name)
+	assert.Equal(t, spans[0].ID, spans[1].ParentID)
+	assert.Equal(t, []hooks.Attribute{
+		{Key: hooks.ServiceTypeKey, Value: "ServiceB"},
+		{Key: hooks.ServiceNameKey, Value: "service"},
+		{Key: hooks.PathKey, Value: []string{"ServiceA", "ServiceB:service"}},
+	}, spans[1].Attributes)
+	assert.Equal(t, hooks.StatusError, spans[1].StatusCode)
+	assert.Equal(t, []error{errors.New("no service")}, spans[1].Errors)
+	assert.GreaterOrEqual(t, spans[1].EndTime.Sub(spans[1].StartTime), 10*time.Millisecond)
+}
+
+func TestTracingHooksFactoryPanic(t *testing.T) {
+	tracer := hooks.NewInMemoryTracer()
+	registry := NewServiceRegistry(WithResolutionHooks(hooks.NewTracing(tracer)))
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		panic("no config")
+	})
+
+	require.NoError(t, registry.RegisterJobDefault(func(_ string, _ ServiceLocator) (*Job, error) {
+		panic("no job")
+	}))
+
+	assert.PanicsWithValue(t, "no config", func() { _, _ = registry.GetConfig() })
+	assert.PanicsWithValue(t, "no job", func() { _, _ = registry.GetJob("job") })
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "construct Config", spans[0].Name)
+	assert.Equal(t, hooks.StatusError, spans[0].StatusCode)
+	assert.Equal(t, "panic: no config", spans[0].StatusDescription)
+
+	assert.Equal(t, "construct Job:job", spans[1].Name)
+	assert.Equal(t, hooks.StatusError, spans[1].StatusCode)
+	assert.Equal(t, "panic: no job", spans[1].StatusDescription)
+}
+
+func TestNamedServiceEnumeration(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	for _, name := range []string{"second", "first"} {
+		registry.RegisterServiceB(name, func(_ string, _ ServiceLocator) (ServiceB, error) {
+			return serviceB{}, nil
+		})
+	}
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		assert.Equal(t, []string{"first", "second"}, serviceLocator.ServiceBNames())
+
+		services, err := serviceLocator.GetAllServiceB()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: services["first"]}, nil
+	})
+
+	assert.Equal(t, []string{"first", "second"}, registry.ServiceBNames())
+
+	services, err := registry.GetAllServiceB()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]ServiceB{"first": serviceB{}, "second": serviceB{}}, services)
+
+	_, err = registry.GetServiceA()
+	require.NoError(t, err)
+
+	assert.Contains(t, registry.DependencyGraph().Dependencies, ServiceDependency{Service: "ServiceA", Dependency: "ServiceB:second"})
+
+	// Errors are returned as is
+	registry.RegisterServiceB("failing", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return nil, errors.New("failing")
+	})
+
+	_, err = registry.GetAllServiceB()
+
+	var constructionErr ServiceConstructionError
+	require.ErrorAs(t, err, &constructionErr)
+
+	assert.Equal(t, "failing", constructionErr.ServiceName)
+
+	// Names are empty without registered factories
+	assert.Empty(t, NewServiceRegistry().ServiceBNames())
+}
+
+func TestScopedNamedServiceEnumeration(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	for _, name := range []string{"admin", "user"} {
+		registry.RegisterSession(name, func(name string, _ ServiceLocator) (*Session, error) {
+			return &Session{Name: name}, nil
+		})
+	}
+
+	_, err := registry.GetAllSession()
+	require.Error(t, err)
+
+	scope := registry.NewScope()
+
+	assert.Equal(t, []string{"admin", "user"}, scope.SessionNames())
+
+	sessions, err := scope.GetAllSession()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]*Session{"admin": {Name: "admin"}, "user": {Name: "user"}}, sessions)
+
+	// Instances are cached by the scope
+	session, err := scope.GetSession("admin")
+	require.NoError(t, err)
+
+	assert.Same(t, sessions["admin"], session)
+}
+
+func TestFallbackFactories(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceB("cache.main", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return decoratedServiceB{decorations: []string{"exact"}}, nil
+	})
+
+	require.NoError(t, registry.RegisterServiceBPattern("cache.*", func(name string, _ ServiceLocator) (ServiceB, error) {
+		return decoratedServiceB{decorations: []string{"pattern", name}}, nil
+	}))
+
+	require.NoError(t, registry.RegisterServiceBPattern("cache.?", func(name string, _ ServiceLocator) (ServiceB, error) {
+		return decoratedServiceB{decorations: []string{"second pattern", name}}, nil
+	}))
+
+	require.NoError(t, registry.RegisterServiceBDefault(func(name string, _ ServiceLocator) (ServiceB, error) {
+		return decoratedServiceB{decorations: []string{"default", name}}, nil
+	}))
+
+	for name, expected := range map[string][]string{
+		"cache.main":  {"exact"},
+		"cache.users": {"pattern", "cache.users"},
+		"cache.x":     {"pattern", "cache.x"}, // the pattern registered first wins
+		"shard-1":     {"default", "shard-1"},
+	} {
+		service, err := registry.GetServiceB(name)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected, service.(decoratedServiceB).decorations, name)
+	}
+
+	// Instances constructed by fallback factories are cached by name
+	first, err := registry.GetServiceB("shard-1")
+	require.NoError(t, err)
+
+	second, err := registry.GetServiceB("shard-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+
+	// Only names with a factory of their own are enumerated
+	assert.Equal(t, []string{"cache.main"}, registry.ServiceBNames())
+
+	// Replacing fallback factories discards instances they constructed
+	require.NoError(t, registry.RegisterServiceBDefault(func(name string, _ ServiceLocator) (ServiceB, error) {
+		return decoratedServiceB{decorations: []string{"new default", name}}, nil
+	}))
+
+	service, err := registry.GetServiceB("shard-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"new default", "shard-1"}, service.(decoratedServiceB).decorations)
+
+	registry.UnregisterServiceBPattern("cache.*")
+
+	service, err = registry.GetServiceB("cache.y")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"second pattern", "cache.y"}, service.(decoratedServiceB).decorations)
+
+	registry.UnregisterServiceBDefault()
+
+	_, err = registry.GetServiceB("shard-2")
+
+	var notRegisteredErr ServiceNotRegisteredError
+	require.ErrorAs(t, err, &notRegisteredErr)
+}
+
+func TestFallbackFactoriesValidation(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	require.Error(t, registry.RegisterServiceBPattern("cache.[", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	}))
+
+	// Pattern factories satisfy required names
+	require.NoError(t, registry.RegisterJobPattern("j*", func(name string, _ ServiceLocator) (*Job, error) {
+		return &Job{Name: name}, nil
+	}))
+
+	err := registry.Validate()
+
+	var notRegisteredErr ServiceNotRegisteredError
+	require.ErrorAs(t, err, &notRegisteredErr)
+	assert.NotEqual(t, "Job", notRegisteredErr.ServiceType)
+
+	job, err := registry.GetJob("job")
+	require.NoError(t, err)
+
+	assert.Equal(t, &Job{Name: "job"}, job)
+}
+
+func TestFallbackFactoriesRegistrationPolicy(t *testing.T) {
+	registry := NewServiceRegistry(WithRegistrationPolicy(ErrorOnDuplicate))
+
+	factory := func(_ string, _ ServiceLocator) (ServiceB, error) {
+		return serviceB{}, nil
+	}
+
+	require.NoError(t, registry.RegisterServiceBPattern("cache.*", factory))
+	require.NoError(t, registry.RegisterServiceBDefault(factory))
+
+	var alreadyRegisteredErr ServiceAlreadyRegisteredError
+
+	require.ErrorAs(t, registry.RegisterServiceBPattern("cache.*", factory), &alreadyRegisteredErr)
+	assert.Equal(t, ServiceAlreadyRegisteredError{ServiceType: "ServiceB", ServiceName: "cache.*"}, alreadyRegisteredErr)
+
+	require.ErrorAs(t, registry.RegisterServiceBDefault(factory), &alreadyRegisteredErr)
+}
+
+func TestCircularDependencyPath(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		_, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	registry.RegisterConfig(func(serviceLocator ServiceLocator) (*Config, error) {
+		// Resolved services are not part of the path of the resolution
+		_, err := serviceLocator.GetBuffer()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = serviceLocator.GetServiceB("x")
+		if err != nil {
+			return nil, err
+		}
+
+		return &Config{}, nil
+	})
+
+	registry.RegisterBuffer(func(_ ServiceLocator) (*bytes.Buffer, error) {
+		return new(bytes.Buffer), nil
+	})
+
+	registry.RegisterServiceB("x", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		_, err := serviceLocator.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceB{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+
+	assert.Equal(t, CircularDependencyError{
+		ServiceType:     "Config",
+		DependencyGraph: []string{"Config", "ServiceB:x", "Config"},
+	}, err)
+	assert.EqualError(t, err, "circular dependency detected for Config: Config -> ServiceB:x -> Config")
+}
+
+func TestRetryAfterFailedResolution(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var attempts int
+
+	registry.RegisterServiceB("x", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("temporary failure")
+		}
+
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		// The first attempt fails: trying again is not a circular dependency
+		_, err := serviceLocator.GetServiceB("x")
+		require.Error(t, err)
+
+		var constructionErr ServiceConstructionError
+		require.ErrorAs(t, err, &constructionErr)
+
+		assert.Equal(t, []string{"ServiceA", "ServiceB:x"}, constructionErr.Path)
+
+		service, err := serviceLocator.GetServiceB("x")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceA{serviceB: service}, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, attempts)
+}
+
+func TestFactoryPanic(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	var attempts atomic.Int32
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	registry.RegisterServiceB("x", func(_ string, _ ServiceLocator) (ServiceB, error) {
+		if attempts.Add(1) == 1 {
+			close(started)
+			<-release
+
+			panic("boom")
+		}
+
+		return serviceB{}, nil
+	})
+
+	panicked := make(chan any)
+
+	go func() {
+		defer func() {
+			panicked <- recover()
+		}()
+
+		_, _ = registry.GetServiceB("x")
+	}()
+
+	<-started
+
+	waiting := make(chan error)
+
+	go func() {
+		_, err := registry.GetServiceB("x")
+		waiting <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "boom", <-panicked)
+
+	select {
+	case err := <-waiting:
+		// The waiting resolution may also start after the panic (and construct the service)
+		if err != nil {
+			var constructionErr ServiceConstructionError
+			require.ErrorAs(t, err, &constructionErr)
+
+			assert.EqualError(t, constructionErr.Err, "panic: boom")
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("resolution waiting for a panicking factory hangs")
+	}
+
+	// The service can be resolved again
+	service, err := registry.GetServiceB("x")
+	require.NoError(t, err)
+	assert.NotNil(t, service)
+}
+
+func TestConcurrentResolutionInFactory(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	configStarted := make(chan struct{})
+
+	registry.RegisterConfig(func(_ ServiceLocator) (*Config, error) {
+		close(configStarted)
+
+		// Give the other branch time to wait for the instance
+		time.Sleep(50 * time.Millisecond)
+
+		return &Config{}, nil
+	})
+
+	for _, name := range []string{"x", "y"} {
+		registry.RegisterServiceB(name, func(name string, serviceLocator ServiceLocator) (ServiceB, error) {
+			if name == "y" {
+				<-configStarted
+			}
+
+			_, err := serviceLocator.GetConfig()
+			if err != nil {
+				return nil, err
+			}
+
+			return serviceB{}, nil
+		})
+	}
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		// Both branches share the same service locator and depend on the same service
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+
+		for i, name := range []string{"x", "y"} {
+			i, name := i, name
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, errs[i] = serviceLocator.GetServiceB(name)
+			}()
+		}
+
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+
+		return serviceA{}, nil
+	})
+
+	_, err := registry.GetServiceA()
+	require.NoError(t, err)
+}
+
+func TestConcurrentCircularDependencyInFactory(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	startedX := make(chan struct{})
+	startedY := make(chan struct{})
+
+	registry.RegisterServiceB("x", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		close(startedX)
+		<-startedY
+
+		_, err := serviceLocator.GetServiceB("y")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceB("y", func(_ string, serviceLocator ServiceLocator) (ServiceB, error) {
+		close(startedY)
+		<-startedX
+
+		_, err := serviceLocator.GetServiceB("x")
+		if err != nil {
+			return nil, err
+		}
+
+		return serviceB{}, nil
+	})
+
+	registry.RegisterServiceA(func(serviceLocator ServiceLocator) (ServiceA, error) {
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+
+		for i, name := range []string{"x", "y"} {
+			i, name := i, name
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, errs[i] = serviceLocator.GetServiceB(name)
+			}()
+		}
+
+		wg.Wait()
+
+		return serviceA{}, errors.Join(errs...)
+	})
+
+	errs := make(chan error, 1)
+
+	go func() {
+		_, err := registry.GetServiceA()
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		assert.ErrorAs(t, err, &CircularDependencyError{})
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: service resolution did not return")
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	registry.RegisterConfigInstance(&Config{Name: "app"})
+	registry.RegisterClock(func(_ ServiceLocator) (func() time.Time, error) {
+		return func() time.Time { return now }, nil
+	})
+
+	mailer, err := registry.GetMailer()
+	require.NoError(t, err)
+
+	require.IsType(t, &SMTPMailer{}, mailer)
+	assert.Equal(t, "app", mailer.(*SMTPMailer).Config.Name)
+	assert.Equal(t, now, mailer.(*SMTPMailer).CreatedAt)
+
+	// Named services are constructed by the default factory
+	worker, err := registry.GetWorker("worker")
+	require.NoError(t, err)
+
+	assert.Equal(t, "worker", worker.Name)
+	assert.NotNil(t, worker.Mailer)
+
+	assert.Equal(t, []ServiceDependency{
+		{Service: "Mailer", Dependency: "Clock"},
+		{Service: "Mailer", Dependency: "Config"},
+		{Service: "Worker:*", Dependency: "Mailer"},
+	}, registry.DependencyGraph().Dependencies)
+
+	for _, service := range registry.Services() {
+		if service.Name == "Mailer" || service.Name == "Worker" {
+			assert.True(t, service.Registered, service.Name)
+		}
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	t.Run("NotRegistered", func(t *testing.T) {
+		registry := NewServiceRegistry()
+
+		registry.RegisterConfigInstance(&Config{Name: "app"})
+
+		_, err := registry.GetMailer()
+
+		var notRegisteredErr ServiceNotRegisteredError
+		require.ErrorAs(t, err, &notRegisteredErr)
+
+		assert.Equal(t, ServiceNotRegisteredError{ServiceType: "Clock", Path: []string{"Mailer"}}, notRegisteredErr)
+	})
+
+	t.Run("Constructor", func(t *testing.T) {
+		registry := NewServiceRegistry()
+
+		registry.RegisterConfigInstance(&Config{})
+		registry.RegisterClock(func(_ ServiceLocator) (func() time.Time, error) {
+			return time.Now, nil
+		})
+
+		_, err := registry.GetWorker("worker")
+
+		var constructionErr ServiceConstructionError
+		require.ErrorAs(t, err, &constructionErr)
+
+		assert.Equal(t, "Mailer", constructionErr.ServiceType)
+		assert.Equal(t, []string{"Worker:worker", "Mailer"}, constructionErr.Path)
+		assert.EqualError(t, constructionErr.Err, "missing config name")
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		registry := NewServiceRegistry()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		registry.RegisterConfigInstance(&Config{Name: "app"})
+		registry.RegisterClock(func(_ ServiceLocator) (func() time.Time, error) {
+			cancel()
+
+			return time.Now, nil
+		})
+
+		_, err := registry.GetMailerContext(ctx)
+
+		// The constructor receives the context of the resolution
+		var constructionErr ServiceConstructionError
+		require.ErrorAs(t, err, &constructionErr)
+
+		assert.Equal(t, "Mailer", constructionErr.ServiceType)
+		assert.ErrorIs(t, constructionErr.Err, context.Canceled)
+	})
+}
+
+func TestConstructorOverride(t *testing.T) {
+	registry := NewServiceRegistry()
+
+	mailer := &SMTPMailer{}
+
+	require.NoError(t, registry.RegisterMailer(func(_ ServiceLocator) (Mailer, error) {
+		return mailer, nil
+	}))
+
+	actual, err := registry.GetMailer()
+	require.NoError(t, err)
+
+	assert.Same(t, mailer, actual)
+
+	// Replacing the constructor is not subject to the registration policy
+	for _, policy := range []RegistrationPolicy{ErrorOnDuplicate, PanicOnDuplicate} {
+		registry = NewServiceRegistry(WithRegistrationPolicy(policy))
+
+		require.NoError(t, registry.RegisterMailer(func(_ ServiceLocator) (Mailer, error) {
+			return mailer, nil
+		}))
+
+		require.NoError(t, registry.RegisterWorkerDefault(func(_ string, _ ServiceLocator) (*Worker, error) {
+			return &Worker{}, nil
+		}))
+	}
+
+	// Factories registered afterwards are duplicates
+	assert.Panics(t, func() {
+		_ = registry.RegisterWorkerDefault(func(_ string, _ ServiceLocator) (*Worker, error) {
+			return &Worker{}, nil
+		})
+	})
+}
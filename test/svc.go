@@ -1,12 +1,49 @@
 package test
 
-import "github.com/sagikazarmark/go-service-locator/test/subtest"
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sagikazarmark/go-service-locator/test/subtest"
+)
 
 // ServiceLocator locates named services in a type-safe manner.
 type ServiceLocator interface {
 	GetServiceA() (ServiceA, error)
 	GetServiceB(name string) (ServiceB, error)
+	ServiceBNames() []string
+	GetAllServiceB() (map[string]ServiceB, error)
 	GetServiceC() (subtest.ServiceC, error)
+	GetConfig() (*Config, error)
+	GetHandlers() ([]Handler, error)
+	GetClock() (func() time.Time, error)
+	GetUserRepo() (Repo[User], error)
+	GetAny() (any, error)
+	GetDB() (*DB, error)
+
+	//servicelocator:transient
+	GetBuffer() (*bytes.Buffer, error)
+
+	//servicelocator:transient
+	//servicelocator:required job
+	GetJob(name string) (*Job, error)
+
+	//servicelocator:scoped
+	GetRequest() (*Request, error)
+
+	//servicelocator:scoped
+	GetSession(name string) (*Session, error)
+
+	//servicelocator:transient
+	//servicelocator:constructor NewSMTPMailer
+	GetMailer() (Mailer, error)
+
+	//servicelocator:transient
+	//servicelocator:constructor NewWorker
+	GetWorker(name string) (*Worker, error)
 }
 
 // ServiceA is an example for service locator tests.
@@ -18,3 +55,106 @@ type ServiceA interface {
 type ServiceB interface {
 	Bar()
 }
+
+// Config is an example for non-interface services.
+type Config struct {
+	Name string
+}
+
+// Handler is an example for non-interface services.
+type Handler func() error
+
+// User is an example for generic services.
+type User struct {
+	ID string
+}
+
+// Repo is an example for generic services.
+type Repo[T any] struct {
+	Items []T
+}
+
+// DB is an example for services declared with an alias type.
+type DB = sql.DB
+
+// Job is an example for transient named services.
+type Job struct {
+	Name string
+}
+
+// Request is an example for scoped services.
+type Request struct {
+	Config *Config
+	Closed bool
+}
+
+// Close implements io.Closer.
+func (r *Request) Close() error {
+	r.Closed = true
+
+	return nil
+}
+
+// Session is an example for scoped named services.
+type Session struct {
+	Name    string
+	Request *Request
+	Closed  bool
+}
+
+// Close closes the session.
+func (s *Session) Close(_ context.Context) error {
+	if s.Request != nil && s.Request.Closed {
+		return errors.New("request closed before session")
+	}
+
+	s.Closed = true
+
+	return nil
+}
+
+// Mailer is an example for services constructed by a constructor.
+type Mailer interface {
+	Send(to string) error
+}
+
+// SMTPMailer is an example for constructors returning a type assignable to the service type.
+type SMTPMailer struct {
+	Config    *Config
+	CreatedAt time.Time
+}
+
+// NewSMTPMailer is an example for constructors resolving their parameters from the service locator.
+func NewSMTPMailer(ctx context.Context, config *Config, clock func() time.Time) (*SMTPMailer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if config.Name == "" {
+		return nil, errors.New("missing config name")
+	}
+
+	return &SMTPMailer{
+		Config:    config,
+		CreatedAt: clock(),
+	}, nil
+}
+
+// Send implements Mailer.
+func (m *SMTPMailer) Send(_ string) error {
+	return nil
+}
+
+// Worker is an example for named services constructed by a constructor.
+type Worker struct {
+	Name   string
+	Mailer Mailer
+}
+
+// NewWorker is an example for constructors of named services.
+func NewWorker(name string, mailer Mailer) *Worker {
+	return &Worker{
+		Name:   name,
+		Mailer: mailer,
+	}
+}
@@ -0,0 +1,10 @@
+package clash
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+
+	// Implemented by the generated registry
+	Validate() error
+}
+
+type Config struct{}
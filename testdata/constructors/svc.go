@@ -0,0 +1,106 @@
+package constructors
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+	GetReader() (io.Reader, error)
+	GetHandlers() ([]Handler, error)
+	GetMiddlewares() ([]Handler, error)
+	GetJob(name string) (*Job, error)
+
+	//servicelocator:constructor NewMailer
+	GetMailer() (*Mailer, error)
+
+	//servicelocator:constructor io.NopCloser
+	GetReadCloser() (io.ReadCloser, error)
+
+	//servicelocator:constructor NewWorker
+	GetWorker(name string) (*Worker, error)
+
+	//servicelocator:constructor NewMissing
+	GetMissing() (*Missing, error)
+
+	//servicelocator:constructor net/http.NewRequest
+	GetRequest() (*Request, error)
+
+	//servicelocator:constructor NewGeneric
+	GetGeneric() (*Generic, error)
+
+	//servicelocator:constructor NewVariadic
+	GetVariadic() (*Variadic, error)
+
+	//servicelocator:constructor NewNothing
+	GetNothing() (*Nothing, error)
+
+	//servicelocator:constructor NewConfig
+	GetWrongType() (*WrongType, error)
+
+	//servicelocator:constructor NewTimeout
+	GetTimeout() (*Timeout, error)
+
+	//servicelocator:constructor NewRouter
+	GetRouter() (*Router, error)
+
+	//servicelocator:constructor NewSelf
+	GetSelf() (*Self, error)
+
+	//servicelocator:constructor NewScheduler
+	GetScheduler() (*Scheduler, error)
+}
+
+type Config struct{}
+
+func NewConfig() *Config { return &Config{} }
+
+type Handler func()
+
+type Job struct{}
+
+type Mailer struct{}
+
+func NewMailer(ctx context.Context, config *Config, serviceLocator ServiceLocator) (*Mailer, error) {
+	return &Mailer{}, nil
+}
+
+type Worker struct{}
+
+func NewWorker(name string, mailer *Mailer) *Worker { return &Worker{} }
+
+type Missing struct{}
+
+type Request struct{}
+
+type Generic struct{}
+
+func NewGeneric[T any]() *Generic { return &Generic{} }
+
+type Variadic struct{}
+
+func NewVariadic(configs ...*Config) *Variadic { return &Variadic{} }
+
+type Nothing struct{}
+
+func NewNothing() {}
+
+type WrongType struct{}
+
+type Timeout struct{}
+
+func NewTimeout(timeout time.Duration) *Timeout { return &Timeout{} }
+
+type Router struct{}
+
+func NewRouter(handlers []Handler) *Router { return &Router{} }
+
+type Self struct{}
+
+func NewSelf(self *Self) *Self { return self }
+
+type Scheduler struct{}
+
+func NewScheduler(job *Job) *Scheduler { return &Scheduler{} }
@@ -0,0 +1,37 @@
+package diagnostics
+
+import "time"
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+	GetServiceB(name string) (ServiceB, error)
+	ServiceBNames() []string
+
+	GetAlias() *Config
+	GetTimeout() ([]time.Duration, string)
+	Config() (*Config, error)
+	GetSettings() (*Config, error)
+	GetEmpty() (struct{}, error)
+	GetJob(id int) (*Job, error)
+	GetHandler(method, path string) (func(), error)
+
+	//servicelocator:transient
+	//servicelocator:scoped
+	GetWorker() (*Worker, error)
+
+	//servicelocator:required primary
+	GetCache() (*Cache, error)
+
+	//servicelocator:lazy
+	GetClock() (func() time.Time, error)
+}
+
+type Config struct{}
+
+type ServiceB interface{}
+
+type Job struct{}
+
+type Worker struct{}
+
+type Cache struct{}
@@ -0,0 +1,40 @@
+package graph
+
+import "context"
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+	GetDB() (*DB, error)
+
+	//servicelocator:constructor NewMailer
+	GetMailer() (*Mailer, error)
+
+	GetServer() (*Server, error)
+}
+
+type Config struct{}
+
+type DB struct{}
+
+type Mailer struct{}
+
+func NewMailer(config *Config) *Mailer {
+	return &Mailer{}
+}
+
+type Server struct{}
+
+func register(registry *ServiceRegistry) {
+	registry.RegisterDBContext(func(ctx context.Context, serviceLocator ServiceLocator) (*DB, error) {
+		_, err := serviceLocator.GetConfig()
+
+		return &DB{}, err
+	})
+
+	registry.RegisterServer(func(serviceLocator ServiceLocator) (*Server, error) {
+		_, _ = serviceLocator.GetDB()
+		_, _ = serviceLocator.GetMailer()
+
+		return &Server{}, nil
+	})
+}
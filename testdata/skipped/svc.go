@@ -0,0 +1,11 @@
+package skipped
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+
+	// Methods that do not describe a service
+	GetAlias() (*Config, bool, error)
+	Ping(target string, attempts ...int) error
+}
+
+type Config struct{}
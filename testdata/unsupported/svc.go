@@ -0,0 +1,10 @@
+package unsupported
+
+type ServiceLocator interface {
+	GetConfig() (*Config, error)
+
+	// Methods that do not describe a service
+	GetEmpty() (struct{}, error)
+}
+
+type Config struct{}
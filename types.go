@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// typeCode renders t as a type expression, qualifying named types with their import path.
+func typeCode(t types.Type) (*jen.Statement, error) {
+	switch t := unalias(t).(type) {
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return jen.Qual("unsafe", "Pointer"), nil
+		}
+
+		if t.Info()&types.IsUntyped != 0 {
+			return nil, fmt.Errorf("untyped type %s", t)
+		}
+
+		return jen.Id(t.Name()), nil
+
+	case *types.Named:
+		obj := t.Obj()
+
+		var code *jen.Statement
+		if obj.Pkg() == nil {
+			code = jen.Id(obj.Name())
+		} else {
+			code = jen.Qual(obj.Pkg().Path(), obj.Name())
+		}
+
+		args := t.TypeArgs()
+		if args.Len() == 0 {
+			return code, nil
+		}
+
+		argCodes := make([]jen.Code, 0, args.Len())
+		for i := 0; i < args.Len(); i++ {
+			argCode, err := typeCode(args.At(i))
+			if err != nil {
+				return nil, err
+			}
+
+			argCodes = append(argCodes, argCode)
+		}
+
+		return code.Types(argCodes...), nil
+
+	case *types.Pointer:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Op("*").Add(elem), nil
+
+	case *types.Slice:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Index().Add(elem), nil
+
+	case *types.Array:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Index(jen.Lit(int(t.Len()))).Add(elem), nil
+
+	case *types.Map:
+		key, err := typeCode(t.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Map(key).Add(elem), nil
+
+	case *types.Chan:
+		elem, err := typeCode(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		switch t.Dir() {
+		case types.SendOnly:
+			return jen.Chan().Op("<-").Add(elem), nil
+		case types.RecvOnly:
+			return jen.Op("<-").Chan().Add(elem), nil
+		default:
+			return jen.Chan().Add(elem), nil
+		}
+
+	case *types.Signature:
+		if t.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("generic function type %s", t)
+		}
+
+		params, err := tupleCode(t.Params(), t.Variadic())
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := tupleCode(t.Results(), false)
+		if err != nil {
+			return nil, err
+		}
+
+		return jen.Func().Params(params...).Params(results...), nil
+
+	case *types.Interface:
+		if t.Empty() {
+			return jen.Any(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func tupleCode(tuple *types.Tuple, variadic bool) ([]jen.Code, error) {
+	codes := make([]jen.Code, 0, tuple.Len())
+
+	for i := 0; i < tuple.Len(); i++ {
+		typ := tuple.At(i).Type()
+
+		if variadic && i == tuple.Len()-1 {
+			elem, err := typeCode(typ.(*types.Slice).Elem())
+			if err != nil {
+				return nil, err
+			}
+
+			codes = append(codes, jen.Op("...").Add(elem))
+
+			continue
+		}
+
+		code, err := typeCode(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// typeString renders t qualified with package names (eg. *bytes.Buffer).
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
+
+// resolveAliases returns t with every alias in it (including the ones nested in composite types) replaced by its actual type.
+//
+// It covers the same types as typeCode, so that type strings match the generated code.
+func resolveAliases(t types.Type) types.Type {
+	switch t := unalias(t).(type) {
+	case *types.Named:
+		args := t.TypeArgs()
+		if args.Len() == 0 {
+			return t
+		}
+
+		resolved := make([]types.Type, 0, args.Len())
+		for i := 0; i < args.Len(); i++ {
+			resolved = append(resolved, resolveAliases(args.At(i)))
+		}
+
+		inst, err := types.Instantiate(nil, t.Origin(), resolved, false)
+		if err != nil {
+			return t
+		}
+
+		return inst
+
+	case *types.Pointer:
+		return types.NewPointer(resolveAliases(t.Elem()))
+
+	case *types.Slice:
+		return types.NewSlice(resolveAliases(t.Elem()))
+
+	case *types.Array:
+		return types.NewArray(resolveAliases(t.Elem()), t.Len())
+
+	case *types.Map:
+		return types.NewMap(resolveAliases(t.Key()), resolveAliases(t.Elem()))
+
+	case *types.Chan:
+		return types.NewChan(t.Dir(), resolveAliases(t.Elem()))
+
+	case *types.Signature:
+		return types.NewSignatureType(nil, nil, nil, resolveTupleAliases(t.Params()), resolveTupleAliases(t.Results()), t.Variadic())
+
+	case *types.Interface:
+		// Rendered as any in the generated code
+		if t.Empty() {
+			return types.Universe.Lookup("any").Type()
+		}
+
+		return t
+
+	default:
+		return t
+	}
+}
+
+func resolveTupleAliases(tuple *types.Tuple) *types.Tuple {
+	vars := make([]*types.Var, 0, tuple.Len())
+
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		vars = append(vars, types.NewParam(v.Pos(), v.Pkg(), v.Name(), resolveAliases(v.Type())))
+	}
+
+	return types.NewTuple(vars...)
+}
+
+// serviceTypeName derives a service name from t.
+//
+// Named types (and pointers to them) are named after the type.
+// Generic instantiations are prefixed with the names of their type arguments (eg. Repo[User] becomes UserRepo).
+// Other types (slices, maps, functions, etc.) have no derived name.
+func serviceTypeName(t types.Type) (string, bool) {
+	switch t := unalias(t).(type) {
+	case *types.Named:
+		// Predeclared types (eg. error)
+		if t.Obj().Pkg() == nil {
+			return "", false
+		}
+
+		var prefix string
+
+		args := t.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			argName, ok := serviceTypeName(args.At(i))
+			if !ok {
+				return "", false
+			}
+
+			prefix += argName
+		}
+
+		return prefix + t.Obj().Name(), true
+
+	case *types.Pointer:
+		return serviceTypeName(t.Elem())
+	}
+
+	return "", false
+}
+
+// typePackagePath returns the import path of the package declaring t (or the type t points to).
+func typePackagePath(t types.Type) string {
+	switch t := unalias(t).(type) {
+	case *types.Named:
+		if t.Obj().Pkg() != nil {
+			return t.Obj().Pkg().Path()
+		}
+
+	case *types.Pointer:
+		return typePackagePath(t.Elem())
+	}
+
+	return ""
+}
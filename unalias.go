@@ -0,0 +1,10 @@
+package main
+
+import "go/types"
+
+// unalias returns the type t is an alias of (or t itself).
+//
+// Since Go 1.23, aliases (including any) are represented by [types.Alias] by default.
+func unalias(t types.Type) types.Type {
+	return types.Unalias(t)
+}